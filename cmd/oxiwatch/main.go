@@ -6,11 +6,18 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/oxisoft/oxiwatch/internal/backfill"
 	"github.com/oxisoft/oxiwatch/internal/config"
 	"github.com/oxisoft/oxiwatch/internal/daemon"
 	"github.com/oxisoft/oxiwatch/internal/geoip"
 	"github.com/oxisoft/oxiwatch/internal/notifier"
+	"github.com/oxisoft/oxiwatch/internal/parser"
 	"github.com/oxisoft/oxiwatch/internal/report"
 	"github.com/oxisoft/oxiwatch/internal/storage"
 	"github.com/oxisoft/oxiwatch/internal/version"
@@ -33,16 +40,38 @@ func main() {
 	switch os.Args[1] {
 	case "daemon":
 		runDaemon(configPath)
+	case "status":
+		runStatus(configPath)
 	case "stats":
 		runStats(configPath)
+	case "report":
+		runReport(configPath)
+	case "incident":
+		runIncident(configPath)
+	case "keys":
+		runKeys(configPath)
+	case "campaigns":
+		runCampaigns(configPath)
 	case "geoip":
 		runGeoIP(configPath)
+	case "import":
+		runImport(configPath)
 	case "cleanup":
 		runCleanup(configPath)
+	case "simulate":
+		runSimulate(configPath)
+	case "hold":
+		runHold(configPath)
+	case "learning":
+		runLearning(configPath)
+	case "trusted":
+		runTrusted(configPath)
 	case "config":
 		runConfig(configPath)
 	case "send-test":
 		runSendTest(configPath)
+	case "notify":
+		runNotify(configPath)
 	case "upgrade":
 		runUpgrade()
 	case "version":
@@ -61,15 +90,46 @@ func printUsage() {
 
 Commands:
   daemon [-f|--foreground]     Run monitoring daemon
-  stats today                  Show today's statistics
-  stats report [-d N]          Generate report (last N days, default 1)
-  stats logins [-d N]          Show successful logins (last N days, default 7)
+  status                       Show the daemon's internal state (queues, cooldowns, failures, next runs)
+  stats today [--service S]    Show today's statistics
+  stats report [-d N] [--service S]
+                               Generate report (last N days, default 1)
+  stats logins [-d N] [--service S] [--country CC] [--continent CC]
+                               Show successful logins (last N days, default 7)
+  report preview [--date D] [--template FILE]
+                               Print the daily report for date D (default: yesterday) without sending it
+  report summary [--period P] [--html FILE]
+                               Quarterly (2025Q3) or annual (2025) summary (default: last complete quarter)
+  incident (--ip IP | --user NAME) [--window 24h] [--until T] [-o FILE]
+                               Markdown timeline of an IP's or user's events for incident writeups
+  keys [--unused-days N]       SSH key inventory: shared keys and keys unused for N days (default 90)
+  campaigns [--days N]         Attack campaigns: IPs in one network trying the same usernames (default 7 days)
   geoip update                 Download/update GeoIP database
   geoip status                 Show GeoIP database info
+  geoip reenrich [--since DATE] [--all]
+                               Look up stored events again (default: only those missing geo data)
+  geoip compare                Show how often the comparison database disagreed
+  import [--year N] FILE       Backfill SSH events from a syslog auth.log file
   cleanup                      Manually run retention cleanup
+  hold add (--ip IP|CIDR | --user NAME) [--since T] [--until T] --reason TEXT
+                               Keep matching events past retention for an investigation
+  hold list [--all]            List legal holds (--all includes released ones)
+  hold release ID              Release a legal hold so cleanup can delete its events
+  trusted candidates [--days N]
+                               Frequent, never-failed login IPs not yet trusted (default 30 days)
+  trusted approve [--note TEXT] IP|CIDR...
+                               Trust networks without editing the config
+  trusted list                 Trusted networks from the config and approved ones
+  trusted remove IP|CIDR       Withdraw an approval
+  learning                     Per-user login baselines and trusted IP candidates from the learning period
+  simulate [--days N] [--start T]
+                               Fast-forward scheduled reports and checks on a copy of the database
   config validate              Validate configuration
   config show                  Show active configuration
   send-test                    Send test Telegram message
+  notify test [--channel C] [--event FILE] [--dry-run]
+                               Render a sample login alert with the configured fields and theme and send it
+                               through every configured channel (telegram, journald) or one
   upgrade                      Self-upgrade to latest release
   version                      Show version
   help                         Show this help
@@ -122,11 +182,15 @@ func runStats(configPath string) {
 	}
 	defer store.Close()
 
-	gen := report.NewGenerator(store, cfg.ServerName, Version)
+	gen := report.NewGenerator(store, cfg.ServerName, cfg.Metadata, Version)
 
 	switch os.Args[2] {
 	case "today":
-		output, err := gen.GenerateStats(1)
+		fs := flag.NewFlagSet("today", flag.ExitOnError)
+		service := fs.String("service", "", "Only include this service (ssh, web, smb, ...)")
+		fs.Parse(os.Args[3:])
+
+		output, err := gen.GenerateStats(1, *service)
 		if err != nil {
 			fatal("failed to generate stats: %v", err)
 		}
@@ -135,9 +199,10 @@ func runStats(configPath string) {
 	case "report":
 		fs := flag.NewFlagSet("report", flag.ExitOnError)
 		days := fs.Int("d", 1, "Number of days")
+		service := fs.String("service", "", "Only include this service (ssh, web, smb, ...)")
 		fs.Parse(os.Args[3:])
 
-		output, err := gen.GenerateStats(*days)
+		output, err := gen.GenerateStats(*days, *service)
 		if err != nil {
 			fatal("failed to generate report: %v", err)
 		}
@@ -146,9 +211,16 @@ func runStats(configPath string) {
 	case "logins":
 		fs := flag.NewFlagSet("logins", flag.ExitOnError)
 		days := fs.Int("d", 7, "Number of days")
+		service := fs.String("service", "", "Only include this service (ssh, web, smb, ...)")
+		country := fs.String("country", "", "Only include this ISO country code (e.g. DE)")
+		continent := fs.String("continent", "", "Only include this continent code (AF, AN, AS, EU, NA, OC, SA)")
 		fs.Parse(os.Args[3:])
 
-		output, err := gen.GenerateLoginsReport(*days)
+		output, err := gen.GenerateLoginsReport(*days, storage.EventFilter{
+			Service:       *service,
+			CountryCode:   *country,
+			ContinentCode: *continent,
+		})
 		if err != nil {
 			fatal("failed to generate logins report: %v", err)
 		}
@@ -160,9 +232,243 @@ func runStats(configPath string) {
 	}
 }
 
+func runReport(configPath string) {
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, "Usage: oxiwatch report <preview|summary>")
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "preview":
+		runReportPreview(configPath)
+	case "summary":
+		runReportSummary(configPath)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown report command: %s\n", os.Args[2])
+		os.Exit(1)
+	}
+}
+
+func runReportPreview(configPath string) {
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	dateStr := fs.String("date", "", "Day to report on (default: yesterday)")
+	templatePath := fs.String("template", "", "Template to render instead of daily_report_template")
+	fs.Parse(os.Args[3:])
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fatal("failed to load config: %v", err)
+	}
+
+	date := time.Now().AddDate(0, 0, -1)
+	if *dateStr != "" {
+		date, err = time.ParseInLocation("2006-01-02", *dateStr, time.Local)
+		if err != nil {
+			fatal("invalid --date %q: %v", *dateStr, err)
+		}
+	}
+
+	store, err := storage.New(cfg.DatabasePath)
+	if err != nil {
+		fatal("failed to open database: %v", err)
+	}
+	defer store.Close()
+
+	gen := report.NewGenerator(store, cfg.ServerName, cfg.Metadata, Version)
+	if *templatePath == "" {
+		*templatePath = cfg.DailyReportTemplate
+	}
+	if *templatePath != "" {
+		if err := gen.LoadTemplate(*templatePath); err != nil {
+			fatal("failed to load template: %v", err)
+		}
+	}
+
+	output, err := gen.ComposeDailyReport(date, cfg)
+	if err != nil {
+		fatal("failed to generate report: %v", err)
+	}
+	fmt.Print(output)
+}
+
+func runReportSummary(configPath string) {
+	fs := flag.NewFlagSet("summary", flag.ExitOnError)
+	period := fs.String("period", "", "Year (2025) or quarter (2025Q3) to summarise (default: last complete quarter)")
+	htmlPath := fs.String("html", "", "Also write the summary as an HTML page to this file")
+	fs.Parse(os.Args[3:])
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fatal("failed to load config: %v", err)
+	}
+
+	now := time.Now()
+	if *period == "" {
+		lastQuarter := now.UTC().AddDate(0, -3, 0)
+		*period = fmt.Sprintf("%dQ%d", lastQuarter.Year(), (int(lastQuarter.Month())+2)/3)
+	}
+	since, until, title, err := report.ParsePeriod(*period)
+	if err != nil {
+		fatal("%v", err)
+	}
+
+	store, err := storage.New(cfg.DatabasePath)
+	if err != nil {
+		fatal("failed to open database: %v", err)
+	}
+	defer store.Close()
+
+	if _, err := report.UpdateRollups(store, now.AddDate(0, 0, -cfg.RetentionDays), now); err != nil {
+		fatal("failed to update rollups: %v", err)
+	}
+
+	gen := report.NewGenerator(store, cfg.ServerName, cfg.Metadata, Version)
+	data, err := gen.SummaryData(since, until, title)
+	if err != nil {
+		fatal("failed to generate summary: %v", err)
+	}
+	fmt.Print(gen.GenerateSummary(data))
+
+	if *htmlPath != "" {
+		f, err := os.Create(*htmlPath)
+		if err != nil {
+			fatal("failed to create %s: %v", *htmlPath, err)
+		}
+		if err := report.WriteSummaryHTML(f, data); err != nil {
+			f.Close()
+			fatal("failed to write %s: %v", *htmlPath, err)
+		}
+		if err := f.Close(); err != nil {
+			fatal("failed to write %s: %v", *htmlPath, err)
+		}
+		fmt.Printf("\nHTML summary written to %s\n", *htmlPath)
+	}
+}
+
+func runIncident(configPath string) {
+	fs := flag.NewFlagSet("incident", flag.ExitOnError)
+	ip := fs.String("ip", "", "IP address to build the timeline for")
+	user := fs.String("user", "", "Username to build the timeline for")
+	window := fs.Duration("window", 24*time.Hour, "How far back from --until to look")
+	untilStr := fs.String("until", "", "End of the window, YYYY-MM-DD HH:MM in local time (default: now)")
+	output := fs.String("o", "", "Write the timeline to this file instead of stdout")
+	fs.Parse(os.Args[2:])
+
+	if *ip == "" && *user == "" {
+		fmt.Fprintln(os.Stderr, "Usage: oxiwatch incident (--ip IP | --user NAME) [--window 24h] [--until \"YYYY-MM-DD HH:MM\"] [-o FILE]")
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fatal("failed to load config: %v", err)
+	}
+
+	until := time.Now()
+	if *untilStr != "" {
+		until, err = time.ParseInLocation("2006-01-02 15:04", *untilStr, time.Local)
+		if err != nil {
+			fatal("invalid --until %q: %v", *untilStr, err)
+		}
+	}
+
+	store, err := storage.New(cfg.DatabasePath)
+	if err != nil {
+		fatal("failed to open database: %v", err)
+	}
+	defer store.Close()
+
+	gen := report.NewGenerator(store, cfg.ServerName, cfg.Metadata, Version)
+	timeline, err := gen.GenerateIncidentTimeline(storage.EventFilter{IP: *ip, Username: *user}, until.Add(-*window), until)
+	if err != nil {
+		fatal("failed to build timeline: %v", err)
+	}
+
+	if *output == "" {
+		fmt.Print(timeline)
+		return
+	}
+	if err := os.WriteFile(*output, []byte(timeline), 0644); err != nil {
+		fatal("failed to write %s: %v", *output, err)
+	}
+	fmt.Printf("Timeline written to %s\n", *output)
+}
+
+func runKeys(configPath string) {
+	fs := flag.NewFlagSet("keys", flag.ExitOnError)
+	unusedDays := fs.Int("unused-days", 90, "Flag keys not used in this many days")
+	fs.Parse(os.Args[2:])
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fatal("failed to load config: %v", err)
+	}
+
+	store, err := storage.New(cfg.DatabasePath)
+	if err != nil {
+		fatal("failed to open database: %v", err)
+	}
+	defer store.Close()
+
+	gen := report.NewGenerator(store, cfg.ServerName, cfg.Metadata, Version)
+	output, err := gen.GenerateKeyInventory(*unusedDays, time.Now())
+	if err != nil {
+		fatal("failed to generate key inventory: %v", err)
+	}
+	fmt.Print(output)
+}
+
+func runCampaigns(configPath string) {
+	fs := flag.NewFlagSet("campaigns", flag.ExitOnError)
+	days := fs.Int("days", 7, "Number of days to look back")
+	fs.Parse(os.Args[2:])
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fatal("failed to load config: %v", err)
+	}
+
+	store, err := storage.New(cfg.DatabasePath)
+	if err != nil {
+		fatal("failed to open database: %v", err)
+	}
+	defer store.Close()
+
+	gen := report.NewGenerator(store, cfg.ServerName, cfg.Metadata, Version)
+	output, err := gen.GenerateCampaigns(*days, time.Now())
+	if err != nil {
+		fatal("failed to list campaigns: %v", err)
+	}
+	fmt.Print(output)
+}
+
+func runStatus(configPath string) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fatal("failed to load config: %v", err)
+	}
+
+	store, err := storage.New(cfg.DatabasePath)
+	if err != nil {
+		fatal("failed to open database: %v", err)
+	}
+	defer store.Close()
+
+	status, err := daemon.LoadStatus(store)
+	if err != nil {
+		fatal("failed to load status: %v", err)
+	}
+	if status == nil {
+		fmt.Println("No status saved yet; the daemon saves one every minute while running.")
+		return
+	}
+	fmt.Print(status.Format(time.Now()))
+	fmt.Println("\nFor a fresh snapshot in the daemon log: systemctl kill -s USR1 oxiwatch")
+}
+
 func runGeoIP(configPath string) {
 	if len(os.Args) < 3 {
-		fmt.Fprintln(os.Stderr, "Usage: oxiwatch geoip <update|status>")
+		fmt.Fprintln(os.Stderr, "Usage: oxiwatch geoip <update|status|reenrich|compare>")
 		os.Exit(1)
 	}
 
@@ -181,6 +487,13 @@ func runGeoIP(configPath string) {
 		}
 		fmt.Println("GeoIP database updated successfully")
 
+		if cfg.ASNEnabled {
+			if err := geoip.NewASNUpdater(cfg.ASNDatabasePath, logger).Update(); err != nil {
+				fatal("failed to update ASN database: %v", err)
+			}
+			fmt.Println("ASN database updated successfully")
+		}
+
 	case "status":
 		if !updater.DatabaseExists() {
 			fmt.Println("GeoIP database: not found")
@@ -218,12 +531,135 @@ func runGeoIP(configPath string) {
 			}
 		}
 
+	case "reenrich":
+		fs := flag.NewFlagSet("reenrich", flag.ExitOnError)
+		sinceStr := fs.String("since", "", "Only events on or after this date (YYYY-MM-DD)")
+		all := fs.Bool("all", false, "Also refresh events that already have geo data")
+		fs.Parse(os.Args[3:])
+
+		var since time.Time
+		if *sinceStr != "" {
+			since, err = time.ParseInLocation("2006-01-02", *sinceStr, time.Local)
+			if err != nil {
+				fatal("invalid --since date %q: %v", *sinceStr, err)
+			}
+		}
+		reenrich(cfg, since, !*all)
+
+	case "compare":
+		if cfg.GeoIPCompareDatabasePath == "" {
+			fatal("geoip_compare_database_path is not set")
+		}
+
+		store, err := storage.New(cfg.DatabasePath)
+		if err != nil {
+			fatal("failed to open database: %v", err)
+		}
+		defer store.Close()
+
+		gen := report.NewGenerator(store, cfg.ServerName, cfg.Metadata, Version)
+		output, err := gen.GenerateGeoIPComparison(cfg.GeoIPDatabasePath, cfg.GeoIPCompareDatabasePath)
+		if err != nil {
+			fatal("failed to generate comparison: %v", err)
+		}
+		fmt.Print(output)
+
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown geoip command: %s\n", os.Args[2])
 		os.Exit(1)
 	}
 }
 
+func reenrich(cfg *config.Config, since time.Time, missingOnly bool) {
+	resolver, err := geoip.NewResolver(cfg.GeoIPDatabasePath)
+	if err != nil {
+		fatal("failed to open GeoIP database: %v", err)
+	}
+	defer resolver.Close()
+	if cfg.ASNEnabled {
+		if err := resolver.LoadASN(cfg.ASNDatabasePath); err != nil {
+			fatal("failed to open ASN database: %v", err)
+		}
+	}
+
+	store, err := storage.New(cfg.DatabasePath)
+	if err != nil {
+		fatal("failed to open database: %v", err)
+	}
+	defer store.Close()
+
+	ips, err := store.GetIPsToEnrich(since, missingOnly, cfg.ASNEnabled)
+	if err != nil {
+		fatal("failed to list events: %v", err)
+	}
+
+	var updated int64
+	for _, ip := range ips {
+		loc, err := resolver.Lookup(ip)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "lookup %s: %v\n", ip, err)
+			continue
+		}
+		n, err := store.UpdateLocation(ip, since, *loc)
+		if err != nil {
+			fatal("failed to update %s: %v", ip, err)
+		}
+		updated += n
+	}
+
+	fmt.Printf("Re-enriched %d events from %d IPs.\n", updated, len(ips))
+}
+
+func runImport(configPath string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	year := fs.Int("year", 0, "Year of the first line (default: derived from the file's modification time)")
+	fs.Parse(os.Args[2:])
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: oxiwatch import [--year N] FILE")
+		os.Exit(1)
+	}
+	path := fs.Arg(0)
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fatal("failed to load config: %v", err)
+	}
+
+	store, err := storage.New(cfg.DatabasePath)
+	if err != nil {
+		fatal("failed to open database: %v", err)
+	}
+	defer store.Close()
+
+	var resolver *geoip.Resolver
+	if cfg.GeoIPEnabled {
+		if r, err := geoip.NewResolver(cfg.GeoIPDatabasePath); err == nil {
+			resolver = r
+			defer resolver.Close()
+		}
+	}
+
+	startYear := *year
+	if startYear == 0 {
+		startYear, err = backfill.StartYear(path)
+		if err != nil {
+			fatal("failed to determine start year: %v", err)
+		}
+	}
+
+	result, err := backfill.Import(path, startYear, store, resolver)
+	if err != nil {
+		fatal("import failed: %v", err)
+	}
+
+	years := fmt.Sprintf("%d", result.FirstYear)
+	if result.LastYear != result.FirstYear {
+		years = fmt.Sprintf("%d-%d", result.FirstYear, result.LastYear)
+	}
+	fmt.Printf("Imported %d events from %d lines (%s), skipped %d already stored.\n", result.Events, result.Lines, years, result.Duplicates)
+}
+
 func runCleanup(configPath string) {
 	cfg, err := config.Load(configPath)
 	if err != nil {
@@ -236,12 +672,352 @@ func runCleanup(configPath string) {
 	}
 	defer store.Close()
 
-	deleted, err := store.Cleanup(cfg.RetentionDays)
+	now := time.Now()
+	if _, err := report.UpdateRollups(store, now.AddDate(0, 0, -cfg.RetentionDays), now); err != nil {
+		fatal("failed to update rollups: %v", err)
+	}
+
+	deleted, err := store.Cleanup(now, cfg.RetentionDays)
 	if err != nil {
 		fatal("cleanup failed: %v", err)
 	}
 
 	fmt.Printf("Cleanup completed. Deleted %d records older than %d days.\n", deleted, cfg.RetentionDays)
+	if holds, err := store.GetLegalHolds(false); err == nil && len(holds) > 0 {
+		fmt.Printf("Events covered by %d active legal holds were kept, see 'oxiwatch hold list'.\n", len(holds))
+	}
+}
+
+func runSimulate(configPath string) {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	days := fs.Int("days", 7, "Number of days to fast-forward")
+	startStr := fs.String("start", "", "Start of the simulation, YYYY-MM-DD HH:MM in local time (default: now)")
+	fs.Parse(os.Args[2:])
+
+	if *days < 1 {
+		fatal("--days must be at least 1")
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fatal("failed to load config: %v", err)
+	}
+
+	start := time.Now().Truncate(time.Minute)
+	if *startStr != "" {
+		start, err = time.ParseInLocation("2006-01-02 15:04", *startStr, time.Local)
+		if err != nil {
+			fatal("invalid --start %q: %v", *startStr, err)
+		}
+	}
+
+	// Cleanup and rollups write to the database, so simulate on a copy.
+	store, err := storage.New(cfg.DatabasePath)
+	if err != nil {
+		fatal("failed to open database: %v", err)
+	}
+	dir, err := os.MkdirTemp("", "oxiwatch-simulate")
+	if err != nil {
+		fatal("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	copyPath := filepath.Join(dir, "oxiwatch.db")
+	err = store.CopyTo(copyPath)
+	store.Close()
+	if err != nil {
+		fatal("failed to copy database: %v", err)
+	}
+
+	simStore, err := storage.New(copyPath)
+	if err != nil {
+		fatal("failed to open database copy: %v", err)
+	}
+	defer simStore.Close()
+
+	logger := setupLogger("warn")
+	messages, err := daemon.Simulate(cfg, logger, Version, simStore, start, *days)
+	if err != nil {
+		fatal("simulation failed: %v", err)
+	}
+
+	for _, m := range messages {
+		fmt.Printf("--- %s ---\n%s\n\n", m.At.Local().Format("2006-01-02 15:04"), strings.TrimSpace(m.Text))
+	}
+	fmt.Printf("%d notifications in %d days from %s. The database was not modified.\n", len(messages), *days, start.Format("2006-01-02 15:04"))
+}
+
+func runHold(configPath string) {
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, "Usage: oxiwatch hold <add|list|release>")
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fatal("failed to load config: %v", err)
+	}
+
+	store, err := storage.New(cfg.DatabasePath)
+	if err != nil {
+		fatal("failed to open database: %v", err)
+	}
+	defer store.Close()
+
+	switch os.Args[2] {
+	case "add":
+		fs := flag.NewFlagSet("hold add", flag.ExitOnError)
+		ip := fs.String("ip", "", "IP address or CIDR range to hold")
+		user := fs.String("user", "", "Username to hold")
+		sinceStr := fs.String("since", "", "Only hold events from this time on, YYYY-MM-DD HH:MM in local time")
+		untilStr := fs.String("until", "", "Only hold events before this time, YYYY-MM-DD HH:MM in local time")
+		reason := fs.String("reason", "", "Why the events are held, e.g. an incident or case number")
+		fs.Parse(os.Args[3:])
+
+		if (*ip == "" && *user == "") || *reason == "" {
+			fmt.Fprintln(os.Stderr, "Usage: oxiwatch hold add (--ip IP|CIDR | --user NAME) [--since T] [--until T] --reason TEXT")
+			os.Exit(1)
+		}
+
+		hold := storage.LegalHold{Username: *user, Reason: *reason, CreatedAt: time.Now()}
+		if *ip != "" {
+			if hold.IPRange, err = storage.ParseIPRange(*ip); err != nil {
+				fatal("%v", err)
+			}
+		}
+		if *sinceStr != "" {
+			if hold.Since, err = time.ParseInLocation("2006-01-02 15:04", *sinceStr, time.Local); err != nil {
+				fatal("invalid --since %q: %v", *sinceStr, err)
+			}
+		}
+		if *untilStr != "" {
+			if hold.Until, err = time.ParseInLocation("2006-01-02 15:04", *untilStr, time.Local); err != nil {
+				fatal("invalid --until %q: %v", *untilStr, err)
+			}
+		}
+
+		id, err := store.AddLegalHold(hold)
+		if err != nil {
+			fatal("failed to add legal hold: %v", err)
+		}
+		fmt.Printf("Legal hold %d added: %s. Matching events are kept past retention until it is released.\n", id, describeHold(hold))
+
+	case "list":
+		fs := flag.NewFlagSet("hold list", flag.ExitOnError)
+		all := fs.Bool("all", false, "Include released holds")
+		fs.Parse(os.Args[3:])
+
+		holds, err := store.GetLegalHolds(*all)
+		if err != nil {
+			fatal("failed to load legal holds: %v", err)
+		}
+		if len(holds) == 0 {
+			fmt.Println("No legal holds.")
+			return
+		}
+		for _, h := range holds {
+			status := "active"
+			if !h.ReleasedAt.IsZero() {
+				status = "released " + h.ReleasedAt.Format("2006-01-02 15:04")
+			}
+			fmt.Printf("%4d  %s  %s\n      %s (added %s)\n", h.ID, status, describeHold(h), h.Reason, h.CreatedAt.Format("2006-01-02 15:04"))
+		}
+
+	case "release":
+		if len(os.Args) < 4 {
+			fmt.Fprintln(os.Stderr, "Usage: oxiwatch hold release ID")
+			os.Exit(1)
+		}
+		id, err := strconv.ParseInt(os.Args[3], 10, 64)
+		if err != nil {
+			fatal("invalid hold ID %q", os.Args[3])
+		}
+		released, err := store.ReleaseLegalHold(id, time.Now())
+		if err != nil {
+			fatal("failed to release legal hold: %v", err)
+		}
+		if !released {
+			fatal("no active legal hold with ID %d", id)
+		}
+		fmt.Printf("Legal hold %d released. Its events are deleted by the next cleanup once past retention.\n", id)
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown hold command: %s\n", os.Args[2])
+		os.Exit(1)
+	}
+}
+
+func runLearning(configPath string) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fatal("failed to load config: %v", err)
+	}
+
+	store, err := storage.New(cfg.DatabasePath)
+	if err != nil {
+		fatal("failed to open database: %v", err)
+	}
+	defer store.Close()
+
+	trusted, err := daemon.TrustedNetworks(cfg, store)
+	if err != nil {
+		fatal("failed to load trusted networks: %v", err)
+	}
+
+	now := time.Now()
+	start, end, err := daemon.LearningPeriod(store, cfg.LearningDays)
+	if err != nil {
+		fatal("failed to load learning period: %v", err)
+	}
+	switch {
+	case cfg.LearningDays == 0 || start.IsZero():
+		start, end = now.AddDate(0, 0, -7), now
+		fmt.Println("Learning mode is not active (learning_days is 0 or the daemon has not run yet). Summary of the last 7 days:")
+	case now.Before(end):
+		fmt.Printf("Learning mode until %s, alerts are suppressed. Summary so far:\n", end.Format("2006-01-02 15:04"))
+		end = now
+	default:
+		fmt.Printf("Learning period ended %s.\n", end.Format("2006-01-02 15:04"))
+	}
+	fmt.Println()
+
+	gen := report.NewGenerator(store, cfg.ServerName, cfg.Metadata, Version)
+	output, err := gen.GenerateLearningSummary(start, end, trusted)
+	if err != nil {
+		fatal("failed to generate learning summary: %v", err)
+	}
+	fmt.Print(output)
+}
+
+func runTrusted(configPath string) {
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, "Usage: oxiwatch trusted <candidates|approve|list|remove>")
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fatal("failed to load config: %v", err)
+	}
+
+	store, err := storage.New(cfg.DatabasePath)
+	if err != nil {
+		fatal("failed to open database: %v", err)
+	}
+	defer store.Close()
+
+	switch os.Args[2] {
+	case "candidates":
+		fs := flag.NewFlagSet("trusted candidates", flag.ExitOnError)
+		days := fs.Int("days", 30, "Number of days of logins to consider")
+		fs.Parse(os.Args[3:])
+
+		trusted, err := daemon.TrustedNetworks(cfg, store)
+		if err != nil {
+			fatal("failed to load trusted networks: %v", err)
+		}
+		now := time.Now()
+		gen := report.NewGenerator(store, cfg.ServerName, cfg.Metadata, Version)
+		candidates, err := gen.TrustedCandidates(now.AddDate(0, 0, -*days), now, trusted)
+		if err != nil {
+			fatal("failed to find candidates: %v", err)
+		}
+		if len(candidates) == 0 {
+			fmt.Printf("No untrusted IP with at least %d logins and no failed attempts in the last %d days.\n", report.MinCandidateLogins, *days)
+			return
+		}
+		for _, c := range candidates {
+			location := c.Country
+			if c.City != "" {
+				location = c.City + ", " + c.Country
+			}
+			fmt.Printf("%-39s  %5d logins  last %s  %s  %s\n",
+				c.IP, c.Logins, c.LastSeen.Format("2006-01-02 15:04"), strings.Join(c.Users, ","), location)
+		}
+		fmt.Println("\nApprove with: oxiwatch trusted approve IP...")
+
+	case "approve":
+		fs := flag.NewFlagSet("trusted approve", flag.ExitOnError)
+		note := fs.String("note", "", "Why the network is trusted, e.g. \"office VPN\"")
+		fs.Parse(os.Args[3:])
+
+		if fs.NArg() == 0 {
+			fmt.Fprintln(os.Stderr, "Usage: oxiwatch trusted approve [--note TEXT] IP|CIDR...")
+			os.Exit(1)
+		}
+		for _, arg := range fs.Args() {
+			ipRange, err := storage.ParseIPRange(arg)
+			if err != nil {
+				fatal("%v", err)
+			}
+			added, err := store.AddTrustedNetwork(storage.TrustedNetwork{IPRange: ipRange, Note: *note, ApprovedAt: time.Now()})
+			if err != nil {
+				fatal("failed to approve %s: %v", ipRange, err)
+			}
+			if !added {
+				fmt.Printf("%s is already approved.\n", ipRange)
+				continue
+			}
+			fmt.Printf("%s approved. Within a minute its logins no longer alert unless a warning applies.\n", ipRange)
+		}
+
+	case "list":
+		for _, network := range cfg.TrustedNetworks {
+			fmt.Printf("%-43s  config\n", network)
+		}
+		networks, err := store.GetTrustedNetworks()
+		if err != nil {
+			fatal("failed to load trusted networks: %v", err)
+		}
+		for _, n := range networks {
+			line := fmt.Sprintf("%-43s  approved %s", n.IPRange, n.ApprovedAt.Format("2006-01-02 15:04"))
+			if n.Note != "" {
+				line += "  " + n.Note
+			}
+			fmt.Println(line)
+		}
+		if len(cfg.TrustedNetworks) == 0 && len(networks) == 0 {
+			fmt.Println("No trusted networks.")
+		}
+
+	case "remove":
+		if len(os.Args) < 4 {
+			fmt.Fprintln(os.Stderr, "Usage: oxiwatch trusted remove IP|CIDR")
+			os.Exit(1)
+		}
+		ipRange, err := storage.ParseIPRange(os.Args[3])
+		if err != nil {
+			fatal("%v", err)
+		}
+		removed, err := store.RemoveTrustedNetwork(ipRange)
+		if err != nil {
+			fatal("failed to remove %s: %v", ipRange, err)
+		}
+		if !removed {
+			fatal("%s is not an approved network; entries in trusted_networks are removed by editing the config", ipRange)
+		}
+		fmt.Printf("%s removed. Logins from it alert again.\n", ipRange)
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown trusted command: %s\n", os.Args[2])
+		os.Exit(1)
+	}
+}
+
+func describeHold(h storage.LegalHold) string {
+	var parts []string
+	if h.IPRange != "" {
+		parts = append(parts, "IPs in "+h.IPRange)
+	}
+	if h.Username != "" {
+		parts = append(parts, "user "+h.Username)
+	}
+	if !h.Since.IsZero() {
+		parts = append(parts, "from "+h.Since.Format("2006-01-02 15:04"))
+	}
+	if !h.Until.IsZero() {
+		parts = append(parts, "until "+h.Until.Format("2006-01-02 15:04"))
+	}
+	return strings.Join(parts, ", ")
 }
 
 func runConfig(configPath string) {
@@ -291,10 +1067,11 @@ func runSendTest(configPath string) {
 		fatal("invalid config: %v", err)
 	}
 
-	telegram, err := notifier.NewTelegram(cfg.TelegramBotToken, cfg.TelegramChatID, cfg.ServerName)
+	telegram, err := notifier.NewTelegram(cfg.TelegramBotToken, cfg.TelegramChatID, cfg.ServerName, cfg.Metadata)
 	if err != nil {
 		fatal("failed to create telegram notifier: %v", err)
 	}
+	telegram.SetTheme(cfg.Theme)
 	if err := telegram.SendTestMessage(); err != nil {
 		fatal("failed to send test message: %v", err)
 	}
@@ -302,6 +1079,201 @@ func runSendTest(configPath string) {
 	fmt.Println("Test message sent successfully")
 }
 
+// sampleLogin is the --event file of notify test. Fields left out keep the
+// values of defaultSampleLogin.
+type sampleLogin struct {
+	Service     string `json:"service"`
+	User        string `json:"user"`
+	IP          string `json:"ip"`
+	Port        int    `json:"port"`
+	Method      string `json:"method"`
+	KeyType     string `json:"key_type"`
+	Fingerprint string `json:"fingerprint"`
+	MFA         bool   `json:"mfa"`
+	Country     string `json:"country"`
+	CountryCode string `json:"country_code"`
+	City        string `json:"city"`
+	ASN         uint   `json:"asn"`
+	ASOrg       string `json:"as_org"`
+	History     string `json:"history"`
+	Warning     string `json:"warning"`
+	NewKey      bool   `json:"new_key"`
+}
+
+var defaultSampleLogin = sampleLogin{
+	Service:     parser.ServiceSSH,
+	User:        "alice",
+	IP:          "198.51.100.7",
+	Port:        50022,
+	Method:      "publickey",
+	KeyType:     "ED25519",
+	Fingerprint: "SHA256:7Ld3Fq0mR3hTzJtqkq5d2k3b1Yx8wVw0bWZC1bQ4nTI",
+	Country:     "Germany",
+	CountryCode: "DE",
+	City:        "Berlin",
+	ASN:         3320,
+	ASOrg:       "Deutsche Telekom AG",
+	History:     "seen 14 times in 30 days, first 2026-01-02",
+}
+
+func runNotify(configPath string) {
+	if len(os.Args) < 3 || os.Args[2] != "test" {
+		fmt.Fprintln(os.Stderr, "Usage: oxiwatch notify test [--channel C] [--event FILE] [--dry-run]")
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("notify test", flag.ExitOnError)
+	channel := fs.String("channel", "", "Channel to send through, e.g. telegram or journald (default: every configured channel)")
+	eventPath := fs.String("event", "", "JSON file describing the login to alert on (default: built-in sample)")
+	dryRun := fs.Bool("dry-run", false, "Print the rendered alert instead of sending it")
+	fs.Parse(os.Args[3:])
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fatal("failed to load config: %v", err)
+	}
+	// A dry run needs no bot token, so it can preview fields and themes
+	// before the channel is set up.
+	if !*dryRun {
+		if err := cfg.Validate(); err != nil {
+			fatal("invalid config: %v", err)
+		}
+	}
+
+	channels := notifyChannels(cfg)
+	if *channel != "" {
+		if !slices.Contains(channels, *channel) {
+			fatal("channel %q is not configured (configured: %s)", *channel, strings.Join(channels, ", "))
+		}
+		channels = []string{*channel}
+	}
+
+	sample := defaultSampleLogin
+	if *eventPath != "" {
+		data, err := os.ReadFile(*eventPath)
+		if err != nil {
+			fatal("failed to read event: %v", err)
+		}
+		if err := json.Unmarshal(data, &sample); err != nil {
+			fatal("failed to parse event %s: %v", *eventPath, err)
+		}
+	}
+
+	event := &parser.SSHEvent{
+		Timestamp:   time.Now(),
+		EventType:   parser.EventSuccess,
+		Service:     sample.Service,
+		Username:    sample.User,
+		IP:          sample.IP,
+		Port:        sample.Port,
+		Method:      sample.Method,
+		KeyType:     sample.KeyType,
+		Fingerprint: sample.Fingerprint,
+		MFA:         sample.MFA,
+	}
+	loc := geoip.Location{
+		Country:     sample.Country,
+		CountryCode: sample.CountryCode,
+		City:        sample.City,
+		ASN:         sample.ASN,
+		ASOrg:       sample.ASOrg,
+	}
+
+	for _, name := range channels {
+		if *dryRun {
+			fmt.Printf("--- %s ---\n", name)
+		}
+		switch name {
+		case "telegram":
+			err = notifyTestTelegram(cfg, *dryRun, event, loc, sample)
+		case "journald":
+			err = notifyTestJournald(cfg, *dryRun, event, loc, sample)
+		}
+		if err != nil {
+			fatal("failed to send test alert via %s: %v", name, err)
+		}
+		if !*dryRun {
+			fmt.Printf("Test alert sent via %s\n", name)
+		}
+	}
+}
+
+// notifyChannels lists the channels alerts are sent through with cfg.
+// Telegram is always configured, since Validate requires it.
+func notifyChannels(cfg *config.Config) []string {
+	channels := []string{"telegram"}
+	if cfg.JournaldAlertsEnabled {
+		channels = append(channels, "journald")
+	}
+	return channels
+}
+
+func notifyTestTelegram(cfg *config.Config, dryRun bool, event *parser.SSHEvent, loc geoip.Location, sample sampleLogin) error {
+	var telegram *notifier.Telegram
+	var recorder *notifier.Recorder
+	if dryRun {
+		recorder = notifier.NewRecorder()
+		telegram = notifier.NewWithTransport(recorder, cfg.ServerName, cfg.Metadata)
+	} else {
+		var err error
+		telegram, err = notifier.NewTelegram(cfg.TelegramBotToken, cfg.TelegramChatID, cfg.ServerName, cfg.Metadata)
+		if err != nil {
+			return err
+		}
+	}
+	telegram.SetLoginAlertFields(cfg.LoginAlertFields)
+	telegram.SetTheme(cfg.Theme)
+
+	if err := telegram.SendLoginAlert(event, loc, sample.History, sample.Warning, sample.NewKey); err != nil {
+		return err
+	}
+	if dryRun {
+		for _, m := range recorder.Messages() {
+			fmt.Println(m.Text)
+		}
+	}
+	return nil
+}
+
+// notifyTestJournald writes the journal entry the daemon writes for the
+// sample login, tagged OXIWATCH_TEST so collectors can tell it apart.
+func notifyTestJournald(cfg *config.Config, dryRun bool, event *parser.SSHEvent, loc geoip.Location, sample sampleLogin) error {
+	severity := notifier.SeverityInfo
+	if sample.NewKey {
+		severity = notifier.SeverityCritical
+	} else if sample.Warning != "" {
+		severity = notifier.SeverityWarning
+	}
+	alert := notifier.Alert{
+		Time:     event.Timestamp,
+		Server:   cfg.ServerName,
+		Kind:     "login",
+		Severity: severity,
+		Message:  fmt.Sprintf("test: %s login as %s from %s", event.Service, event.Username, event.IP),
+		Fields: map[string]string{
+			"service":     event.Service,
+			"user":        event.Username,
+			"ip":          event.IP,
+			"method":      event.Method,
+			"fingerprint": event.Fingerprint,
+			"mfa":         strconv.FormatBool(event.MFA),
+			"country":     loc.Country,
+			"city":        loc.City,
+			"history":     sample.History,
+			"warning":     sample.Warning,
+			"test":        "true",
+		},
+	}
+
+	if dryRun {
+		for _, field := range notifier.JournalFields(alert) {
+			fmt.Println(field)
+		}
+		return nil
+	}
+	return notifier.NewJournald(notifier.JournaldSocket).Emit(alert)
+}
+
 func runVersion() {
 	fmt.Printf("oxiwatch version %s\n", Version)
 
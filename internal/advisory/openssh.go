@@ -0,0 +1,183 @@
+package advisory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const osvQueryURL = "https://api.osv.dev/v1/query"
+
+var cvePattern = regexp.MustCompile(`CVE-\d{4}-\d+`)
+
+type Result struct {
+	Package   string
+	Version   string
+	Ecosystem string
+	CVEs      []string
+}
+
+type Checker struct {
+	httpClient *http.Client
+	osRelease  string
+	queryURL   string
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		osRelease:  "/etc/os-release",
+		queryURL:   osvQueryURL,
+	}
+}
+
+func (c *Checker) CheckOpenSSH() (*Result, error) {
+	ecosystem, err := c.ecosystem()
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := installedVersion("openssh-server")
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine installed OpenSSH version: %w", err)
+	}
+
+	result := &Result{
+		Package:   "openssh",
+		Version:   version,
+		Ecosystem: ecosystem,
+	}
+
+	cves, err := c.query(result.Package, ecosystem, version)
+	if err != nil {
+		return nil, err
+	}
+	result.CVEs = cves
+	return result, nil
+}
+
+func (c *Checker) ecosystem() (string, error) {
+	f, err := os.Open(c.osRelease)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if k, v, ok := strings.Cut(scanner.Text(), "="); ok {
+			fields[k] = strings.Trim(v, `"`)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	switch fields["ID"] {
+	case "debian":
+		major, _, _ := strings.Cut(fields["VERSION_ID"], ".")
+		if major == "" {
+			return "", fmt.Errorf("debian release without VERSION_ID is not supported")
+		}
+		return "Debian:" + major, nil
+	case "ubuntu":
+		ecosystem := "Ubuntu:" + fields["VERSION_ID"]
+		if strings.Contains(fields["VERSION"], "LTS") {
+			ecosystem += ":LTS"
+		}
+		return ecosystem, nil
+	default:
+		return "", fmt.Errorf("unsupported distribution %q", fields["ID"])
+	}
+}
+
+func installedVersion(pkg string) (string, error) {
+	out, err := exec.Command("dpkg-query", "-W", "-f=${Version}", pkg).Output()
+	if err != nil {
+		return "", err
+	}
+	version := strings.TrimSpace(string(out))
+	if version == "" {
+		return "", fmt.Errorf("package %s is not installed", pkg)
+	}
+	return version, nil
+}
+
+type osvQuery struct {
+	Version string `json:"version"`
+	Package struct {
+		Name      string `json:"name"`
+		Ecosystem string `json:"ecosystem"`
+	} `json:"package"`
+}
+
+type osvResponse struct {
+	Vulns []struct {
+		ID      string   `json:"id"`
+		Aliases []string `json:"aliases"`
+	} `json:"vulns"`
+}
+
+func (c *Checker) query(pkg, ecosystem, version string) ([]string, error) {
+	var q osvQuery
+	q.Version = version
+	q.Package.Name = pkg
+	q.Package.Ecosystem = ecosystem
+
+	body, err := json.Marshal(q)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Post(c.queryURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OSV API returned status %d", resp.StatusCode)
+	}
+
+	var result osvResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var cves []string
+	for _, v := range result.Vulns {
+		for _, id := range append([]string{v.ID}, v.Aliases...) {
+			cve := cvePattern.FindString(id)
+			if cve != "" && !seen[cve] {
+				seen[cve] = true
+				cves = append(cves, cve)
+			}
+		}
+	}
+	sort.Slice(cves, func(i, j int) bool {
+		yearI, numberI := cveOrder(cves[i])
+		yearJ, numberJ := cveOrder(cves[j])
+		if yearI != yearJ {
+			return yearI > yearJ
+		}
+		return numberI > numberJ
+	})
+	return cves, nil
+}
+
+// cveOrder returns the year and sequence number of a CVE ID. Sequence
+// numbers have four or more digits, so IDs do not sort as strings.
+func cveOrder(cve string) (year, number int) {
+	fmt.Sscanf(cve, "CVE-%d-%d", &year, &number)
+	return year, number
+}
@@ -0,0 +1,99 @@
+package advisory
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEcosystem(t *testing.T) {
+	tests := []struct {
+		osRelease string
+		expected  string
+	}{
+		{"ID=debian\nVERSION_ID=\"12\"\nVERSION=\"12 (bookworm)\"\n", "Debian:12"},
+		{"ID=ubuntu\nVERSION_ID=\"24.04\"\nVERSION=\"24.04.1 LTS (Noble Numbat)\"\n", "Ubuntu:24.04:LTS"},
+		{"ID=ubuntu\nVERSION_ID=\"24.10\"\nVERSION=\"24.10 (Oracular Oriole)\"\n", "Ubuntu:24.10"},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "os-release")
+		if err := os.WriteFile(path, []byte(tt.osRelease), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		c := NewChecker()
+		c.osRelease = path
+		got, err := c.ecosystem()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, got)
+		}
+	}
+}
+
+func TestEcosystemUnsupported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "os-release")
+	if err := os.WriteFile(path, []byte("ID=fedora\nVERSION_ID=40\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewChecker()
+	c.osRelease = path
+	if _, err := c.ecosystem(); err == nil {
+		t.Error("expected error for unsupported distribution")
+	}
+}
+
+func TestQuery(t *testing.T) {
+	var got osvQuery
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST, got %s", r.Method)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid query: %v", err)
+		}
+		w.Write([]byte(`{"vulns": [
+			{"id": "DSA-5586-1", "aliases": ["CVE-2023-48795", "CVE-2023-51385"]},
+			{"id": "DEBIAN-CVE-2024-6387", "aliases": ["CVE-2024-6387"]},
+			{"id": "GHSA-xxxx", "aliases": ["CVE-2023-48795"]},
+			{"id": "GHSA-yyyy", "aliases": ["CVE-2023-9999"]}
+		]}`))
+	}))
+	defer server.Close()
+
+	c := NewChecker()
+	c.queryURL = server.URL
+	cves, err := c.query("openssh", "Debian:12", "1:9.2p1-2+deb12u2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Package.Name != "openssh" || got.Package.Ecosystem != "Debian:12" || got.Version != "1:9.2p1-2+deb12u2" {
+		t.Errorf("unexpected query: %+v", got)
+	}
+	// Newest first by number, not as strings.
+	want := []string{"CVE-2024-6387", "CVE-2023-51385", "CVE-2023-48795", "CVE-2023-9999"}
+	if !reflect.DeepEqual(cves, want) {
+		t.Errorf("expected %v, got %v", want, cves)
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := NewChecker()
+	c.queryURL = server.URL
+	if _, err := c.query("openssh", "Debian:12", "1:9.2p1-2"); err == nil {
+		t.Error("expected error for a failed request")
+	}
+}
@@ -0,0 +1,152 @@
+package audit
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type Finding struct {
+	Setting  string
+	Value    string
+	Expected string
+	OK       bool
+}
+
+var defaults = map[string]string{
+	"permitrootlogin":        "prohibit-password",
+	"passwordauthentication": "yes",
+	"maxauthtries":           "6",
+}
+
+func AuditSSHDConfig(path string) ([]Finding, error) {
+	settings := make(map[string][]string)
+	if err := parseSSHDConfig(path, filepath.Dir(path), settings, 0); err != nil {
+		return nil, err
+	}
+
+	value := func(key string) string {
+		if v, ok := settings[key]; ok {
+			return v[0]
+		}
+		return defaults[key]
+	}
+
+	var findings []Finding
+
+	rootLogin := value("permitrootlogin")
+	findings = append(findings, Finding{
+		Setting:  "PermitRootLogin",
+		Value:    rootLogin,
+		Expected: "no",
+		OK:       rootLogin == "no",
+	})
+
+	passwordAuth := value("passwordauthentication")
+	findings = append(findings, Finding{
+		Setting:  "PasswordAuthentication",
+		Value:    passwordAuth,
+		Expected: "no",
+		OK:       passwordAuth == "no",
+	})
+
+	maxTries := value("maxauthtries")
+	n, err := strconv.Atoi(maxTries)
+	findings = append(findings, Finding{
+		Setting:  "MaxAuthTries",
+		Value:    maxTries,
+		Expected: "4 or less",
+		OK:       err == nil && n <= 4,
+	})
+
+	if _, ok := settings["protocol"]; ok {
+		protocol := value("protocol")
+		findings = append(findings, Finding{
+			Setting:  "Protocol",
+			Value:    protocol,
+			Expected: "2",
+			OK:       protocol == "2",
+		})
+	}
+
+	return findings, nil
+}
+
+func SSHDPorts(path string) ([]int, error) {
+	settings := make(map[string][]string)
+	if err := parseSSHDConfig(path, filepath.Dir(path), settings, 0); err != nil {
+		return nil, err
+	}
+
+	var ports []int
+	for _, v := range settings["port"] {
+		if port, err := strconv.Atoi(v); err == nil {
+			ports = append(ports, port)
+		}
+	}
+	if len(ports) == 0 {
+		ports = append(ports, 22)
+	}
+	return ports, nil
+}
+
+// parseSSHDConfig collects every value in file order; sshd uses the first one
+// for most keywords. It stops at the first Match block since its settings are
+// conditional.
+func parseSSHDConfig(path, baseDir string, settings map[string][]string, depth int) error {
+	if depth > 8 {
+		return fmt.Errorf("too many nested includes at %s", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value := splitDirective(line)
+		key = strings.ToLower(key)
+
+		switch key {
+		case "match":
+			return nil
+		case "include":
+			for _, pattern := range strings.Fields(value) {
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(baseDir, pattern)
+				}
+				matches, err := filepath.Glob(pattern)
+				if err != nil {
+					return err
+				}
+				for _, m := range matches {
+					if err := parseSSHDConfig(m, baseDir, settings, depth+1); err != nil {
+						return err
+					}
+				}
+			}
+		default:
+			settings[key] = append(settings[key], strings.ToLower(strings.Trim(value, `"`)))
+		}
+	}
+	return scanner.Err()
+}
+
+func splitDirective(line string) (string, string) {
+	if i := strings.IndexAny(line, " \t="); i >= 0 {
+		key := line[:i]
+		value := strings.TrimLeft(line[i:], " \t=")
+		return key, strings.TrimSpace(value)
+	}
+	return line, ""
+}
@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditSSHDConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sshd_config.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	main := "Include sshd_config.d/*.conf\nPermitRootLogin yes\nPasswordAuthentication yes\n\nMatch User backup\n  PasswordAuthentication no\n"
+	if err := os.WriteFile(filepath.Join(dir, "sshd_config"), []byte(main), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dropIn := "# hardening\nPasswordAuthentication=no\nMaxAuthTries 3\n"
+	if err := os.WriteFile(filepath.Join(dir, "sshd_config.d", "10-hardening.conf"), []byte(dropIn), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	findings, err := AuditSSHDConfig(filepath.Join(dir, "sshd_config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]Finding)
+	for _, f := range findings {
+		got[f.Setting] = f
+	}
+
+	if f := got["PermitRootLogin"]; f.OK || f.Value != "yes" {
+		t.Errorf("expected failing PermitRootLogin yes, got %+v", f)
+	}
+	if f := got["PasswordAuthentication"]; !f.OK || f.Value != "no" {
+		t.Errorf("expected drop-in PasswordAuthentication no to win, got %+v", f)
+	}
+	if f := got["MaxAuthTries"]; !f.OK || f.Value != "3" {
+		t.Errorf("expected MaxAuthTries 3, got %+v", f)
+	}
+	if _, ok := got["Protocol"]; ok {
+		t.Error("expected no Protocol finding when unset")
+	}
+}
+
+func TestAuditSSHDConfigDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sshd_config")
+	if err := os.WriteFile(path, []byte("Port 22\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	findings, err := AuditSSHDConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range findings {
+		if f.OK {
+			t.Errorf("expected OpenSSH default for %s to fail the baseline, got %+v", f.Setting, f)
+		}
+	}
+}
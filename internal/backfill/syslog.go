@@ -0,0 +1,108 @@
+package backfill
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+	"github.com/oxisoft/oxiwatch/internal/parser"
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+type Result struct {
+	Lines      int
+	Events     int
+	Duplicates int
+	FirstYear  int
+	LastYear   int
+}
+
+// StartYear determines the year of the first line of a syslog file. The last
+// line is dated relative to the file's modification time and the year
+// rollovers in between are counted back from there.
+func StartYear(path string) (int, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	tracker := parser.NewYearTracker(0)
+	var lastMonth time.Month
+	var lastDay int
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		month, day, ok := parser.SyslogDate(scanner.Text())
+		if !ok {
+			continue
+		}
+		tracker.Year(month)
+		lastMonth, lastDay = month, day
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if lastMonth == 0 {
+		return 0, fmt.Errorf("no syslog timestamps found in %s", path)
+	}
+
+	endYear := parser.InferYear(lastMonth, lastDay, info.ModTime())
+	return endYear - tracker.Current(), nil
+}
+
+func Import(path string, startYear int, store storage.Store, geo *geoip.Resolver) (*Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := &Result{FirstYear: startYear}
+	tracker := parser.NewYearTracker(startYear)
+	occurrences := parser.NewOccurrenceTracker()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		result.Lines++
+
+		month, _, ok := parser.SyslogDate(line)
+		if !ok {
+			continue
+		}
+		year := tracker.Year(month)
+
+		event := parser.ParseLine(line, year)
+		if event == nil {
+			continue
+		}
+		occurrences.Mark(event)
+
+		var loc geoip.Location
+		if geo != nil {
+			if found, err := geo.Lookup(event.IP); err == nil && found != nil {
+				loc = *found
+			}
+		}
+
+		inserted, err := store.InsertEvent(event, loc)
+		if err != nil {
+			return result, fmt.Errorf("line %d: %w", result.Lines, err)
+		}
+		if !inserted {
+			result.Duplicates++
+			continue
+		}
+		result.Events++
+	}
+	result.LastYear = tracker.Current()
+	return result, scanner.Err()
+}
@@ -0,0 +1,47 @@
+// Package clock lets time-dependent code run on a fake clock, so tests and
+// simulations are deterministic and can fast-forward.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the real wall clock.
+type System struct{}
+
+func (System) Now() time.Time {
+	return time.Now()
+}
+
+// Fake only moves when told to. Its times carry no monotonic reading.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now.Round(0)}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now.Round(0)
+}
@@ -3,43 +3,163 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net/netip"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/parser"
 )
 
 const (
 	DefaultConfigPath   = "/etc/oxiwatch/config.json"
 	DefaultDatabasePath = "/var/lib/oxiwatch/oxiwatch.db"
 	DefaultGeoIPPath    = "/var/lib/oxiwatch/dbip-city-lite.mmdb"
+	DefaultASNPath      = "/var/lib/oxiwatch/dbip-asn-lite.mmdb"
+	DefaultSSHDConfig   = "/etc/ssh/sshd_config"
 )
 
+// Performance profiles. The high-volume profile trades per-attempt detail
+// for throughput on hosts that see millions of failed attempts a day.
+const (
+	ProfileDefault    = "default"
+	ProfileHighVolume = "high-volume"
+)
+
+// LoginAlertFieldNames lists the fields that login_alert_fields may select.
+var LoginAlertFieldNames = []string{"user", "time", "method", "ip", "port", "location", "asn", "fingerprint", "history"}
+
 type Config struct {
-	TelegramBotToken    string `json:"telegram_bot_token"`
-	TelegramChatID      string `json:"telegram_chat_id"`
-	ServerName          string `json:"server_name"`
-	GeoIPEnabled        bool   `json:"geoip_enabled"`
-	GeoIPDatabasePath   string `json:"geoip_database_path"`
-	DatabasePath        string `json:"database_path"`
-	DailyReportEnabled  bool   `json:"daily_report_enabled"`
-	DailyReportTime     string `json:"daily_report_time"`
-	DailyReportTimezone string `json:"daily_report_timezone"`
-	RetentionDays       int    `json:"retention_days"`
-	LogLevel            string `json:"log_level"`
+	TelegramBotToken             string         `json:"telegram_bot_token"`
+	TelegramChatID               string         `json:"telegram_chat_id"`
+	ServerName                   string         `json:"server_name"`
+	Metadata                     ServerMetadata `json:"server_metadata"`
+	GeoIPEnabled                 bool           `json:"geoip_enabled"`
+	GeoIPDatabasePath            string         `json:"geoip_database_path"`
+	ASNEnabled                   bool           `json:"asn_enabled"`
+	ASNDatabasePath              string         `json:"asn_database_path"`
+	GeoIPCompareDatabasePath     string         `json:"geoip_compare_database_path"`
+	GeoIPCompareDays             int            `json:"geoip_compare_days"`
+	DatabasePath                 string         `json:"database_path"`
+	DailyReportEnabled           bool           `json:"daily_report_enabled"`
+	DailyReportTime              string         `json:"daily_report_time"`
+	DailyReportTimezone          string         `json:"daily_report_timezone"`
+	DailyReportTemplate          string         `json:"daily_report_template"`
+	RetentionDays                int            `json:"retention_days"`
+	LogLevel                     string         `json:"log_level"`
+	SambaEnabled                 bool           `json:"samba_enabled"`
+	MFAExpected                  bool           `json:"mfa_expected"`
+	SSHDAuditEnabled             bool           `json:"sshd_audit_enabled"`
+	SSHDAuditWeekday             string         `json:"sshd_audit_weekday"`
+	SSHDConfigPath               string         `json:"sshd_config_path"`
+	ExposureCheckEnabled         bool           `json:"exposure_check_enabled"`
+	ExposureCheckIntervalMinutes int            `json:"exposure_check_interval_minutes"`
+	AdvisoryCheckEnabled         bool           `json:"advisory_check_enabled"`
+	RebootAlertEnabled           bool           `json:"reboot_alert_enabled"`
+	WhatsNewEnabled              bool           `json:"whats_new_enabled"`
+	DiskMinFreeMB                int            `json:"disk_min_free_mb"`
+	ClockJumpThresholdSeconds    int            `json:"clock_jump_threshold_seconds"`
+	SpikeAlertMultiplier         int            `json:"spike_alert_multiplier"`
+	SpikeAlertMinAttempts        int            `json:"spike_alert_min_attempts"`
+	SpikeBaselineDays            int            `json:"spike_baseline_days"`
+	CampaignMinIPs               int            `json:"campaign_min_ips"`
+	CampaignWindowMinutes        int            `json:"campaign_window_minutes"`
+	MemoryLimitMB                int            `json:"memory_limit_mb"`
+	GoroutineLimit               int            `json:"goroutine_limit"`
+	JournaldAlertsEnabled        bool           `json:"journald_alerts_enabled"`
+	EventLogPath                 string         `json:"event_log_path"`
+	EventLogMaxSizeMB            int            `json:"event_log_max_size_mb"`
+	EventLogMaxFiles             int            `json:"event_log_max_files"`
+	ForwardAddress               string         `json:"forward_address"`
+	ForwardTag                   string         `json:"forward_tag"`
+	ForwardBufferEvents          int            `json:"forward_buffer_events"`
+	PerformanceProfile           string         `json:"performance_profile"`
+	LogSources                   []LogSource    `json:"log_sources"`
+	WebFailureThreshold          int            `json:"web_failure_threshold"`
+	WebAdminLoginThreshold       int            `json:"web_admin_login_threshold"`
+	WebBurstWindowMinutes        int            `json:"web_burst_window_minutes"`
+	AlertEditInPlace             bool           `json:"alert_edit_in_place"`
+	LoginAlertFields             []string       `json:"login_alert_fields"`
+	NewKeyAlertEnabled           bool           `json:"new_key_alert_enabled"`
+	TrustedNetworks              []string       `json:"trusted_networks"`
+	LearningDays                 int            `json:"learning_days"`
+	Theme                        string         `json:"theme"`
+	PinnedStatusEnabled          bool           `json:"pinned_status_enabled"`
+	WallOfShameEnabled           bool           `json:"wall_of_shame_enabled"`
+	WallOfShameWeekday           string         `json:"wall_of_shame_weekday"`
+}
+
+// ServerMetadata is shown with every alert and report so on-call responders
+// know what the server is and where its runbook lives.
+type ServerMetadata struct {
+	Environment string `json:"environment,omitempty"`
+	Datacenter  string `json:"datacenter,omitempty"`
+	OwnerTeam   string `json:"owner_team,omitempty"`
+	RunbookURL  string `json:"runbook_url,omitempty"`
+}
+
+func (m ServerMetadata) Labels() []string {
+	var labels []string
+	if m.Environment != "" {
+		labels = append(labels, "Env: "+m.Environment)
+	}
+	if m.Datacenter != "" {
+		labels = append(labels, "DC: "+m.Datacenter)
+	}
+	if m.OwnerTeam != "" {
+		labels = append(labels, "Team: "+m.OwnerTeam)
+	}
+	return labels
+}
+
+type LogSource struct {
+	Path   string `json:"path"`
+	Format string `json:"format"`
 }
 
 func DefaultConfig() *Config {
 	hostname, _ := os.Hostname()
 	return &Config{
-		ServerName:          hostname,
-		GeoIPEnabled:        true,
-		GeoIPDatabasePath:   DefaultGeoIPPath,
-		DatabasePath:        DefaultDatabasePath,
-		DailyReportEnabled:  true,
-		DailyReportTime:     "08:00",
-		DailyReportTimezone: "UTC",
-		RetentionDays:       90,
-		LogLevel:            "info",
+		ServerName:                   hostname,
+		GeoIPEnabled:                 true,
+		GeoIPDatabasePath:            DefaultGeoIPPath,
+		ASNDatabasePath:              DefaultASNPath,
+		GeoIPCompareDays:             14,
+		DatabasePath:                 DefaultDatabasePath,
+		DailyReportEnabled:           true,
+		DailyReportTime:              "08:00",
+		DailyReportTimezone:          "UTC",
+		RetentionDays:                90,
+		LogLevel:                     "info",
+		SSHDAuditEnabled:             true,
+		SSHDAuditWeekday:             "monday",
+		SSHDConfigPath:               DefaultSSHDConfig,
+		ExposureCheckEnabled:         true,
+		ExposureCheckIntervalMinutes: 60,
+		RebootAlertEnabled:           true,
+		WhatsNewEnabled:              true,
+		DiskMinFreeMB:                512,
+		ClockJumpThresholdSeconds:    120,
+		SpikeAlertMultiplier:         10,
+		SpikeAlertMinAttempts:        100,
+		SpikeBaselineDays:            7,
+		CampaignMinIPs:               3,
+		CampaignWindowMinutes:        60,
+		EventLogMaxSizeMB:            100,
+		EventLogMaxFiles:             5,
+		ForwardTag:                   "oxiwatch.event",
+		ForwardBufferEvents:          10000,
+		PerformanceProfile:           ProfileDefault,
+		WebFailureThreshold:          20,
+		WebAdminLoginThreshold:       5,
+		WebBurstWindowMinutes:        10,
+		AlertEditInPlace:             true,
+		LoginAlertFields:             []string{"user", "time", "method", "ip", "location", "history"},
+		NewKeyAlertEnabled:           true,
+		Theme:                        "emoji",
+		WallOfShameWeekday:           "sunday",
 	}
 }
 
@@ -83,12 +203,38 @@ func applyEnvOverrides(cfg *Config) {
 	if v := os.Getenv("OXIWATCH_SERVER_NAME"); v != "" {
 		cfg.ServerName = v
 	}
+	if v := os.Getenv("OXIWATCH_ENVIRONMENT"); v != "" {
+		cfg.Metadata.Environment = v
+	}
+	if v := os.Getenv("OXIWATCH_DATACENTER"); v != "" {
+		cfg.Metadata.Datacenter = v
+	}
+	if v := os.Getenv("OXIWATCH_OWNER_TEAM"); v != "" {
+		cfg.Metadata.OwnerTeam = v
+	}
+	if v := os.Getenv("OXIWATCH_RUNBOOK_URL"); v != "" {
+		cfg.Metadata.RunbookURL = v
+	}
 	if v := os.Getenv("OXIWATCH_GEOIP_ENABLED"); v != "" {
 		cfg.GeoIPEnabled = strings.ToLower(v) == "true" || v == "1"
 	}
 	if v := os.Getenv("OXIWATCH_GEOIP_DATABASE_PATH"); v != "" {
 		cfg.GeoIPDatabasePath = v
 	}
+	if v := os.Getenv("OXIWATCH_ASN_ENABLED"); v != "" {
+		cfg.ASNEnabled = strings.ToLower(v) == "true" || v == "1"
+	}
+	if v := os.Getenv("OXIWATCH_ASN_DATABASE_PATH"); v != "" {
+		cfg.ASNDatabasePath = v
+	}
+	if v := os.Getenv("OXIWATCH_GEOIP_COMPARE_DATABASE_PATH"); v != "" {
+		cfg.GeoIPCompareDatabasePath = v
+	}
+	if v := os.Getenv("OXIWATCH_GEOIP_COMPARE_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.GeoIPCompareDays = n
+		}
+	}
 	if v := os.Getenv("OXIWATCH_DATABASE_PATH"); v != "" {
 		cfg.DatabasePath = v
 	}
@@ -101,6 +247,9 @@ func applyEnvOverrides(cfg *Config) {
 	if v := os.Getenv("OXIWATCH_DAILY_REPORT_TIMEZONE"); v != "" {
 		cfg.DailyReportTimezone = v
 	}
+	if v := os.Getenv("OXIWATCH_DAILY_REPORT_TEMPLATE"); v != "" {
+		cfg.DailyReportTemplate = v
+	}
 	if v := os.Getenv("OXIWATCH_RETENTION_DAYS"); v != "" {
 		if days, err := strconv.Atoi(v); err == nil {
 			cfg.RetentionDays = days
@@ -109,6 +258,163 @@ func applyEnvOverrides(cfg *Config) {
 	if v := os.Getenv("OXIWATCH_LOG_LEVEL"); v != "" {
 		cfg.LogLevel = v
 	}
+	if v := os.Getenv("OXIWATCH_SAMBA_ENABLED"); v != "" {
+		cfg.SambaEnabled = strings.ToLower(v) == "true" || v == "1"
+	}
+	if v := os.Getenv("OXIWATCH_MFA_EXPECTED"); v != "" {
+		cfg.MFAExpected = strings.ToLower(v) == "true" || v == "1"
+	}
+	if v := os.Getenv("OXIWATCH_SSHD_AUDIT_ENABLED"); v != "" {
+		cfg.SSHDAuditEnabled = strings.ToLower(v) == "true" || v == "1"
+	}
+	if v := os.Getenv("OXIWATCH_SSHD_AUDIT_WEEKDAY"); v != "" {
+		cfg.SSHDAuditWeekday = v
+	}
+	if v := os.Getenv("OXIWATCH_SSHD_CONFIG_PATH"); v != "" {
+		cfg.SSHDConfigPath = v
+	}
+	if v := os.Getenv("OXIWATCH_EXPOSURE_CHECK_ENABLED"); v != "" {
+		cfg.ExposureCheckEnabled = strings.ToLower(v) == "true" || v == "1"
+	}
+	if v := os.Getenv("OXIWATCH_EXPOSURE_CHECK_INTERVAL_MINUTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.ExposureCheckIntervalMinutes = n
+		}
+	}
+	if v := os.Getenv("OXIWATCH_ADVISORY_CHECK_ENABLED"); v != "" {
+		cfg.AdvisoryCheckEnabled = strings.ToLower(v) == "true" || v == "1"
+	}
+	if v := os.Getenv("OXIWATCH_REBOOT_ALERT_ENABLED"); v != "" {
+		cfg.RebootAlertEnabled = strings.ToLower(v) == "true" || v == "1"
+	}
+	if v := os.Getenv("OXIWATCH_WHATS_NEW_ENABLED"); v != "" {
+		cfg.WhatsNewEnabled = strings.ToLower(v) == "true" || v == "1"
+	}
+	if v := os.Getenv("OXIWATCH_DISK_MIN_FREE_MB"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.DiskMinFreeMB = n
+		}
+	}
+	if v := os.Getenv("OXIWATCH_CLOCK_JUMP_THRESHOLD_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.ClockJumpThresholdSeconds = n
+		}
+	}
+	if v := os.Getenv("OXIWATCH_SPIKE_ALERT_MULTIPLIER"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.SpikeAlertMultiplier = n
+		}
+	}
+	if v := os.Getenv("OXIWATCH_SPIKE_ALERT_MIN_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.SpikeAlertMinAttempts = n
+		}
+	}
+	if v := os.Getenv("OXIWATCH_SPIKE_BASELINE_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.SpikeBaselineDays = n
+		}
+	}
+	if v := os.Getenv("OXIWATCH_CAMPAIGN_MIN_IPS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.CampaignMinIPs = n
+		}
+	}
+	if v := os.Getenv("OXIWATCH_CAMPAIGN_WINDOW_MINUTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.CampaignWindowMinutes = n
+		}
+	}
+	if v := os.Getenv("OXIWATCH_MEMORY_LIMIT_MB"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.MemoryLimitMB = n
+		}
+	}
+	if v := os.Getenv("OXIWATCH_GOROUTINE_LIMIT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.GoroutineLimit = n
+		}
+	}
+	if v := os.Getenv("OXIWATCH_JOURNALD_ALERTS_ENABLED"); v != "" {
+		cfg.JournaldAlertsEnabled = strings.ToLower(v) == "true" || v == "1"
+	}
+	if v := os.Getenv("OXIWATCH_EVENT_LOG_PATH"); v != "" {
+		cfg.EventLogPath = v
+	}
+	if v := os.Getenv("OXIWATCH_EVENT_LOG_MAX_SIZE_MB"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.EventLogMaxSizeMB = n
+		}
+	}
+	if v := os.Getenv("OXIWATCH_EVENT_LOG_MAX_FILES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.EventLogMaxFiles = n
+		}
+	}
+	if v := os.Getenv("OXIWATCH_FORWARD_ADDRESS"); v != "" {
+		cfg.ForwardAddress = v
+	}
+	if v := os.Getenv("OXIWATCH_FORWARD_TAG"); v != "" {
+		cfg.ForwardTag = v
+	}
+	if v := os.Getenv("OXIWATCH_FORWARD_BUFFER_EVENTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.ForwardBufferEvents = n
+		}
+	}
+	if v := os.Getenv("OXIWATCH_PERFORMANCE_PROFILE"); v != "" {
+		cfg.PerformanceProfile = v
+	}
+	if v := os.Getenv("OXIWATCH_WEB_FAILURE_THRESHOLD"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.WebFailureThreshold = n
+		}
+	}
+	if v := os.Getenv("OXIWATCH_WEB_ADMIN_LOGIN_THRESHOLD"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.WebAdminLoginThreshold = n
+		}
+	}
+	if v := os.Getenv("OXIWATCH_WEB_BURST_WINDOW_MINUTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.WebBurstWindowMinutes = n
+		}
+	}
+	if v := os.Getenv("OXIWATCH_ALERT_EDIT_IN_PLACE"); v != "" {
+		cfg.AlertEditInPlace = strings.ToLower(v) == "true" || v == "1"
+	}
+	if v := os.Getenv("OXIWATCH_LOGIN_ALERT_FIELDS"); v != "" {
+		cfg.LoginAlertFields = strings.Split(v, ",")
+		for i := range cfg.LoginAlertFields {
+			cfg.LoginAlertFields[i] = strings.TrimSpace(cfg.LoginAlertFields[i])
+		}
+	}
+	if v := os.Getenv("OXIWATCH_NEW_KEY_ALERT_ENABLED"); v != "" {
+		cfg.NewKeyAlertEnabled = strings.ToLower(v) == "true" || v == "1"
+	}
+	if v := os.Getenv("OXIWATCH_TRUSTED_NETWORKS"); v != "" {
+		cfg.TrustedNetworks = strings.Split(v, ",")
+		for i := range cfg.TrustedNetworks {
+			cfg.TrustedNetworks[i] = strings.TrimSpace(cfg.TrustedNetworks[i])
+		}
+	}
+	if v := os.Getenv("OXIWATCH_LEARNING_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.LearningDays = n
+		}
+	}
+	if v := os.Getenv("OXIWATCH_THEME"); v != "" {
+		cfg.Theme = v
+	}
+	if v := os.Getenv("OXIWATCH_PINNED_STATUS_ENABLED"); v != "" {
+		cfg.PinnedStatusEnabled = strings.ToLower(v) == "true" || v == "1"
+	}
+	if v := os.Getenv("OXIWATCH_WALL_OF_SHAME_ENABLED"); v != "" {
+		cfg.WallOfShameEnabled = strings.ToLower(v) == "true" || v == "1"
+	}
+	if v := os.Getenv("OXIWATCH_WALL_OF_SHAME_WEEKDAY"); v != "" {
+		cfg.WallOfShameWeekday = v
+	}
 }
 
 func (c *Config) Validate() error {
@@ -124,9 +430,144 @@ func (c *Config) Validate() error {
 	if c.RetentionDays < 1 {
 		return fmt.Errorf("retention_days must be at least 1")
 	}
+	if u := c.Metadata.RunbookURL; u != "" && !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+		return fmt.Errorf("server_metadata.runbook_url must be an http(s) URL")
+	}
+	for i, src := range c.LogSources {
+		if src.Path == "" {
+			return fmt.Errorf("log_sources[%d]: path is required", i)
+		}
+		if !parser.IsLogFormat(src.Format) {
+			return fmt.Errorf("log_sources[%d]: unknown format %q", i, src.Format)
+		}
+	}
+	if c.SSHDAuditEnabled {
+		if _, err := c.AuditWeekday(); err != nil {
+			return err
+		}
+	}
+	if c.WallOfShameEnabled {
+		if _, err := c.WallOfShameDay(); err != nil {
+			return err
+		}
+	}
+	if c.ExposureCheckEnabled && c.ExposureCheckIntervalMinutes < 1 {
+		return fmt.Errorf("exposure_check_interval_minutes must be at least 1")
+	}
+	if c.GeoIPCompareDatabasePath != "" && c.GeoIPCompareDays < 1 {
+		return fmt.Errorf("geoip_compare_days must be at least 1")
+	}
+	if c.DiskMinFreeMB < 0 {
+		return fmt.Errorf("disk_min_free_mb must not be negative")
+	}
+	if c.ClockJumpThresholdSeconds < 0 {
+		return fmt.Errorf("clock_jump_threshold_seconds must not be negative")
+	}
+	if c.SpikeAlertMultiplier < 0 {
+		return fmt.Errorf("spike_alert_multiplier must not be negative")
+	}
+	if c.SpikeAlertMultiplier > 0 && c.SpikeBaselineDays < 1 {
+		return fmt.Errorf("spike_baseline_days must be at least 1")
+	}
+	if c.CampaignMinIPs < 0 || c.CampaignMinIPs == 1 {
+		return fmt.Errorf("campaign_min_ips must be 0 (disabled) or at least 2")
+	}
+	if c.CampaignMinIPs > 0 && c.CampaignWindowMinutes < 1 {
+		return fmt.Errorf("campaign_window_minutes must be at least 1")
+	}
+	if c.MemoryLimitMB < 0 {
+		return fmt.Errorf("memory_limit_mb must not be negative")
+	}
+	if c.GoroutineLimit < 0 {
+		return fmt.Errorf("goroutine_limit must not be negative")
+	}
+	if c.EventLogPath != "" && c.EventLogMaxSizeMB < 1 {
+		return fmt.Errorf("event_log_max_size_mb must be at least 1")
+	}
+	if c.EventLogMaxFiles < 0 {
+		return fmt.Errorf("event_log_max_files must not be negative")
+	}
+	if c.ForwardAddress != "" && c.ForwardTag == "" {
+		return fmt.Errorf("forward_tag is required")
+	}
+	if c.ForwardAddress != "" && c.ForwardBufferEvents < 1 {
+		return fmt.Errorf("forward_buffer_events must be at least 1")
+	}
+	if c.WebBurstWindowMinutes < 1 {
+		return fmt.Errorf("web_burst_window_minutes must be at least 1")
+	}
+	for _, f := range c.LoginAlertFields {
+		if !slices.Contains(LoginAlertFieldNames, f) {
+			return fmt.Errorf("login_alert_fields: unknown field %q", f)
+		}
+	}
+	if _, err := c.TrustedPrefixes(); err != nil {
+		return err
+	}
+	if c.LearningDays < 0 {
+		return fmt.Errorf("learning_days must not be negative")
+	}
+	switch c.Theme {
+	case "emoji", "minimal", "plain":
+	default:
+		return fmt.Errorf("theme must be one of emoji, minimal, plain")
+	}
+	switch c.PerformanceProfile {
+	case ProfileDefault, ProfileHighVolume:
+	default:
+		return fmt.Errorf("performance_profile must be one of %s, %s", ProfileDefault, ProfileHighVolume)
+	}
 	return nil
 }
 
+// TrustedPrefixes parses trusted_networks, which may list single addresses
+// as well as CIDR ranges.
+func (c *Config) TrustedPrefixes() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, n := range c.TrustedNetworks {
+		if addr, err := netip.ParseAddr(n); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(n)
+		if err != nil {
+			return nil, fmt.Errorf("trusted_networks: invalid IP or CIDR range %q", n)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// HighVolume reports whether the high-volume performance profile is on.
+func (c *Config) HighVolume() bool {
+	return c.PerformanceProfile == ProfileHighVolume
+}
+
+func (c *Config) JournalUnits() []string {
+	units := []string{"ssh"}
+	if c.SambaEnabled {
+		units = append(units, "smbd")
+	}
+	return units
+}
+
+func (c *Config) AuditWeekday() (time.Weekday, error) {
+	return parseWeekday("sshd_audit_weekday", c.SSHDAuditWeekday)
+}
+
+func (c *Config) WallOfShameDay() (time.Weekday, error) {
+	return parseWeekday("wall_of_shame_weekday", c.WallOfShameWeekday)
+}
+
+func parseWeekday(field, value string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), value) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("%s: unknown weekday %q", field, value)
+}
+
 func (c *Config) String() string {
 	data, _ := json.MarshalIndent(c, "", "  ")
 	return string(data)
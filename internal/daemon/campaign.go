@@ -0,0 +1,111 @@
+package daemon
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/detect"
+	"github.com/oxisoft/oxiwatch/internal/notifier"
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+const campaignCheckInterval = 5 * time.Minute
+
+// checkCampaigns clusters the failed attempts of the last window into
+// campaigns, tags their events and alerts once per new campaign. A cluster
+// continues a campaign with the same network and usernames that was active
+// within the window before it.
+func (d *Daemon) checkCampaigns(ctx context.Context) error {
+	now := d.clock.Now()
+	window := time.Duration(d.cfg.CampaignWindowMinutes) * time.Minute
+	since := now.Add(-window)
+
+	attempts, err := d.storage.GetIPUserAttempts(since, now)
+	if err != nil {
+		return err
+	}
+	clusters := detect.FindCampaigns(attempts, d.cfg.CampaignMinIPs)
+	if len(clusters) == 0 {
+		return nil
+	}
+	known, err := d.storage.GetCampaigns(since.Add(-window))
+	if err != nil {
+		return err
+	}
+
+	for _, cluster := range clusters {
+		campaign, ok := continuedCampaign(known, cluster, window)
+		if !ok {
+			campaign = storage.Campaign{
+				ID:        campaignID(cluster),
+				ASN:       cluster.ASN,
+				Usernames: cluster.Usernames,
+				FirstSeen: cluster.First,
+			}
+		}
+		campaign.ASOrg = max(campaign.ASOrg, cluster.ASOrg)
+		for _, ip := range cluster.IPs {
+			if !slices.Contains(campaign.IPs, ip) {
+				campaign.IPs = append(campaign.IPs, ip)
+			}
+		}
+		slices.Sort(campaign.IPs)
+		if cluster.Last.After(campaign.LastSeen) {
+			campaign.LastSeen = cluster.Last
+		}
+
+		tagged, err := d.storage.TagCampaignEvents(campaign.ID, cluster.ASN, cluster.IPs, since, now)
+		if err != nil {
+			return err
+		}
+		campaign.Attempts += int(tagged)
+		if err := d.storage.SaveCampaign(campaign); err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+
+		d.logger.Warn("attack campaign detected", "campaign", campaign.ID, "asn", campaign.ASN, "ips", len(campaign.IPs), "usernames", strings.Join(campaign.Usernames, ","))
+		if d.learning() {
+			continue
+		}
+		d.emit("campaign", notifier.SeverityWarning, fmt.Sprintf("Attack campaign %s: %d IPs in AS%d tried %s, %d attempts",
+			campaign.ID, len(campaign.IPs), campaign.ASN, strings.Join(campaign.Usernames, ", "), campaign.Attempts), map[string]string{
+			"campaign":  campaign.ID,
+			"asn":       strconv.FormatUint(uint64(campaign.ASN), 10),
+			"as_org":    campaign.ASOrg,
+			"ips":       strings.Join(campaign.IPs, ","),
+			"usernames": strings.Join(campaign.Usernames, ","),
+			"count":     strconv.Itoa(campaign.Attempts),
+		})
+		if err := d.telegram.SendCampaignAlert(campaign); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// continuedCampaign finds the campaign that cluster continues: same network
+// and usernames, last seen no more than window before the cluster starts.
+func continuedCampaign(known []storage.Campaign, cluster detect.Cluster, window time.Duration) (storage.Campaign, bool) {
+	for _, c := range known {
+		if c.ASN == cluster.ASN && slices.Equal(c.Usernames, cluster.Usernames) && !c.LastSeen.Before(cluster.First.Add(-window)) {
+			return c, true
+		}
+	}
+	return storage.Campaign{}, false
+}
+
+// campaignID derives a short, stable ID from the cluster's network,
+// usernames and start.
+func campaignID(cluster detect.Cluster) string {
+	sum := sha256.Sum256([]byte(cluster.Key() + "|" + strconv.FormatInt(cluster.First.Unix(), 10)))
+	return "c-" + hex.EncodeToString(sum[:4])
+}
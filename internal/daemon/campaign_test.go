@@ -0,0 +1,75 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/fixture"
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+)
+
+func TestCampaignAlert(t *testing.T) {
+	h := newHarness(t)
+	d := h.daemon()
+	ctx := context.Background()
+
+	hosting := geoip.Location{ASN: 64500, ASOrg: "Example Hosting"}
+	fail := func(user, ip string, loc geoip.Location, ago time.Duration) {
+		t.Helper()
+		if _, err := h.store.InsertEvent(fixture.Failure(user, ip).Ago(ago).Build(), loc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Three IPs in one network try root and admin. Another tries only
+	// root, and two in a different network the same list.
+	for i := 1; i <= 3; i++ {
+		fail("root", fmt.Sprintf("203.0.113.%d", i), hosting, time.Duration(i)*time.Minute)
+		fail("admin", fmt.Sprintf("203.0.113.%d", i), hosting, time.Duration(i)*time.Minute)
+	}
+	fail("root", "203.0.113.50", hosting, time.Minute)
+	for i := 1; i <= 2; i++ {
+		other := geoip.Location{ASN: 64501}
+		fail("root", fmt.Sprintf("198.51.100.%d", i), other, time.Minute)
+		fail("admin", fmt.Sprintf("198.51.100.%d", i), other, time.Minute)
+	}
+
+	if err := d.checkCampaigns(ctx); err != nil {
+		t.Fatal(err)
+	}
+	alerts := h.alerts()
+	if len(alerts) != 1 {
+		t.Fatalf("expected one campaign alert, got %d", len(alerts))
+	}
+	for _, want := range []string{"Attack Campaign", "AS64500 Example Hosting", "IPs: 3 (203.0.113.1, 203.0.113.2, 203.0.113.3)", "Usernames: admin, root", "Attempts: 6"} {
+		if !strings.Contains(alerts[0].Text, want) {
+			t.Errorf("expected %q in alert:\n%s", want, alerts[0].Text)
+		}
+	}
+	if sinkAlerts := h.sink.Alerts(); len(sinkAlerts) != 1 || sinkAlerts[0].Kind != "campaign" {
+		t.Errorf("expected a structured campaign alert, got %+v", sinkAlerts)
+	}
+
+	// A fourth IP joins the same campaign without another alert.
+	fail("root", "203.0.113.4", hosting, 0)
+	fail("admin", "203.0.113.4", hosting, 0)
+	if err := d.checkCampaigns(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if alerts := h.alerts(); len(alerts) != 1 {
+		t.Fatalf("expected no alert for a continued campaign, got %d", len(alerts))
+	}
+
+	campaigns, err := h.store.GetCampaigns(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(campaigns) != 1 || len(campaigns[0].IPs) != 4 || campaigns[0].Attempts != 8 {
+		t.Fatalf("unexpected campaigns: %+v", campaigns)
+	}
+	if !strings.Contains(alerts[0].Text, campaigns[0].ID) {
+		t.Errorf("expected campaign ID %s in the alert", campaigns[0].ID)
+	}
+}
@@ -4,32 +4,89 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net/netip"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/oxisoft/oxiwatch/internal/audit"
+	"github.com/oxisoft/oxiwatch/internal/clock"
 	"github.com/oxisoft/oxiwatch/internal/config"
+	"github.com/oxisoft/oxiwatch/internal/detect"
 	"github.com/oxisoft/oxiwatch/internal/geoip"
+	"github.com/oxisoft/oxiwatch/internal/host"
 	"github.com/oxisoft/oxiwatch/internal/journal"
+	"github.com/oxisoft/oxiwatch/internal/logfile"
+	"github.com/oxisoft/oxiwatch/internal/netinfo"
 	"github.com/oxisoft/oxiwatch/internal/notifier"
+	"github.com/oxisoft/oxiwatch/internal/output"
 	"github.com/oxisoft/oxiwatch/internal/parser"
 	"github.com/oxisoft/oxiwatch/internal/report"
 	"github.com/oxisoft/oxiwatch/internal/scheduler"
 	"github.com/oxisoft/oxiwatch/internal/storage"
+	"github.com/oxisoft/oxiwatch/internal/version"
+)
+
+const (
+	stateBootID        = "boot_id"
+	stateStatusMessage = "status_message_id"
+	stateStatusDate    = "status_message_date"
+	stateCompareStart  = "geoip_compare_started"
+	stateJournalCursor = "journal_cursor"
+	stateLastCheck     = "scheduler_last_check"
+)
+
+const (
+	// drainTimeout bounds how long shutdown waits for already read log
+	// lines to be handled, well below systemd's default stop timeout.
+	drainTimeout = 15 * time.Second
+	// maxResumeGap is the longest downtime after which the journal and the
+	// scheduler pick up where they stopped. After longer outages replaying
+	// old logins and reports would be noise, so they start from now.
+	maxResumeGap = time.Hour
 )
 
 type Daemon struct {
-	cfg       *config.Config
-	logger    *slog.Logger
-	storage   *storage.Storage
-	journal   *journal.Reader
-	telegram  *notifier.Telegram
-	scheduler *scheduler.Scheduler
-	geoip     *geoip.Resolver
-	geoUpdate *geoip.Updater
-	report    *report.Generator
-	version   string
+	cfg           *config.Config
+	logger        *slog.Logger
+	clock         clock.Clock
+	storage       storage.Store
+	journal       *journal.Reader
+	logReaders    []*logfile.Reader
+	logEvents     chan *parser.SSHEvent
+	telegram      *notifier.Telegram
+	sinks         []notifier.Sink
+	outputs       []output.Sink
+	scheduler     *scheduler.Scheduler
+	geoip         *geoip.Resolver
+	geoUpdate     *geoip.Updater
+	asnUpdate     *geoip.Updater
+	geoCompare    *geoip.Resolver
+	compareEnd    time.Time
+	report        *report.Generator
+	webBursts     *detect.BurstTracker
+	adminBursts   *detect.BurstTracker
+	attacks       *detect.AttackTracker
+	factors       *detect.FactorTracker
+	pendingSSSD   *detect.PendingEvents
+	pendingMFA    *detect.PendingEvents
+	failures      *failureBatch
+	exposure      string
+	trusted       atomic.Pointer[[]netip.Prefix]
+	learningStart time.Time
+	learningEnd   time.Time
+	lowDisk       atomic.Bool
+	spiking       atomic.Bool
+	throttled     atomic.Bool
+	version       string
+	releaseNotes  func(version string) (*version.Release, error)
+	started       time.Time
+	lastEvent     time.Time
+	events        int
 }
 
 func New(cfg *config.Config, logger *slog.Logger, version string) (*Daemon, error) {
@@ -38,30 +95,83 @@ func New(cfg *config.Config, logger *slog.Logger, version string) (*Daemon, erro
 		return nil, err
 	}
 
-	telegram, err := notifier.NewTelegram(cfg.TelegramBotToken, cfg.TelegramChatID, cfg.ServerName)
+	telegram, err := notifier.NewTelegram(cfg.TelegramBotToken, cfg.TelegramChatID, cfg.ServerName, cfg.Metadata)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create telegram notifier: %w", err)
 	}
 
+	d := newDaemon(cfg, logger, version, store, telegram, journal.New(logger, cfg.JournalUnits()))
+	if cfg.DailyReportTemplate != "" {
+		if err := d.report.LoadTemplate(cfg.DailyReportTemplate); err != nil {
+			return nil, fmt.Errorf("failed to load daily report template: %w", err)
+		}
+	}
+
+	return d, nil
+}
+
+// newDaemon wires a daemon from its outside dependencies, so tests can pass
+// an in-memory store, a recording notifier and a scripted journal.
+func newDaemon(cfg *config.Config, logger *slog.Logger, version string, store storage.Store, telegram *notifier.Telegram, journal *journal.Reader) *Daemon {
+	telegram.SetLoginAlertFields(cfg.LoginAlertFields)
+	telegram.SetTheme(cfg.Theme)
+
+	burstWindow := time.Duration(cfg.WebBurstWindowMinutes) * time.Minute
+
 	d := &Daemon{
-		cfg:       cfg,
-		logger:    logger,
-		storage:   store,
-		journal:   journal.New(logger),
-		telegram:  telegram,
-		scheduler: scheduler.New(logger),
-		geoUpdate: geoip.NewUpdater(cfg.GeoIPDatabasePath, logger),
-		report:    report.NewGenerator(store, cfg.ServerName, version),
-		version:   version,
+		cfg:         cfg,
+		logger:      logger,
+		clock:       clock.System{},
+		storage:     store,
+		journal:     journal,
+		logEvents:   make(chan *parser.SSHEvent, 100),
+		telegram:    telegram,
+		scheduler:   scheduler.New(logger),
+		geoUpdate:   geoip.NewUpdater(cfg.GeoIPDatabasePath, logger),
+		asnUpdate:   geoip.NewASNUpdater(cfg.ASNDatabasePath, logger),
+		report:      report.NewGenerator(store, cfg.ServerName, cfg.Metadata, version),
+		webBursts:   detect.NewBurstTracker(cfg.WebFailureThreshold, burstWindow),
+		adminBursts: detect.NewBurstTracker(cfg.WebAdminLoginThreshold, burstWindow),
+		attacks:     detect.NewAttackTracker(burstWindow, 30*time.Second),
+		factors:     detect.NewFactorTracker(2 * time.Minute),
+		pendingSSSD: detect.NewPendingEvents(10 * time.Second),
+		pendingMFA:  detect.NewPendingEvents(10 * time.Second),
+		failures:    newFailureBatch(),
+		version:     version,
+	}
+	d.releaseNotes = fetchRelease
+
+	// Validate rejects invalid trusted_networks before the daemon starts.
+	// Approved networks are added by refreshTrusted once it does.
+	trusted, _ := cfg.TrustedPrefixes()
+	d.trusted.Store(&trusted)
+
+	if cfg.JournaldAlertsEnabled {
+		d.sinks = append(d.sinks, notifier.NewJournald(notifier.JournaldSocket))
+	}
+	if cfg.EventLogPath != "" {
+		d.outputs = append(d.outputs, output.NewFile(cfg.EventLogPath, int64(cfg.EventLogMaxSizeMB)<<20, cfg.EventLogMaxFiles))
+	}
+	if cfg.ForwardAddress != "" {
+		d.outputs = append(d.outputs, output.NewForward(cfg.ForwardAddress, cfg.ForwardTag, cfg.ForwardBufferEvents, logger))
+	}
+
+	for _, src := range cfg.LogSources {
+		d.logReaders = append(d.logReaders, logfile.New(logger, src.Path, src.Format))
 	}
 
 	if cfg.GeoIPEnabled {
 		if err := d.initGeoIP(); err != nil {
 			logger.Warn("GeoIP initialization failed, continuing without geo lookup", "error", err)
 		}
+		if cfg.GeoIPCompareDatabasePath != "" && d.geoip != nil {
+			if err := d.initGeoIPCompare(); err != nil {
+				logger.Warn("GeoIP comparison disabled", "error", err)
+			}
+		}
 	}
 
-	return d, nil
+	return d
 }
 
 func (d *Daemon) initGeoIP() error {
@@ -80,110 +190,565 @@ func (d *Daemon) initGeoIP() error {
 		}
 		d.geoip = resolver
 		d.logger.Info("GeoIP database loaded", "path", d.cfg.GeoIPDatabasePath)
+		d.loadASN()
+	}
+
+	return nil
+}
+
+// initGeoIPCompare opens the second database for the comparison mode. The
+// sampling period starts with the first run and survives restarts.
+func (d *Daemon) initGeoIPCompare() error {
+	start := d.clock.Now()
+	value, err := d.storage.GetState(stateCompareStart)
+	if err != nil {
+		return err
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		start = t
+	} else if err := d.storage.SetState(stateCompareStart, start.Format(time.RFC3339)); err != nil {
+		return err
+	}
+
+	d.compareEnd = start.AddDate(0, 0, d.cfg.GeoIPCompareDays)
+	if d.clock.Now().After(d.compareEnd) {
+		d.logger.Info("GeoIP comparison period is over, run 'oxiwatch geoip compare' for the results")
+		return nil
 	}
 
+	resolver, err := geoip.NewResolver(d.cfg.GeoIPCompareDatabasePath)
+	if err != nil {
+		return err
+	}
+	d.geoCompare = resolver
+	d.logger.Info("GeoIP comparison enabled", "path", d.cfg.GeoIPCompareDatabasePath, "until", d.compareEnd.Format("2006-01-02 15:04"))
 	return nil
 }
 
+func (d *Daemon) compareGeoIP(event *parser.SSHEvent, loc geoip.Location) {
+	if d.geoCompare == nil || d.throttled.Load() {
+		return
+	}
+	if d.clock.Now().After(d.compareEnd) {
+		d.logger.Info("GeoIP comparison period is over, run 'oxiwatch geoip compare' for the results")
+		d.geoCompare.Close()
+		d.geoCompare = nil
+		return
+	}
+
+	other, err := d.geoCompare.Lookup(event.IP)
+	if err != nil {
+		d.logger.Debug("GeoIP comparison lookup failed", "ip", event.IP, "error", err)
+		return
+	}
+	if err := d.storage.AddGeoIPComparison(event.IP, event.Timestamp, loc, *other); err != nil {
+		d.logger.Warn("failed to store GeoIP comparison", "error", err)
+	}
+}
+
+func (d *Daemon) loadASN() {
+	if !d.cfg.ASNEnabled || d.geoip == nil {
+		return
+	}
+
+	if !d.asnUpdate.DatabaseExists() {
+		d.logger.Info("ASN database not found, downloading...")
+		if err := d.asnUpdate.Update(); err != nil {
+			d.logger.Warn("failed to download ASN database", "error", err)
+			return
+		}
+	}
+
+	if err := d.geoip.LoadASN(d.cfg.ASNDatabasePath); err != nil {
+		d.logger.Warn("failed to load ASN database", "error", err)
+		return
+	}
+	d.logger.Info("ASN database loaded", "path", d.cfg.ASNDatabasePath)
+}
+
 func (d *Daemon) Run() error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	dumpCh := make(chan os.Signal, 1)
+	signal.Notify(dumpCh, syscall.SIGUSR1)
+	defer signal.Stop(dumpCh)
+	d.started = d.clock.Now()
+
+	d.resumeState()
 
 	if err := d.journal.Start(ctx); err != nil {
 		return err
 	}
-	d.logger.Info("started monitoring SSH journal")
+	d.logger.Info("started monitoring journal", "units", d.cfg.JournalUnits())
 
-	if d.cfg.DailyReportEnabled {
-		if err := d.scheduler.AddDailyTask("daily-report", d.cfg.DailyReportTime, d.cfg.DailyReportTimezone, d.sendDailyReport); err != nil {
-			return err
+	for _, r := range d.logReaders {
+		if err := r.Start(ctx); err != nil {
+			return fmt.Errorf("failed to follow %s: %w", r.Path(), err)
 		}
-		d.logger.Info("scheduled daily report", "time", d.cfg.DailyReportTime, "timezone", d.cfg.DailyReportTimezone)
+		go d.forwardLogEvents(r)
+		d.logger.Info("started monitoring log file", "path", r.Path())
 	}
 
-	if err := d.scheduler.AddDailyTask("retention-cleanup", "03:00", "UTC", d.runCleanup); err != nil {
+	if err := d.scheduleTasks(); err != nil {
 		return err
 	}
-
-	if d.cfg.GeoIPEnabled {
-		if err := d.scheduler.AddMonthlyTask("geoip-update", "04:00", "UTC", d.checkGeoIPUpdate); err != nil {
-			return err
-		}
-	}
+	d.limitMemory()
 
 	go d.scheduler.Start(ctx)
 
+	pendingTicker := time.NewTicker(5 * time.Second)
+	defer pendingTicker.Stop()
+	statusTicker := time.NewTicker(statusInterval)
+	defer statusTicker.Stop()
+
 	d.logger.Info("daemon started")
 
 	if err := d.telegram.SendStartupMessage(d.version); err != nil {
 		d.logger.Warn("failed to send startup notification", "error", err)
 	}
 
+	d.checkReboot()
+	go d.checkUpgrade()
+
 	for {
 		select {
 		case sig := <-sigCh:
 			d.logger.Info("received signal, shutting down", "signal", sig)
+			drained := d.drain()
 			cancel()
-			return d.shutdown()
+			return d.shutdown(drained)
 
 		case event := <-d.journal.Events():
 			if event == nil {
 				d.logger.Info("journal reader closed")
-				return d.shutdown()
+				return d.shutdown(true)
 			}
 			d.processEvent(event)
+
+		case event := <-d.logEvents:
+			d.processEvent(event)
+
+		case <-dumpCh:
+			d.dumpStatus(true)
+
+		case <-statusTicker.C:
+			d.dumpStatus(false)
+
+		case now := <-pendingTicker.C:
+			d.expireSSSD(now)
+			d.expireMFA(now)
 		}
 	}
 }
 
+func (d *Daemon) scheduleTasks() error {
+	if err := d.refreshTrusted(context.Background()); err != nil {
+		return err
+	}
+	d.scheduler.AddIntervalTask("trusted-refresh", trustedRefreshInterval, d.refreshTrusted)
+
+	if d.cfg.LearningDays > 0 {
+		if err := d.initLearning(); err != nil {
+			return err
+		}
+		d.scheduler.AddIntervalTask("learning-check", 10*time.Minute, d.checkLearning)
+	}
+
+	if d.cfg.DailyReportEnabled {
+		if err := d.scheduler.AddDailyTask("daily-report", d.cfg.DailyReportTime, d.cfg.DailyReportTimezone, d.sendDailyReport); err != nil {
+			return err
+		}
+		d.logger.Info("scheduled daily report", "time", d.cfg.DailyReportTime, "timezone", d.cfg.DailyReportTimezone)
+	}
+
+	if d.cfg.WallOfShameEnabled {
+		if err := d.scheduler.AddDailyTask("wall-of-shame", d.cfg.DailyReportTime, d.cfg.DailyReportTimezone, d.sendWallOfShame); err != nil {
+			return err
+		}
+	}
+
+	if err := d.scheduler.AddDailyTask("retention-cleanup", "03:00", "UTC", d.runCleanup); err != nil {
+		return err
+	}
+
+	if d.cfg.GeoIPEnabled {
+		if err := d.scheduler.AddMonthlyTask("geoip-update", "04:00", "UTC", d.checkGeoIPUpdate); err != nil {
+			return err
+		}
+	}
+
+	if d.cfg.ExposureCheckEnabled {
+		interval := time.Duration(d.cfg.ExposureCheckIntervalMinutes) * time.Minute
+		d.scheduler.AddIntervalTask("exposure-check", interval, d.checkExposure)
+	}
+
+	if d.cfg.DiskMinFreeMB > 0 {
+		d.scheduler.AddIntervalTask("disk-check", 5*time.Minute, d.checkDiskSpace)
+	}
+
+	if d.cfg.SpikeAlertMultiplier > 0 {
+		d.scheduler.AddIntervalTask("spike-check", 5*time.Minute, d.checkSpike)
+	}
+
+	if d.cfg.CampaignMinIPs > 0 {
+		d.scheduler.AddIntervalTask("campaign-check", campaignCheckInterval, d.checkCampaigns)
+	}
+
+	if d.cfg.MemoryLimitMB > 0 || d.cfg.GoroutineLimit > 0 {
+		d.scheduler.AddIntervalTask("resource-check", time.Minute, d.checkResources)
+	}
+
+	if d.cfg.AlertEditInPlace {
+		d.scheduler.AddIntervalTask("attack-end", time.Minute, func(ctx context.Context) error {
+			d.endAttacks(d.clock.Now())
+			return nil
+		})
+	}
+
+	if d.cfg.HighVolume() {
+		d.scheduler.AddIntervalTask("failure-flush", failureBatchInterval, d.flushFailures)
+	}
+
+	if d.cfg.PinnedStatusEnabled {
+		d.scheduler.AddIntervalTask("pinned-status", 5*time.Minute, d.updatePinnedStatus)
+	}
+
+	if d.cfg.ClockJumpThresholdSeconds > 0 {
+		d.scheduler.OnClockJump(time.Duration(d.cfg.ClockJumpThresholdSeconds)*time.Second, d.handleClockJump)
+	}
+	return nil
+}
+
+func (d *Daemon) forwardLogEvents(r *logfile.Reader) {
+	for event := range r.Events() {
+		d.logEvents <- event
+	}
+	d.logger.Warn("log file reader closed", "path", r.Path())
+}
+
 func (d *Daemon) processEvent(event *parser.SSHEvent) {
-	var country, city string
-	if d.geoip != nil {
-		loc, err := d.geoip.Lookup(event.IP)
-		if err != nil {
-			d.logger.Warn("GeoIP lookup failed", "ip", event.IP, "error", err)
-		} else if loc != nil {
-			country = loc.Country
-			city = loc.City
+	d.events++
+	d.lastEvent = event.Timestamp
+
+	switch event.EventType {
+	case parser.EventMFA:
+		d.logger.Debug("second factor completed", "user", event.Username, "provider", event.Method)
+		d.factors.Record(event.Username, event.Timestamp)
+		return
+	case parser.EventPartial:
+		d.logger.Debug("partial authentication", "user", event.Username, "ip", event.IP, "method", event.Method)
+		d.factors.Record(partialKey(event), event.Timestamp)
+		return
+	}
+
+	if event.Service == parser.ServiceSSH {
+		if event.AuthSource == parser.AuthSourceSSSD {
+			d.pendingSSSD.Add(sssdKey(event), event)
+			return
+		}
+		// PAM modules log a rejected second factor without the address;
+		// sshd's Failed line for the same connection follows and has it.
+		if event.EventType == parser.EventFailure && event.IP == "" && parser.IsMFAMethod(event.Method) {
+			d.pendingMFA.Add(mfaKey(event), event)
+			return
+		}
+		if pending := d.pendingSSSD.Take(sssdKey(event)); pending != nil {
+			event.AuthSource = pending.AuthSource
 		}
+		if event.EventType == parser.EventFailure {
+			if pending := d.pendingMFA.Take(mfaKey(event)); pending != nil {
+				event.Method = pending.Method
+			}
+		}
+	}
+
+	if event.EventType == parser.EventSuccess && event.Service == parser.ServiceSSH {
+		viaPAM := d.factors.Consume(event.Username, event.Timestamp)
+		viaPartial := d.factors.Consume(partialKey(event), event.Timestamp)
+		event.MFA = viaPAM || viaPartial
+	}
+
+	d.handleEvent(event)
+}
+
+// lookup returns the location of ip, or an empty one without a GeoIP
+// database.
+func (d *Daemon) lookup(ip string) geoip.Location {
+	if d.geoip == nil {
+		return geoip.Location{}
+	}
+	found, err := d.geoip.Lookup(ip)
+	if err != nil {
+		d.logger.Warn("GeoIP lookup failed", "ip", ip, "error", err)
+		return geoip.Location{}
+	}
+	if found == nil {
+		return geoip.Location{}
 	}
+	return *found
+}
 
-	var warning string
+func (d *Daemon) handleEvent(event *parser.SSHEvent) {
+	// The high-volume profile batches failed attempts as aggregates and
+	// looks up locations only for IPs that trigger an alert.
+	batched := event.EventType == parser.EventFailure && d.cfg.HighVolume()
+	var loc geoip.Location
+	if !batched {
+		loc = d.lookup(event.IP)
+	}
+
+	var history string
+	var warnings []string
+	var newKey bool
 	if event.EventType == parser.EventSuccess {
-		warning = d.checkLocationChange(event, country, city)
+		history = d.ipHistory(event.IP)
+		if d.cfg.NewKeyAlertEnabled && d.isNewKey(event) {
+			d.logger.Warn("login with a key not seen for this user before", "user", event.Username, "ip", event.IP, "fingerprint", event.Fingerprint)
+			warnings = append(warnings, "New key for this account!")
+			newKey = true
+		}
+		if w := d.checkLocationChange(event, loc.Country, loc.City); w != "" {
+			warnings = append(warnings, w)
+		}
+		if d.cfg.MFAExpected && event.Service == parser.ServiceSSH && !event.MFA {
+			d.logger.Warn("login without second factor", "user", event.Username, "ip", event.IP)
+			warnings = append(warnings, "Login completed without a second factor!")
+		}
 	}
+	warning := strings.Join(warnings, "\n⚠️ ")
 
-	if err := d.storage.InsertEvent(event, country, city); err != nil {
-		d.logger.Error("failed to store event", "error", err)
+	var duplicate bool
+	switch {
+	case batched:
+		pending, added := d.failures.add(event)
+		duplicate = !added
+		if pending >= failureBatchMax {
+			if err := d.flushFailures(context.Background()); err != nil {
+				d.logger.Error("failed to write batched failed attempts", "error", err)
+			}
+		}
+	case event.EventType == parser.EventFailure && d.lowDisk.Load():
+		if err := d.storage.AddFailureAggregate(event, loc); err != nil {
+			d.logger.Error("failed to aggregate event", "error", err)
+			return
+		}
+	default:
+		inserted, err := d.storage.InsertEvent(event, loc)
+		if err != nil {
+			d.logger.Error("failed to store event", "error", err)
+			return
+		}
+		duplicate = !inserted
+	}
+	if duplicate {
+		d.logger.Debug("skipping duplicate event", "service", event.Service, "user", event.Username, "ip", event.IP, "timestamp", event.Timestamp)
+		// A hash match cannot prove a replay, so failures still count
+		// towards bursts rather than risk hiding a fast brute force.
+		if event.EventType == parser.EventFailure && event.Service == parser.ServiceWeb {
+			d.checkWebBurst(event, loc)
+		}
 		return
 	}
+	if !batched {
+		d.compareGeoIP(event, loc)
+	}
+	d.writeOutputs(event, loc)
 
 	if event.EventType == parser.EventSuccess {
-		d.logger.Info("successful SSH login",
+		d.logger.Info("successful login",
+			"service", event.Service,
 			"user", event.Username,
 			"ip", event.IP,
 			"method", event.Method,
-			"country", country,
-			"city", city,
+			"mfa", event.MFA,
+			"auth_source", event.AuthSource,
+			"country", loc.Country,
+			"city", loc.City,
 		)
 
-		if err := d.telegram.SendLoginAlert(event, country, city, warning); err != nil {
+		if d.learning() {
+			d.logger.Info("learning mode, login alert suppressed", "user", event.Username, "ip", event.IP)
+			return
+		}
+		if warning == "" && report.IsTrusted(d.trustedNetworks(), event.IP) {
+			d.logger.Debug("login from trusted network, alert suppressed", "user", event.Username, "ip", event.IP)
+			return
+		}
+
+		severity := notifier.SeverityInfo
+		if newKey {
+			severity = notifier.SeverityCritical
+		} else if warning != "" {
+			severity = notifier.SeverityWarning
+		}
+		d.emit("login", severity, fmt.Sprintf("%s login as %s from %s", event.Service, event.Username, event.IP), map[string]string{
+			"service":     event.Service,
+			"user":        event.Username,
+			"ip":          event.IP,
+			"method":      event.Method,
+			"fingerprint": event.Fingerprint,
+			"mfa":         strconv.FormatBool(event.MFA),
+			"auth_source": event.AuthSource,
+			"country":     loc.Country,
+			"city":        loc.City,
+			"history":     history,
+			"warning":     strings.Join(warnings, "\n"),
+		})
+		if err := d.telegram.SendLoginAlert(event, loc, history, warning, newKey); err != nil {
 			d.logger.Error("failed to send Telegram alert", "error", err)
 		}
 	} else {
-		d.logger.Debug("failed SSH attempt",
+		d.logger.Debug("failed login attempt",
+			"service", event.Service,
 			"user", event.Username,
 			"ip", event.IP,
+			"method", event.Method,
 			"invalid_user", event.InvalidUser,
 		)
+
+		if event.Service == parser.ServiceWeb {
+			d.checkWebBurst(event, loc)
+		}
 	}
 }
 
+func (d *Daemon) checkWebBurst(event *parser.SSHEvent, loc geoip.Location) {
+	tracker, kind := d.webBursts, "Web Auth Failure Burst"
+	if event.Method == parser.MethodAdminLogin {
+		tracker, kind = d.adminBursts, "Admin Panel Brute Force"
+	}
+
+	attackKey := kind + "|" + event.IP
+	if d.cfg.AlertEditInPlace {
+		if attack, ok, edit := d.attacks.Hit(attackKey, event.Timestamp); ok {
+			if edit {
+				if d.cfg.HighVolume() {
+					loc = d.lookup(event.IP)
+				}
+				if err := d.telegram.EditBurstAlert(attack.MessageID, kind, event.IP, loc, attack.History, attack.Count, attack.First, attack.Last); err != nil {
+					d.logger.Warn("failed to update Telegram alert", "error", err)
+				}
+			}
+			return
+		}
+	}
+
+	count, fire := tracker.Add(event.IP, event.Timestamp)
+	if !fire {
+		return
+	}
+
+	window := time.Duration(d.cfg.WebBurstWindowMinutes) * time.Minute
+	d.logger.Warn("web brute force detected", "ip", event.IP, "method", event.Method, "count", count)
+	if d.learning() {
+		return
+	}
+	if d.cfg.HighVolume() {
+		loc = d.lookup(event.IP)
+	}
+	history := d.ipHistory(event.IP)
+	d.emit("burst", notifier.SeverityWarning, fmt.Sprintf("%s from %s: %d attempts in %s", kind, event.IP, count, window), map[string]string{
+		"burst":   kind,
+		"ip":      event.IP,
+		"count":   strconv.Itoa(count),
+		"window":  window.String(),
+		"country": loc.Country,
+		"history": history,
+	})
+	messageID, err := d.telegram.SendBurstAlert(kind, event.IP, loc, history, count, window)
+	if err != nil {
+		d.logger.Error("failed to send Telegram alert", "error", err)
+		return
+	}
+	if d.cfg.AlertEditInPlace {
+		d.attacks.Start(attackKey, messageID, history, count, event.Timestamp)
+	}
+}
+
+// emit passes an alert to the structured sinks. Chat messages are sent
+// separately since they are rendered per alert kind.
+func (d *Daemon) emit(kind, severity, message string, fields map[string]string) {
+	alert := notifier.Alert{
+		Time:     d.clock.Now(),
+		Server:   d.cfg.ServerName,
+		Kind:     kind,
+		Severity: severity,
+		Message:  message,
+		Fields:   fields,
+	}
+	for _, sink := range d.sinks {
+		if err := sink.Emit(alert); err != nil {
+			d.logger.Warn("failed to emit alert", "kind", kind, "error", err)
+		}
+	}
+}
+
+// writeOutputs ships a stored event to the configured outputs.
+func (d *Daemon) writeOutputs(event *parser.SSHEvent, loc geoip.Location) {
+	if len(d.outputs) == 0 {
+		return
+	}
+	record := output.NewEvent(d.cfg.ServerName, event, loc)
+	for _, o := range d.outputs {
+		if err := o.Write(record); err != nil {
+			d.logger.Warn("failed to write event to output", "error", err)
+		}
+	}
+}
+
+const ipHistoryDays = 30
+
+// ipHistory summarises what storage knows about ip, e.g. "seen 14 times in
+// 30 days, first 2026-01-02", so responders can spot recurring offenders.
+// The query is skipped while the daemon throttles itself.
+func (d *Daemon) ipHistory(ip string) string {
+	if d.throttled.Load() {
+		return ""
+	}
+	h, err := d.storage.GetIPHistory(ip, d.clock.Now().AddDate(0, 0, -ipHistoryDays))
+	if err != nil {
+		d.logger.Warn("failed to load IP history", "ip", ip, "error", err)
+		return ""
+	}
+	if h.FirstSeen.IsZero() {
+		return "first time seen"
+	}
+
+	times := "times"
+	if h.Count == 1 {
+		times = "time"
+	}
+	return fmt.Sprintf("seen %d %s in %d days, first %s", h.Count, times, ipHistoryDays, h.FirstSeen.Format("2006-01-02"))
+}
+
+// isNewKey reports whether a public key login uses a key that the account
+// has not logged in with before. Accounts without any recorded key logins
+// have no baseline yet, so their first key is not reported.
+func (d *Daemon) isNewKey(event *parser.SSHEvent) bool {
+	if event.Fingerprint == "" {
+		return false
+	}
+	usage, err := d.storage.GetKeyUsage("", event.Username)
+	if err != nil {
+		d.logger.Warn("failed to load key inventory", "user", event.Username, "error", err)
+		return false
+	}
+	if len(usage) == 0 {
+		return false
+	}
+	for _, u := range usage {
+		if u.Fingerprint == event.Fingerprint {
+			return false
+		}
+	}
+	return true
+}
+
 func (d *Daemon) checkLocationChange(event *parser.SSHEvent, country, city string) string {
-	lastLogin, err := d.storage.GetLastLoginForUser(event.Username)
+	lastLogin, err := d.storage.GetLastLoginForUser(event.Service, event.Username)
 	if err != nil {
 		return ""
 	}
@@ -206,6 +771,45 @@ func (d *Daemon) checkLocationChange(event *parser.SSHEvent, country, city strin
 	return fmt.Sprintf("New location! Previous: %s (%s)", lastLocation, lastLogin.IP)
 }
 
+// expireSSSD handles pam_sss results sshd did not log a line for. A failure
+// stands on its own, but a PAM success alone is not a completed login: sshd
+// may still have refused the session, so it is only logged.
+func (d *Daemon) expireSSSD(now time.Time) {
+	for _, event := range d.pendingSSSD.Expired(now) {
+		if event.EventType == parser.EventSuccess {
+			d.logger.Info("pam_sss success without an sshd login, not recorded", "user", event.Username, "ip", event.IP)
+			continue
+		}
+		d.handleEvent(event)
+	}
+}
+
+// expireMFA drops rejected second factors sshd did not log a failure for.
+// Without sshd's line the address is unknown, and an attempt without one
+// would show up as an empty entry in every per-IP list.
+func (d *Daemon) expireMFA(now time.Time) {
+	for _, event := range d.pendingMFA.Expired(now) {
+		d.logger.Info("rejected second factor without an sshd failure, not recorded", "user", event.Username, "method", event.Method)
+	}
+}
+
+// mfaKey pairs a rejected second factor with sshd's Failed line, logged by
+// the same process.
+func mfaKey(event *parser.SSHEvent) string {
+	return fmt.Sprintf("%s|%s", strings.ToLower(event.Username), event.PID)
+}
+
+// sssdKey pairs a pam_sss result with the sshd line of the same
+// connection. Both are logged by the same sshd process, while the address
+// may differ: pam_sss often logs rhost as a resolved hostname.
+func sssdKey(event *parser.SSHEvent) string {
+	return fmt.Sprintf("%s|%s|%s", event.EventType, strings.ToLower(event.Username), event.PID)
+}
+
+func partialKey(event *parser.SSHEvent) string {
+	return fmt.Sprintf("%s|%s|%d", event.Username, event.IP, event.Port)
+}
+
 func formatLocation(country, city string) string {
 	if city != "" && country != "" {
 		return fmt.Sprintf("%s, %s", city, country)
@@ -216,26 +820,171 @@ func formatLocation(country, city string) string {
 	return city
 }
 
+// updatePinnedStatus keeps one pinned message per day up to date with the
+// day's counters. A new message is sent and pinned each day; the previous
+// day's message is unpinned and left in the chat history.
+func (d *Daemon) updatePinnedStatus(ctx context.Context) error {
+	now := d.clock.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	serviceStats, err := d.storage.GetServiceStats(startOfDay, now)
+	if err != nil {
+		return err
+	}
+
+	status := notifier.Status{
+		ActiveAttacks: d.attacks.Len(),
+		LowDisk:       d.lowDisk.Load(),
+		Updated:       now,
+	}
+	for _, ss := range serviceStats {
+		status.Counters = append(status.Counters, notifier.StatusCounter{
+			Service:   ss.Service,
+			Success:   ss.SuccessCount,
+			Failed:    ss.FailedCount,
+			UniqueIPs: ss.UniqueIPs,
+		})
+	}
+
+	today := now.Format("2006-01-02")
+	date, err := d.storage.GetState(stateStatusDate)
+	if err != nil {
+		return err
+	}
+	idValue, err := d.storage.GetState(stateStatusMessage)
+	if err != nil {
+		return err
+	}
+	messageID, _ := strconv.Atoi(idValue)
+
+	if date == today && messageID != 0 {
+		if err := d.telegram.EditStatus(messageID, status); err == nil || strings.Contains(err.Error(), "message is not modified") {
+			return nil
+		}
+		d.logger.Warn("failed to edit pinned status, sending a new one", "error", err)
+	}
+
+	if messageID != 0 {
+		if err := d.telegram.Unpin(messageID); err != nil {
+			d.logger.Debug("failed to unpin previous status", "error", err)
+		}
+	}
+
+	messageID, err = d.telegram.SendStatus(status)
+	if err != nil {
+		return err
+	}
+	if err := d.telegram.Pin(messageID); err != nil {
+		d.logger.Warn("failed to pin status message, the bot needs pin permission in groups", "error", err)
+	}
+
+	if err := d.storage.SetState(stateStatusMessage, strconv.Itoa(messageID)); err != nil {
+		return err
+	}
+	return d.storage.SetState(stateStatusDate, today)
+}
+
+func (d *Daemon) checkReboot() {
+	bootID, err := host.BootID()
+	if err != nil {
+		d.logger.Warn("failed to read boot ID", "error", err)
+		return
+	}
+
+	previous, err := d.storage.GetState(stateBootID)
+	if err != nil {
+		d.logger.Warn("failed to load previous boot ID", "error", err)
+		return
+	}
+
+	if previous != "" && previous != bootID {
+		bootTime := d.clock.Now()
+		if uptime, err := host.Uptime(); err == nil {
+			bootTime = bootTime.Add(-uptime)
+		}
+		d.logger.Info("host reboot detected", "boot_id", bootID, "booted", bootTime)
+		if d.cfg.RebootAlertEnabled {
+			d.emit("reboot", notifier.SeverityInfo, "host rebooted at "+bootTime.Format("2006-01-02 15:04:05"), map[string]string{
+				"boot_id": bootID,
+				"booted":  bootTime.Format(time.RFC3339),
+			})
+			if err := d.telegram.SendRebootAlert(bootTime); err != nil {
+				d.logger.Error("failed to send reboot alert", "error", err)
+			}
+		}
+	}
+
+	if previous != bootID {
+		if err := d.storage.SetState(stateBootID, bootID); err != nil {
+			d.logger.Warn("failed to store boot ID", "error", err)
+		}
+	}
+}
+
 func (d *Daemon) sendDailyReport(ctx context.Context) error {
-	yesterday := time.Now().AddDate(0, 0, -1)
-	reportText, err := d.report.GenerateDailyReport(yesterday)
+	yesterday := d.clock.Now().AddDate(0, 0, -1)
+	reportText, err := d.report.ComposeDailyReport(yesterday, d.cfg)
 	if err != nil {
 		return err
 	}
+
 	return d.telegram.SendDailyReport(reportText)
 }
 
+func (d *Daemon) sendWallOfShame(ctx context.Context) error {
+	weekday, _ := d.cfg.WallOfShameDay()
+	now := d.clock.Now()
+	if now.Weekday() != weekday {
+		return nil
+	}
+
+	text, err := d.report.GenerateWallOfShame(now)
+	if err != nil {
+		return err
+	}
+	return d.telegram.SendDailyReport(text)
+}
+
 func (d *Daemon) runCleanup(ctx context.Context) error {
-	deleted, err := d.storage.Cleanup(d.cfg.RetentionDays)
+	now := d.clock.Now()
+	rolled, err := report.UpdateRollups(d.storage, now.AddDate(0, 0, -d.cfg.RetentionDays), now)
+	if err != nil {
+		return err
+	}
+	if rolled > 0 {
+		d.logger.Info("daily rollups updated", "days", rolled)
+	}
+
+	deleted, err := d.storage.Cleanup(now, d.cfg.RetentionDays)
 	if err != nil {
 		return err
 	}
 	if deleted > 0 {
 		d.logger.Info("retention cleanup completed", "deleted", deleted)
 	}
+
+	d.pruneTrackers(now)
 	return nil
 }
 
+func (d *Daemon) pruneTrackers(now time.Time) {
+	d.webBursts.Prune(now)
+	d.adminBursts.Prune(now)
+	d.endAttacks(now)
+	d.factors.Prune(now)
+}
+
+// endAttacks forgets attacks that stopped and edits their alert once more
+// when it does not show the final count yet.
+func (d *Daemon) endAttacks(now time.Time) {
+	for _, attack := range d.attacks.Prune(now) {
+		kind, ip, _ := strings.Cut(attack.Key, "|")
+		if err := d.telegram.EndBurstAlert(attack.MessageID, kind, ip, d.lookup(ip), attack.History, attack.Count, attack.First, attack.Last); err != nil {
+			d.logger.Warn("failed to update Telegram alert", "error", err)
+		}
+	}
+}
+
 func (d *Daemon) checkGeoIPUpdate(ctx context.Context) error {
 	needsUpdate, err := d.geoUpdate.NeedsUpdate()
 	if err != nil {
@@ -256,11 +1005,275 @@ func (d *Daemon) checkGeoIPUpdate(ctx context.Context) error {
 			return err
 		}
 		d.geoip = resolver
+		d.loadASN()
+	}
+
+	if d.cfg.ASNEnabled && d.geoip != nil {
+		needsUpdate, err := d.asnUpdate.NeedsUpdate()
+		if err != nil {
+			d.logger.Warn("failed to check for ASN update", "error", err)
+			return nil
+		}
+		if needsUpdate {
+			if err := d.asnUpdate.Update(); err != nil {
+				return err
+			}
+			d.loadASN()
+		}
+	}
+	return nil
+}
+
+func (d *Daemon) handleClockJump(jump time.Duration) {
+	d.logger.Warn("clock jump exceeds threshold, resetting burst trackers", "jump", jump)
+	d.webBursts.Reset()
+	d.adminBursts.Reset()
+	d.attacks.Reset()
+
+	d.emit("clock_jump", notifier.SeverityWarning, fmt.Sprintf("system clock jumped by %s", jump), map[string]string{
+		"jump_seconds": strconv.FormatFloat(jump.Seconds(), 'f', 0, 64),
+	})
+	if err := d.telegram.SendClockJumpAlert(jump); err != nil {
+		d.logger.Error("failed to send clock jump alert", "error", err)
+	}
+}
+
+// checkSpike compares failed attempts in the last hour with the hourly
+// average over the baseline period before it. Distributed attacks rotate
+// IPs fast enough that no per-IP threshold fires, but they still show up
+// as a jump in total volume. Only the start of a spike is alerted.
+func (d *Daemon) checkSpike(ctx context.Context) error {
+	now := d.clock.Now()
+	hourAgo := now.Add(-time.Hour)
+	baselineStart := hourAgo.AddDate(0, 0, -d.cfg.SpikeBaselineDays)
+
+	current, err := d.failedAttempts(hourAgo, now)
+	if err != nil {
+		return err
+	}
+	baseline, err := d.failedAttempts(baselineStart, hourAgo)
+	if err != nil {
+		return err
+	}
+
+	average := float64(baseline) / (float64(d.cfg.SpikeBaselineDays) * 24)
+	spike := current >= d.cfg.SpikeAlertMinAttempts &&
+		float64(current) >= average*float64(d.cfg.SpikeAlertMultiplier)
+	if d.spiking.Swap(spike) == spike {
+		return nil
+	}
+
+	if !spike {
+		d.logger.Info("failed attempt volume back to normal", "last_hour", current, "hourly_average", average)
+		return nil
+	}
+	d.logger.Warn("failed attempt volume spike", "last_hour", current, "hourly_average", average)
+	if d.learning() {
+		return nil
+	}
+	d.emit("spike", notifier.SeverityWarning, fmt.Sprintf("%d failed attempts in the last hour, hourly average %.1f", current, average), map[string]string{
+		"last_hour":      strconv.Itoa(current),
+		"hourly_average": strconv.FormatFloat(average, 'f', 1, 64),
+		"baseline_days":  strconv.Itoa(d.cfg.SpikeBaselineDays),
+	})
+	return d.telegram.SendSpikeAlert(current, average, d.cfg.SpikeBaselineDays)
+}
+
+// failedAttempts counts stored and aggregated failed attempts in [since, until).
+func (d *Daemon) failedAttempts(since, until time.Time) (int, error) {
+	stats, err := d.storage.GetFailedStats(since, until, "")
+	if err != nil {
+		return 0, err
 	}
+	aggregated, err := d.storage.GetAggregatedFailures(since, until, "")
+	if err != nil {
+		return 0, err
+	}
+	return stats.TotalAttempts + aggregated, nil
+}
+
+func (d *Daemon) checkDiskSpace(ctx context.Context) error {
+	free, err := host.FreeSpace(d.cfg.DatabasePath)
+	if err != nil {
+		return fmt.Errorf("failed to check free disk space: %w", err)
+	}
+
+	threshold := uint64(d.cfg.DiskMinFreeMB) * 1024 * 1024
+	low := free < threshold
+	if d.lowDisk.Swap(low) == low {
+		return nil
+	}
+
+	severity, message := notifier.SeverityCritical, "low disk space, storing failed attempts as aggregates only"
+	if low {
+		d.logger.Warn(message, "path", d.cfg.DatabasePath, "free_mb", free/(1024*1024))
+	} else {
+		severity, message = notifier.SeverityInfo, "disk space recovered, resuming full event storage"
+		d.logger.Info(message, "path", d.cfg.DatabasePath, "free_mb", free/(1024*1024))
+	}
+	d.emit("disk_space", severity, message, map[string]string{
+		"path":         d.cfg.DatabasePath,
+		"free_mb":      strconv.FormatUint(free/(1024*1024), 10),
+		"threshold_mb": strconv.Itoa(d.cfg.DiskMinFreeMB),
+	})
+	return d.telegram.SendDiskSpaceAlert(d.cfg.DatabasePath, free, threshold, low)
+}
+
+func (d *Daemon) checkExposure(ctx context.Context) error {
+	ports, err := audit.SSHDPorts(d.cfg.SSHDConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to read sshd ports: %w", err)
+	}
+
+	listeners, err := netinfo.TCPListeners(ports)
+	if err != nil {
+		return err
+	}
+
+	ipv4, ipv6 := netinfo.PublicIPs()
+
+	var lines []string
+	for _, l := range listeners {
+		lines = append(lines, fmt.Sprintf("%s - %s", l, describeExposure(l, ipv4, ipv6)))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, fmt.Sprintf("sshd is not listening on port(s) %v", ports))
+	}
+	current := strings.Join(lines, "\n")
+
+	if d.exposure == "" {
+		d.logger.Info("SSH exposure baseline", "listeners", lines)
+		d.exposure = current
+		return nil
+	}
+	if current == d.exposure {
+		return nil
+	}
+
+	d.logger.Warn("SSH exposure changed", "previous", d.exposure, "current", current)
+	d.emit("exposure", notifier.SeverityWarning, "SSH exposure changed", map[string]string{
+		"previous": d.exposure,
+		"current":  current,
+	})
+	if err := d.telegram.SendExposureAlert(d.exposure, current); err != nil {
+		return err
+	}
+	d.exposure = current
 	return nil
 }
 
-func (d *Daemon) shutdown() error {
+func describeExposure(l netinfo.Listener, ipv4, ipv6 string) string {
+	if !l.Public() {
+		return "private"
+	}
+	if !l.IP.IsUnspecified() {
+		return "public"
+	}
+
+	var via []string
+	if ipv4 != "" {
+		via = append(via, ipv4)
+	}
+	if ipv6 != "" && l.IP.To4() == nil {
+		via = append(via, ipv6)
+	}
+	if len(via) == 0 {
+		return "all interfaces"
+	}
+	return "all interfaces, public via " + strings.Join(via, ", ")
+}
+
+// resumeState continues the journal after the last entry read before a
+// recent restart, and lets the scheduler catch up on tasks due meanwhile.
+// Replayed entries that were already stored are skipped as duplicates.
+func (d *Daemon) resumeState() {
+	value, err := d.storage.GetState(stateLastCheck)
+	if err != nil || value == "" {
+		return
+	}
+	lastCheck, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		d.logger.Warn("ignoring invalid scheduler state", "value", value, "error", err)
+		return
+	}
+	if d.clock.Now().Sub(lastCheck) > maxResumeGap {
+		d.logger.Info("last run too long ago, starting from now", "stopped", lastCheck)
+		return
+	}
+
+	d.scheduler.Resume(lastCheck)
+	cursor, err := d.storage.GetState(stateJournalCursor)
+	if err != nil {
+		d.logger.Warn("failed to load journal cursor", "error", err)
+		return
+	}
+	if cursor != "" {
+		d.journal.ResumeAfter(cursor)
+	}
+	d.logger.Info("resuming after restart", "stopped", lastCheck, "journal_cursor", cursor != "")
+}
+
+// drain stops reading new log lines and handles the events that were
+// already read, so that none are lost when the daemon stops. It reports
+// false if that did not finish within drainTimeout.
+func (d *Daemon) drain() bool {
+	d.journal.Stop()
+	for _, r := range d.logReaders {
+		r.Stop()
+	}
+
+	timeout := time.After(drainTimeout)
+	for {
+		select {
+		case event := <-d.journal.Events():
+			if event == nil {
+				return d.drainLogEvents()
+			}
+			d.processEvent(event)
+		case event := <-d.logEvents:
+			d.processEvent(event)
+		case <-timeout:
+			d.logger.Warn("timed out handling buffered events", "timeout", drainTimeout)
+			return false
+		}
+	}
+}
+
+func (d *Daemon) drainLogEvents() bool {
+	for {
+		select {
+		case event := <-d.logEvents:
+			d.processEvent(event)
+		default:
+			return true
+		}
+	}
+}
+
+// saveState records where the journal and the scheduler stopped for
+// resumeState. If draining was cut short, the journal cursor is cleared
+// since it points past events that were never handled.
+func (d *Daemon) saveState(drained bool) {
+	cursor := d.journal.Cursor()
+	if !drained {
+		cursor = ""
+	}
+	if cursor != "" || !drained {
+		if err := d.storage.SetState(stateJournalCursor, cursor); err != nil {
+			d.logger.Warn("failed to save journal cursor", "error", err)
+		}
+	}
+
+	lastCheck := d.scheduler.LastCheck()
+	if lastCheck.IsZero() {
+		lastCheck = d.clock.Now()
+	}
+	if err := d.storage.SetState(stateLastCheck, lastCheck.Format(time.RFC3339Nano)); err != nil {
+		d.logger.Warn("failed to save scheduler state", "error", err)
+	}
+}
+
+func (d *Daemon) shutdown(drained bool) error {
 	d.logger.Info("shutting down")
 
 	if err := d.telegram.SendShutdownMessage(); err != nil {
@@ -271,9 +1284,32 @@ func (d *Daemon) shutdown() error {
 		d.journal.Stop()
 	}
 
+	d.expireSSSD(d.clock.Now().Add(time.Hour))
+	d.expireMFA(d.clock.Now().Add(time.Hour))
+
+	for _, r := range d.logReaders {
+		r.Stop()
+	}
+
+	if err := d.flushFailures(context.Background()); err != nil {
+		d.logger.Warn("failed to write batched failed attempts", "error", err)
+	}
+
+	d.saveState(drained)
+	d.dumpStatus(false)
+
+	for _, o := range d.outputs {
+		if err := o.Close(); err != nil {
+			d.logger.Warn("failed to close output", "error", err)
+		}
+	}
+
 	if d.geoip != nil {
 		d.geoip.Close()
 	}
+	if d.geoCompare != nil {
+		d.geoCompare.Close()
+	}
 
 	if d.storage != nil {
 		d.storage.Close()
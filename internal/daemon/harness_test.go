@@ -0,0 +1,135 @@
+package daemon
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/config"
+	"github.com/oxisoft/oxiwatch/internal/journal"
+	"github.com/oxisoft/oxiwatch/internal/notifier"
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+// harness runs the whole daemon pipeline against scripted journal output,
+// an in-memory store and a recording notifier. Add lines with entry or sshd,
+// call run, then inspect store and messages.
+type harness struct {
+	t        *testing.T
+	cfg      *config.Config
+	store    *storage.Memory
+	recorder *notifier.Recorder
+	sink     *sinkRecorder
+	lines    []string
+}
+
+// sinkRecorder collects the structured alerts the daemon emits.
+type sinkRecorder struct {
+	mu     sync.Mutex
+	alerts []notifier.Alert
+}
+
+func (s *sinkRecorder) Emit(alert notifier.Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alerts = append(s.alerts, alert)
+	return nil
+}
+
+func (s *sinkRecorder) Alerts() []notifier.Alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]notifier.Alert(nil), s.alerts...)
+}
+
+func newHarness(t *testing.T) *harness {
+	cfg := config.DefaultConfig()
+	cfg.ServerName = "test-host"
+	cfg.GeoIPEnabled = false
+	cfg.DailyReportEnabled = false
+	cfg.SSHDAuditEnabled = false
+	cfg.ExposureCheckEnabled = false
+	cfg.RebootAlertEnabled = false
+	cfg.DiskMinFreeMB = 0
+	cfg.ClockJumpThresholdSeconds = 0
+	cfg.SpikeAlertMultiplier = 0
+
+	return &harness{
+		t:        t,
+		cfg:      cfg,
+		store:    storage.NewMemory(),
+		recorder: notifier.NewRecorder(),
+		sink:     &sinkRecorder{},
+	}
+}
+
+// entry appends a journalctl -o json line.
+func (h *harness) entry(identifier string, ts time.Time, message string) {
+	h.entryPID(identifier, "", ts, message)
+}
+
+// entryPID appends a journalctl -o json line logged by process pid.
+func (h *harness) entryPID(identifier, pid string, ts time.Time, message string) {
+	line, err := json.Marshal(map[string]string{
+		"__CURSOR":             "s=test;i=" + strconv.Itoa(len(h.lines)+1),
+		"__REALTIME_TIMESTAMP": strconv.FormatInt(ts.UnixMicro(), 10),
+		"SYSLOG_IDENTIFIER":    identifier,
+		"_PID":                 pid,
+		"MESSAGE":              message,
+	})
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	h.lines = append(h.lines, string(line))
+}
+
+func (h *harness) sshd(ts time.Time, message string) {
+	h.entry("sshd", ts, message)
+}
+
+// daemon builds a daemon wired to the harness without starting it, for
+// tests that call its periodic checks directly.
+func (h *harness) daemon() *Daemon {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	source := strings.NewReader(strings.Join(h.lines, "\n") + "\n")
+	telegram := notifier.NewWithTransport(h.recorder, h.cfg.ServerName, h.cfg.Metadata)
+	d := newDaemon(h.cfg, logger, "test", h.store, telegram, journal.NewScripted(logger, source))
+	d.sinks = append(d.sinks, h.sink)
+	return d
+}
+
+// run feeds all lines through the daemon and returns once it has shut down
+// at the end of the script.
+func (h *harness) run() {
+	h.t.Helper()
+
+	d := h.daemon()
+	done := make(chan error, 1)
+	go func() { done <- d.Run() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			h.t.Fatalf("daemon failed: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		h.t.Fatal("daemon did not finish the script")
+	}
+}
+
+// alerts returns the messages other than the startup and shutdown notices.
+func (h *harness) alerts() []notifier.Message {
+	var alerts []notifier.Message
+	for _, m := range h.recorder.Messages() {
+		if strings.Contains(m.Text, "OxiWatch Started") || strings.Contains(m.Text, "OxiWatch Stopped") {
+			continue
+		}
+		alerts = append(alerts, m)
+	}
+	return alerts
+}
@@ -0,0 +1,118 @@
+package daemon
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/parser"
+	"github.com/oxisoft/oxiwatch/internal/scheduler"
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+// failureBatchInterval is how often the high-volume profile writes batched
+// failure counts; interval tasks cannot run more often than the scheduler
+// ticks. failureBatchMax flushes early once that many attempts are pending,
+// which bounds memory during an attack. failureDedupWindow is how long an
+// attempt is remembered to drop it when it is read again.
+const (
+	failureBatchInterval = scheduler.TickInterval
+	failureBatchMax      = 10000
+	failureDedupWindow   = 2 * time.Minute
+)
+
+type failureKey struct {
+	hour    time.Time
+	service string
+	ip      string
+}
+
+// failureBatch merges failed attempts into hour/service/IP counts until they
+// are written, so repeated attempts from one IP cost one row update per
+// flush instead of one insert each. seen keeps the hashes of recent attempts
+// across flushes and drops the same attempt read twice within
+// failureDedupWindow, e.g. from the journal and a log file. Unlike the
+// unique hash of stored rows it does not catch older replays.
+type failureBatch struct {
+	mu      sync.Mutex
+	counts  map[failureKey]int
+	pending int
+	seen    map[string]time.Time
+}
+
+func newFailureBatch() *failureBatch {
+	return &failureBatch{counts: make(map[failureKey]int), seen: make(map[string]time.Time)}
+}
+
+// add counts event unless it is a duplicate and returns the number of
+// pending attempts.
+func (b *failureBatch) add(event *parser.SSHEvent) (pending int, added bool) {
+	service := event.Service
+	if service == "" {
+		service = parser.ServiceSSH
+	}
+	hash := event.Hash()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.seen[hash]; ok {
+		return b.pending, false
+	}
+	b.seen[hash] = event.Timestamp
+	b.counts[failureKey{event.Timestamp.Truncate(time.Hour), service, event.IP}]++
+	b.pending++
+	return b.pending, true
+}
+
+// take empties the batch and returns its counts. Attempts from before
+// failureDedupWindow are forgotten.
+func (b *failureBatch) take(now time.Time) []storage.FailureAggregate {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	aggregates := make([]storage.FailureAggregate, 0, len(b.counts))
+	for key, count := range b.counts {
+		aggregates = append(aggregates, storage.FailureAggregate{Hour: key.hour, Service: key.service, IP: key.ip, Count: count})
+	}
+	clear(b.counts)
+	b.pending = 0
+
+	cutoff := now.Add(-failureDedupWindow)
+	for hash, ts := range b.seen {
+		if ts.Before(cutoff) {
+			delete(b.seen, hash)
+		}
+	}
+	return aggregates
+}
+
+// restore puts back counts that could not be written.
+func (b *failureBatch) restore(aggregates []storage.FailureAggregate) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, a := range aggregates {
+		b.counts[failureKey{a.Hour, a.Service, a.IP}] += a.Count
+		b.pending += a.Count
+	}
+}
+
+func (b *failureBatch) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.counts)
+}
+
+// flushFailures writes the batched failure counts in one transaction. On
+// failure they are kept for the next flush.
+func (d *Daemon) flushFailures(ctx context.Context) error {
+	aggregates := d.failures.take(d.clock.Now())
+	if len(aggregates) == 0 {
+		return nil
+	}
+	if err := d.storage.AddFailureAggregates(aggregates); err != nil {
+		d.failures.restore(aggregates)
+		return err
+	}
+	d.logger.Debug("wrote batched failed attempts", "buckets", len(aggregates))
+	return nil
+}
@@ -0,0 +1,121 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/clock"
+	"github.com/oxisoft/oxiwatch/internal/config"
+	"github.com/oxisoft/oxiwatch/internal/fixture"
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+func TestHighVolumeProfile(t *testing.T) {
+	h := newHarness(t)
+	h.cfg.PerformanceProfile = config.ProfileHighVolume
+	ts := time.Now().Add(-time.Minute).Truncate(time.Second)
+	h.sshd(ts, "Failed password for root from 203.0.113.5 port 40000 ssh2")
+	h.sshd(ts, "Failed password for root from 203.0.113.5 port 40000 ssh2")
+	h.sshd(ts.Add(time.Second), "Failed password for admin from 203.0.113.5 port 40001 ssh2")
+	h.sshd(ts.Add(2*time.Second), "Failed password for invalid user test from 198.51.100.7 port 40002 ssh2")
+	h.sshd(ts.Add(3*time.Second), "Accepted publickey for alice from 192.0.2.1 port 50000 ssh2: ED25519 SHA256:abc")
+	h.run()
+
+	since := ts.Add(-time.Hour)
+	failures, err := h.store.GetFailedAttempts(since, storage.EventFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 0 {
+		t.Errorf("expected failed attempts as aggregates only, got %d rows", len(failures))
+	}
+	// The replayed attempt is counted once; the batch is written on shutdown.
+	if n, _ := h.store.GetAggregatedFailures(since, time.Now(), "ssh"); n != 3 {
+		t.Errorf("expected 3 aggregated failed attempts, got %d", n)
+	}
+	logins, err := h.store.GetSuccessfulLogins(since, storage.EventFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logins) != 1 {
+		t.Errorf("expected the login to be stored in full, got %d", len(logins))
+	}
+	if len(h.alerts()) != 1 {
+		t.Errorf("expected one login alert, got %+v", h.alerts())
+	}
+}
+
+// failingAggregates is a store whose batched aggregate writes fail.
+type failingAggregates struct {
+	storage.Store
+}
+
+func (failingAggregates) AddFailureAggregates([]storage.FailureAggregate) error {
+	return errors.New("database is locked")
+}
+
+func TestFailureBatchKeptOnWriteError(t *testing.T) {
+	h := newHarness(t)
+	h.cfg.PerformanceProfile = config.ProfileHighVolume
+	d := h.daemon()
+	d.storage = failingAggregates{h.store}
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		d.failures.add(fixture.Failure("root", "203.0.113.5").At(now).Build())
+	}
+	if err := d.flushFailures(context.Background()); err == nil {
+		t.Fatal("expected the write to fail")
+	}
+	if n := d.failures.Len(); n != 1 {
+		t.Fatalf("expected the bucket to be kept, got %d", n)
+	}
+
+	d.storage = h.store
+	if err := d.flushFailures(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := h.store.GetAggregatedFailures(now.Add(-time.Hour), now.Add(time.Hour), ""); n != 3 {
+		t.Errorf("expected 3 aggregated failed attempts, got %d", n)
+	}
+}
+
+func TestFailureBatchDedupSpansFlush(t *testing.T) {
+	h := newHarness(t)
+	h.cfg.PerformanceProfile = config.ProfileHighVolume
+	d := h.daemon()
+	start := time.Now().Truncate(time.Hour).Add(10 * time.Minute)
+	clk := clock.NewFake(start)
+	d.setClock(clk)
+
+	event := fixture.Failure("root", "203.0.113.5").At(start).Build()
+	if _, added := d.failures.add(event); !added {
+		t.Fatal("expected the first attempt to be counted")
+	}
+	if err := d.flushFailures(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read again after the flush, e.g. from a log file lagging behind the
+	// journal.
+	clk.Advance(time.Minute)
+	if _, added := d.failures.add(event); added {
+		t.Error("expected the attempt read again after a flush to be dropped")
+	}
+	if err := d.flushFailures(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	clk.Advance(failureDedupWindow)
+	if err := d.flushFailures(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, added := d.failures.add(event); !added {
+		t.Error("expected attempts older than the dedup window to be forgotten")
+	}
+	if n, _ := h.store.GetAggregatedFailures(start.Add(-time.Hour), start.Add(time.Hour), ""); n != 1 {
+		t.Errorf("expected 1 aggregated failed attempt, got %d", n)
+	}
+}
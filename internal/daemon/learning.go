@@ -0,0 +1,74 @@
+package daemon
+
+import (
+	"context"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+const (
+	stateLearningStarted = "learning_started"
+	stateLearningSummary = "learning_summary_sent"
+)
+
+// LearningPeriod returns the learning period configured by learningDays,
+// which starts with the daemon's first run. start is zero if the daemon
+// has not run since learning was enabled.
+func LearningPeriod(store storage.Store, learningDays int) (start, end time.Time, err error) {
+	value, err := store.GetState(stateLearningStarted)
+	if err != nil || value == "" {
+		return time.Time{}, time.Time{}, err
+	}
+	start, err = time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, start.AddDate(0, 0, learningDays), nil
+}
+
+// initLearning starts the learning period on the first run and restores it
+// after restarts.
+func (d *Daemon) initLearning() error {
+	start, end, err := LearningPeriod(d.storage, d.cfg.LearningDays)
+	if err != nil {
+		return err
+	}
+	if start.IsZero() {
+		start = d.clock.Now()
+		end = start.AddDate(0, 0, d.cfg.LearningDays)
+		if err := d.storage.SetState(stateLearningStarted, start.Format(time.RFC3339)); err != nil {
+			return err
+		}
+	}
+	d.learningStart, d.learningEnd = start, end
+	if d.learning() {
+		d.logger.Info("learning mode, detections are recorded but not alerted", "until", end.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+func (d *Daemon) learning() bool {
+	return !d.learningEnd.IsZero() && d.clock.Now().Before(d.learningEnd)
+}
+
+// checkLearning sends the learning summary once the period is over.
+func (d *Daemon) checkLearning(ctx context.Context) error {
+	if d.learningEnd.IsZero() || d.learning() {
+		return nil
+	}
+	sent, err := d.storage.GetState(stateLearningSummary)
+	if err != nil || sent != "" {
+		return err
+	}
+
+	text, err := d.report.GenerateLearningSummary(d.learningStart, d.learningEnd, d.trustedNetworks())
+	if err != nil {
+		return err
+	}
+	d.logger.Info("learning period over, sending summary")
+	if err := d.telegram.SendDailyReport(text); err != nil {
+		return err
+	}
+	return d.storage.SetState(stateLearningSummary, d.clock.Now().Format(time.RFC3339))
+}
@@ -0,0 +1,90 @@
+package daemon
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/fixture"
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+func TestLearningModeSuppressesAlerts(t *testing.T) {
+	h := newHarness(t)
+	h.cfg.LearningDays = 7
+	h.sshd(time.Now(), "Accepted password for alice from 198.51.100.7 port 50000 ssh2")
+	h.run()
+
+	if alerts := h.alerts(); len(alerts) != 0 {
+		t.Errorf("expected no alerts while learning, got %d", len(alerts))
+	}
+	if logins, _ := h.store.GetSuccessfulLogins(time.Time{}, storage.EventFilter{}); len(logins) != 1 {
+		t.Errorf("expected the login to be stored while learning, got %d", len(logins))
+	}
+}
+
+func TestLearningSummaryAfterPeriod(t *testing.T) {
+	h := newHarness(t)
+	h.cfg.LearningDays = 7
+	start := time.Now().AddDate(0, 0, -8).Truncate(time.Second)
+	if err := h.store.SetState(stateLearningStarted, start.Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		login := fixture.Success("alice", "198.51.100.7").At(start.AddDate(0, 0, i)).Build()
+		if _, err := h.store.InsertEvent(login, geoip.Location{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := h.daemon()
+	if err := d.initLearning(); err != nil {
+		t.Fatal(err)
+	}
+	if d.learning() {
+		t.Fatal("expected the learning period to be over")
+	}
+	for i := 0; i < 2; i++ {
+		if err := d.checkLearning(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	alerts := h.alerts()
+	if len(alerts) != 1 {
+		t.Fatalf("expected the summary once, got %d messages", len(alerts))
+	}
+	if !strings.Contains(alerts[0].Text, "Learning Period Summary") || !strings.Contains(alerts[0].Text, "trusted approve 198.51.100.7") {
+		t.Errorf("unexpected summary:\n%s", alerts[0].Text)
+	}
+}
+
+func TestTrustedNetworkLogin(t *testing.T) {
+	h := newHarness(t)
+	h.cfg.TrustedNetworks = []string{"198.51.100.0/24"}
+	h.sshd(time.Now(), "Accepted password for alice from 198.51.100.7 port 50000 ssh2")
+	h.sshd(time.Now(), "Accepted password for alice from 203.0.113.9 port 50001 ssh2")
+	h.run()
+
+	alerts := h.alerts()
+	if len(alerts) != 1 || !strings.Contains(alerts[0].Text, "203.0.113.9") {
+		t.Errorf("expected only the untrusted login to alert, got %+v", alerts)
+	}
+}
+
+func TestApprovedNetworkLogin(t *testing.T) {
+	h := newHarness(t)
+	if _, err := h.store.AddTrustedNetwork(storage.TrustedNetwork{IPRange: "203.0.113.9/32", ApprovedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	h.sshd(time.Now(), "Accepted password for alice from 203.0.113.9 port 50000 ssh2")
+	h.sshd(time.Now(), "Accepted password for alice from 192.0.2.1 port 50001 ssh2")
+	h.run()
+
+	alerts := h.alerts()
+	if len(alerts) != 1 || !strings.Contains(alerts[0].Text, "192.0.2.1") {
+		t.Errorf("expected only the unapproved login to alert, got %+v", alerts)
+	}
+}
@@ -0,0 +1,49 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/output"
+)
+
+func TestPipelineWritesEventLog(t *testing.T) {
+	h := newHarness(t)
+	h.cfg.EventLogPath = filepath.Join(t.TempDir(), "events.ndjson")
+	ts := time.Now().Add(-time.Minute).Truncate(time.Second)
+	h.sshd(ts, "Failed password for root from 203.0.113.5 port 40000 ssh2")
+	h.sshd(ts, "Failed password for root from 203.0.113.5 port 40000 ssh2")
+	h.sshd(ts.Add(time.Second), "Accepted publickey for alice from 192.0.2.1 port 50000 ssh2: ED25519 SHA256:abc")
+	h.run()
+
+	file, err := os.Open(h.cfg.EventLogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var events []output.Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e output.Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+
+	// The replayed failure is a duplicate and is not written twice.
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %+v", events)
+	}
+	if e := events[0]; e.Type != "failure" || e.User != "root" || e.IP != "203.0.113.5" || !e.Time.Equal(ts) || e.Server != "test-host" {
+		t.Errorf("unexpected failure event: %+v", e)
+	}
+	if e := events[1]; e.Type != "success" || e.Method != "publickey" || e.KeyType != "ED25519" || e.Fingerprint != "SHA256:abc" {
+		t.Errorf("unexpected login event: %+v", e)
+	}
+}
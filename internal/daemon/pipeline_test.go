@@ -0,0 +1,390 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/fixture"
+	"github.com/oxisoft/oxiwatch/internal/parser"
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+func TestPipelineLoginAndFailures(t *testing.T) {
+	h := newHarness(t)
+	h.cfg.LoginAlertFields = append(h.cfg.LoginAlertFields, "fingerprint")
+
+	now := time.Now().Truncate(time.Second)
+	h.sshd(now.Add(-3*time.Second), "Failed password for root from 203.0.113.5 port 40001 ssh2")
+	h.sshd(now.Add(-2*time.Second), "Failed password for invalid user admin from 203.0.113.5 port 40002 ssh2")
+	h.sshd(now.Add(-time.Second), "Accepted publickey for alice from 198.51.100.7 port 50000 ssh2: ED25519 SHA256:abc123")
+	// A journal replay of the same line must neither store nor alert twice.
+	h.sshd(now.Add(-time.Second), "Accepted publickey for alice from 198.51.100.7 port 50000 ssh2: ED25519 SHA256:abc123")
+	h.sshd(now, "Server listening on 0.0.0.0 port 22.")
+	h.run()
+
+	stats, err := h.store.GetOverallStats(now.Add(-time.Hour), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.SuccessCount != 1 || stats.FailedCount != 2 || stats.UniqueIPs != 2 {
+		t.Errorf("unexpected stored events: %+v", stats)
+	}
+
+	failures, err := h.store.GetFailedAttempts(now.Add(-time.Hour), storage.EventFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 2 || !failures[0].InvalidUser || failures[0].Username != "admin" {
+		t.Errorf("unexpected failures: %+v", failures)
+	}
+
+	alerts := h.alerts()
+	if len(alerts) != 1 {
+		t.Fatalf("expected one login alert, got %d: %+v", len(alerts), alerts)
+	}
+	for _, want := range []string{"SSH Login Alert", "test-host", "User: alice", "IP: 198.51.100.7", "Method: publickey", "Key: ED25519 SHA256:abc123"} {
+		if !strings.Contains(alerts[0].Text, want) {
+			t.Errorf("expected %q in alert:\n%s", want, alerts[0].Text)
+		}
+	}
+	if !alerts[0].HTML {
+		t.Error("expected an HTML message with the default theme")
+	}
+}
+
+func TestPipelineSecondFactor(t *testing.T) {
+	h := newHarness(t)
+	h.cfg.MFAExpected = true
+
+	now := time.Now().Truncate(time.Second)
+	h.entry("pam_duo", now.Add(-3*time.Second), "Successful Duo login for 'alice' from 198.51.100.7")
+	h.sshd(now.Add(-2*time.Second), "Accepted keyboard-interactive/pam for alice from 198.51.100.7 port 50000 ssh2")
+	h.sshd(now.Add(-time.Second), "Accepted password for bob from 198.51.100.8 port 50001 ssh2")
+	h.run()
+
+	alerts := h.alerts()
+	if len(alerts) != 2 {
+		t.Fatalf("expected two login alerts, got %d: %+v", len(alerts), alerts)
+	}
+	if !strings.Contains(alerts[0].Text, "User: alice") || !strings.Contains(alerts[0].Text, "MFA: completed") {
+		t.Errorf("expected alice's alert to show the completed factor:\n%s", alerts[0].Text)
+	}
+	if strings.Contains(alerts[0].Text, "without a second factor") {
+		t.Errorf("alice completed MFA but got a warning:\n%s", alerts[0].Text)
+	}
+	if !strings.Contains(alerts[1].Text, "User: bob") || !strings.Contains(alerts[1].Text, "Login completed without a second factor!") {
+		t.Errorf("expected bob's alert to warn about the missing factor:\n%s", alerts[1].Text)
+	}
+
+	logins, err := h.store.GetSuccessfulLogins(now.Add(-time.Hour), storage.EventFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logins) != 2 || logins[1].Username != "alice" || !logins[1].MFA || logins[0].MFA {
+		t.Errorf("expected alice with MFA and bob without, got %+v", logins)
+	}
+}
+
+func TestPipelineSecurityKey(t *testing.T) {
+	h := newHarness(t)
+	h.cfg.MFAExpected = true
+
+	now := time.Now().Truncate(time.Second)
+	h.entryPID("sshd", "4242", now.Add(-3*time.Second), "debug(pam_u2f): pam-u2f.c:288 (pam_sm_authenticate): Requesting authentication for user alice")
+	h.entryPID("sshd", "4242", now.Add(-2*time.Second), "debug(pam_u2f): pam-u2f.c:415 (pam_sm_authenticate): done. [Success]")
+	h.entryPID("sshd", "4242", now.Add(-time.Second), "Accepted keyboard-interactive/pam for alice from 198.51.100.7 port 50000 ssh2")
+	h.run()
+
+	alerts := h.alerts()
+	if len(alerts) != 1 {
+		t.Fatalf("expected one login alert, got %d: %+v", len(alerts), alerts)
+	}
+	if !strings.Contains(alerts[0].Text, "MFA: completed") || strings.Contains(alerts[0].Text, "without a second factor") {
+		t.Errorf("expected the security key to count as a second factor:\n%s", alerts[0].Text)
+	}
+}
+
+func TestPipelinePlainTheme(t *testing.T) {
+	h := newHarness(t)
+	h.cfg.Theme = "plain"
+
+	h.sshd(time.Now(), "Accepted password for alice from 198.51.100.7 port 50000 ssh2")
+	h.run()
+
+	alerts := h.alerts()
+	if len(alerts) != 1 {
+		t.Fatalf("expected one login alert, got %d", len(alerts))
+	}
+	if alerts[0].HTML || !strings.HasPrefix(alerts[0].Text, "[WARN] SSH Login Alert") {
+		t.Errorf("expected a plain text alert, got %+v", alerts[0])
+	}
+}
+
+func TestPipelineNewKey(t *testing.T) {
+	h := newHarness(t)
+
+	now := time.Now().Truncate(time.Second)
+	// The first key sets the baseline, the same key again is routine.
+	h.sshd(now.Add(-3*time.Second), "Accepted publickey for deploy from 198.51.100.7 port 50000 ssh2: ED25519 SHA256:known")
+	h.sshd(now.Add(-2*time.Second), "Accepted publickey for deploy from 198.51.100.7 port 50001 ssh2: ED25519 SHA256:known")
+	h.sshd(now.Add(-time.Second), "Accepted publickey for deploy from 198.51.100.7 port 50002 ssh2: RSA SHA256:other")
+	h.run()
+
+	alerts := h.alerts()
+	if len(alerts) != 3 {
+		t.Fatalf("expected three login alerts, got %d", len(alerts))
+	}
+	for _, a := range alerts[:2] {
+		if strings.Contains(a.Text, "New key") || strings.HasPrefix(a.Text, "🚨") {
+			t.Errorf("known key escalated:\n%s", a.Text)
+		}
+	}
+	for _, want := range []string{"🚨 <b>SSH Login Alert", "Key: RSA SHA256:other", "New key for this account!"} {
+		if !strings.Contains(alerts[2].Text, want) {
+			t.Errorf("expected %q in alert:\n%s", want, alerts[2].Text)
+		}
+	}
+
+	usage, err := h.store.GetKeyUsage("", "deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 2 {
+		t.Errorf("expected both keys in the inventory, got %+v", usage)
+	}
+}
+
+func TestPipelineSavesResumeState(t *testing.T) {
+	h := newHarness(t)
+	h.sshd(time.Now(), "Accepted password for alice from 198.51.100.7 port 50000 ssh2")
+	h.sshd(time.Now(), "Server listening on 0.0.0.0 port 22.")
+	h.run()
+
+	cursor, err := h.store.GetState(stateJournalCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor != "s=test;i=2" {
+		t.Errorf("expected the cursor of the last entry read, got %q", cursor)
+	}
+
+	value, err := h.store.GetState(stateLastCheck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastCheck, err := time.Parse(time.RFC3339Nano, value); err != nil || time.Since(lastCheck) > time.Minute {
+		t.Errorf("expected a recent scheduler check, got %q", value)
+	}
+
+	// A restart shortly after resumes from the saved state and does not
+	// alert again on the replayed login.
+	h.run()
+	if alerts := h.alerts(); len(alerts) != 1 {
+		t.Errorf("expected the replayed login to be skipped, got %d alerts", len(alerts))
+	}
+}
+
+func TestPipelineSavesStatus(t *testing.T) {
+	h := newHarness(t)
+	h.sshd(time.Now(), "Accepted password for alice from 198.51.100.7 port 50000 ssh2")
+	h.sshd(time.Now(), "Failed password for root from 203.0.113.5 port 40000 ssh2")
+	h.run()
+
+	status, err := LoadStatus(h.store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status == nil {
+		t.Fatal("expected a status snapshot after shutdown")
+	}
+	if status.Events != 2 || status.LastEvent.IsZero() {
+		t.Errorf("expected 2 events with a last event time, got %d at %v", status.Events, status.LastEvent)
+	}
+	if _, ok := status.Channels["telegram"]; !ok {
+		t.Error("expected telegram channel failures in the snapshot")
+	}
+	if len(status.NextRuns) == 0 {
+		t.Error("expected next scheduled runs in the snapshot")
+	}
+	if out := status.Format(status.Time); !strings.Contains(out, "Events handled: 2") {
+		t.Errorf("unexpected formatted status:\n%s", out)
+	}
+}
+
+func TestPipelineEmitsStructuredAlerts(t *testing.T) {
+	h := newHarness(t)
+	now := time.Now().Truncate(time.Second)
+	h.sshd(now, "Accepted publickey for deploy from 198.51.100.7 port 50000 ssh2: ED25519 SHA256:abc")
+	h.sshd(now, "Failed password for root from 203.0.113.5 port 40000 ssh2")
+	h.run()
+
+	alerts := h.sink.Alerts()
+	if len(alerts) != 1 {
+		t.Fatalf("expected one structured alert for the login, got %+v", alerts)
+	}
+	a := alerts[0]
+	if a.Kind != "login" || a.Severity != "info" || a.Server != "test-host" {
+		t.Errorf("unexpected alert: %+v", a)
+	}
+	if a.Fields["user"] != "deploy" || a.Fields["ip"] != "198.51.100.7" || a.Fields["fingerprint"] != "SHA256:abc" || a.Fields["history"] != "first time seen" {
+		t.Errorf("unexpected alert fields: %v", a.Fields)
+	}
+}
+
+func TestSameSecondWebFailures(t *testing.T) {
+	h := newHarness(t)
+	h.cfg.WebAdminLoginThreshold = 3
+	d := h.daemon()
+
+	line := `203.0.113.7 - - [` + time.Now().UTC().Format("02/Jan/2006:15:04:05 -0700") + `] "POST /wp-login.php HTTP/1.1" 200 381 "-" "curl/8.5.0"`
+	occurrences := parser.NewOccurrenceTracker()
+	parse := func() *parser.SSHEvent {
+		event := parser.ParseLogLine("nginx", line)
+		occurrences.Mark(event)
+		return event
+	}
+
+	// Access logs have no ports and one-second timestamps, so both lines
+	// are separate attempts.
+	d.handleEvent(parse())
+	d.handleEvent(parse())
+	stats, err := h.store.GetFailedStats(time.Now().Add(-time.Hour), time.Now().Add(time.Hour), parser.ServiceWeb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalAttempts != 2 {
+		t.Errorf("expected both attempts to be stored, got %d", stats.TotalAttempts)
+	}
+
+	// A replayed line is not stored again but still counts towards the
+	// burst.
+	replay := parser.ParseLogLine("nginx", line)
+	d.handleEvent(replay)
+	alerts := h.alerts()
+	if len(alerts) != 1 || !strings.Contains(alerts[0].Text, "Admin Panel Brute Force") {
+		t.Fatalf("expected a burst alert after three attempts, got %+v", alerts)
+	}
+}
+
+func TestPipelineRejectedSecondFactor(t *testing.T) {
+	h := newHarness(t)
+	now := time.Now().Truncate(time.Second)
+	h.entryPID("sshd(pam_google_authenticator)", "5000", now.Add(-4*time.Second), "Invalid verification code for alice")
+	h.entryPID("sshd", "5000", now.Add(-4*time.Second), "Failed keyboard-interactive/pam for alice from 203.0.113.5 port 50000 ssh2")
+	// No sshd line follows this one, so its address stays unknown.
+	h.entryPID("sshd", "5001", now.Add(-3*time.Second), "debug(pam_u2f): pam-u2f.c:288 (pam_sm_authenticate): Requesting authentication for user bob")
+	h.entryPID("sshd", "5001", now.Add(-2*time.Second), "debug(pam_u2f): pam-u2f.c:415 (pam_sm_authenticate): done. [Authentication failure]")
+	h.run()
+
+	failures, err := h.store.GetFailedAttempts(now.Add(-time.Hour), storage.EventFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || failures[0].IP != "203.0.113.5" || failures[0].Method != parser.MFAGoogleAuthenticator {
+		t.Errorf("expected one google_authenticator failure from 203.0.113.5, got %+v", failures)
+	}
+	ips, err := h.store.GetTopIPs(now.Add(-time.Hour), now, 10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ip := range ips {
+		if ip.IP == "" {
+			t.Errorf("expected no failures without an address, got %+v", ips)
+		}
+	}
+}
+
+func TestPipelineUnconfirmedSSSD(t *testing.T) {
+	h := newHarness(t)
+	now := time.Now().Truncate(time.Second)
+	h.sshd(now.Add(-2*time.Second), "pam_sss(sshd:auth): authentication success; logname= uid=0 euid=0 tty=ssh ruser= rhost=198.51.100.7 user=alice@example.com")
+	h.sshd(now.Add(-time.Second), "pam_sss(sshd:auth): authentication failure; logname= uid=0 euid=0 tty=ssh ruser= rhost=203.0.113.5 user=bob@example.com")
+	h.run()
+
+	// Without a matching sshd line the success is not a login, while the
+	// failure is still an attempt.
+	stats, err := h.store.GetOverallStats(now.Add(-time.Hour), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.SuccessCount != 0 || stats.FailedCount != 1 {
+		t.Errorf("unexpected stored events: %+v", stats)
+	}
+	if alerts := h.alerts(); len(alerts) != 0 {
+		t.Errorf("expected no login alert, got %+v", alerts)
+	}
+}
+
+func TestPipelineSSSDPairing(t *testing.T) {
+	tests := []struct {
+		name  string
+		rhost string
+	}{
+		{"address", "198.51.100.7"},
+		{"hostname", "client.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			now := time.Now().Truncate(time.Second)
+			h.entryPID("sshd", "4242", now.Add(-4*time.Second), "pam_sss(sshd:auth): authentication success; logname= uid=0 euid=0 tty=ssh ruser= rhost="+tt.rhost+" user=alice")
+			h.entryPID("sshd", "4242", now.Add(-4*time.Second), "Accepted password for alice from 198.51.100.7 port 50022 ssh2")
+			h.entryPID("sshd", "4243", now.Add(-2*time.Second), "pam_sss(sshd:auth): authentication failure; logname= uid=0 euid=0 tty=ssh ruser= rhost="+tt.rhost+" user=alice")
+			h.entryPID("sshd", "4243", now.Add(-2*time.Second), "Failed password for alice from 198.51.100.7 port 50023 ssh2")
+			h.run()
+
+			// Each pam_sss result is merged into its sshd line, not counted
+			// again when it expires.
+			stats, err := h.store.GetOverallStats(now.Add(-time.Hour), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.SuccessCount != 1 || stats.FailedCount != 1 {
+				t.Errorf("unexpected stored events: %+v", stats)
+			}
+			logins, err := h.store.GetSuccessfulLogins(now.Add(-time.Hour), storage.EventFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			failures, err := h.store.GetFailedAttempts(now.Add(-time.Hour), storage.EventFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(logins) != 1 || logins[0].AuthSource != parser.AuthSourceSSSD || logins[0].IP != "198.51.100.7" {
+				t.Errorf("expected one sssd login from the sshd address, got %+v", logins)
+			}
+			if len(failures) != 1 || failures[0].AuthSource != parser.AuthSourceSSSD {
+				t.Errorf("expected one sssd failure, got %+v", failures)
+			}
+		})
+	}
+}
+
+func TestBurstAlertShowsFinalCount(t *testing.T) {
+	h := newHarness(t)
+	h.cfg.WebFailureThreshold = 2
+	d := h.daemon()
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, offset := range []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second} {
+		event := fixture.Failure("", "203.0.113.5").At(start.Add(offset)).Service(parser.ServiceWeb).Build()
+		event.Method = parser.MethodHTTPAuth
+		d.handleEvent(event)
+	}
+
+	// The hits after the alert came faster than the edit interval.
+	alerts := h.alerts()
+	if len(alerts) != 1 || alerts[0].Edits != 0 {
+		t.Fatalf("expected one unedited burst alert, got %+v", alerts)
+	}
+
+	d.endAttacks(start.Add(time.Hour))
+	alerts = h.alerts()
+	if alerts[0].Edits != 1 || !strings.Contains(alerts[0].Text, "Attempts: 4 from") || !strings.Contains(alerts[0].Text, "(ended)") {
+		t.Errorf("expected the alert to show the final count:\n%s", alerts[0].Text)
+	}
+	if d.attacks.Len() != 0 {
+		t.Errorf("expected the attack to end, %d left", d.attacks.Len())
+	}
+}
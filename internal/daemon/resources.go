@@ -0,0 +1,85 @@
+package daemon
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/notifier"
+)
+
+const (
+	// resourceWarnRatio is the share of a limit at which the daemon starts
+	// throttling itself; it resumes below resourceRecoverRatio so usage
+	// hovering around the limit does not flap.
+	resourceWarnRatio    = 0.9
+	resourceRecoverRatio = 0.7
+)
+
+type resourceUsage struct {
+	memoryMB   int
+	goroutines int
+}
+
+// limitMemory sets the Go runtime's soft memory limit, so the garbage
+// collector works harder before the process reaches memory_limit_mb.
+func (d *Daemon) limitMemory() {
+	if d.cfg.MemoryLimitMB > 0 {
+		debug.SetMemoryLimit(int64(d.cfg.MemoryLimitMB) << 20)
+	}
+}
+
+func currentUsage() resourceUsage {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return resourceUsage{
+		memoryMB:   int((m.Sys - m.HeapReleased) >> 20),
+		goroutines: runtime.NumGoroutine(),
+	}
+}
+
+func (d *Daemon) checkResources(ctx context.Context) error {
+	return d.applyResourceUsage(currentUsage(), d.clock.Now())
+}
+
+// applyResourceUsage throttles the daemon when usage approaches a limit:
+// optional per-event lookups are paused and in-memory trackers pruned. Login
+// alerts, storage and burst detection keep working.
+func (d *Daemon) applyResourceUsage(u resourceUsage, now time.Time) error {
+	ratio := max(usageRatio(u.memoryMB, d.cfg.MemoryLimitMB), usageRatio(u.goroutines, d.cfg.GoroutineLimit))
+
+	throttled := d.throttled.Load()
+	switch {
+	case !throttled && ratio >= resourceWarnRatio:
+		d.throttled.Store(true)
+		d.logger.Warn("resource limit approached, throttling", "memory_mb", u.memoryMB, "goroutines", u.goroutines)
+		d.pruneTrackers(now)
+		debug.FreeOSMemory()
+	case throttled && ratio < resourceRecoverRatio:
+		d.throttled.Store(false)
+		d.logger.Info("resource usage recovered, resuming lookups", "memory_mb", u.memoryMB, "goroutines", u.goroutines)
+	default:
+		return nil
+	}
+
+	severity, message := notifier.SeverityWarning, "resource limit approached, throttling"
+	if throttled {
+		severity, message = notifier.SeverityInfo, "resource usage recovered"
+	}
+	d.emit("resources", severity, message, map[string]string{
+		"memory_mb":       strconv.Itoa(u.memoryMB),
+		"memory_limit_mb": strconv.Itoa(d.cfg.MemoryLimitMB),
+		"goroutines":      strconv.Itoa(u.goroutines),
+		"goroutine_limit": strconv.Itoa(d.cfg.GoroutineLimit),
+	})
+	return d.telegram.SendResourceAlert(u.memoryMB, d.cfg.MemoryLimitMB, u.goroutines, d.cfg.GoroutineLimit, !throttled)
+}
+
+func usageRatio(used, limit int) float64 {
+	if limit <= 0 {
+		return 0
+	}
+	return float64(used) / float64(limit)
+}
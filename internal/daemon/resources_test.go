@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResourceThrottling(t *testing.T) {
+	h := newHarness(t)
+	h.cfg.MemoryLimitMB = 100
+	h.cfg.GoroutineLimit = 200
+	h.addFailure("203.0.113.5", time.Hour)
+
+	d := h.daemon()
+	now := time.Now()
+
+	if err := d.applyResourceUsage(resourceUsage{memoryMB: 60, goroutines: 150}, now); err != nil {
+		t.Fatal(err)
+	}
+	if d.throttled.Load() || len(h.alerts()) != 0 {
+		t.Fatal("expected no throttling below the limits")
+	}
+
+	// Goroutines at 95% of their limit throttle even with memory well below.
+	if err := d.applyResourceUsage(resourceUsage{memoryMB: 60, goroutines: 190}, now); err != nil {
+		t.Fatal(err)
+	}
+	if !d.throttled.Load() {
+		t.Fatal("expected throttling near the goroutine limit")
+	}
+	if history := d.ipHistory("203.0.113.5"); history != "" {
+		t.Errorf("expected IP history lookups to be paused, got %q", history)
+	}
+
+	// Between the recovery and warning ratios nothing changes.
+	if err := d.applyResourceUsage(resourceUsage{memoryMB: 80, goroutines: 10}, now); err != nil {
+		t.Fatal(err)
+	}
+	if !d.throttled.Load() {
+		t.Fatal("expected throttling to continue above the recovery ratio")
+	}
+
+	if err := d.applyResourceUsage(resourceUsage{memoryMB: 50, goroutines: 10}, now); err != nil {
+		t.Fatal(err)
+	}
+	if d.throttled.Load() {
+		t.Fatal("expected throttling to end after recovery")
+	}
+	if history := d.ipHistory("203.0.113.5"); !strings.HasPrefix(history, "seen 1 time") {
+		t.Errorf("expected IP history after recovery, got %q", history)
+	}
+
+	alerts := h.alerts()
+	if len(alerts) != 2 {
+		t.Fatalf("expected a warning and a recovery notice, got %d alerts", len(alerts))
+	}
+	if !strings.Contains(alerts[0].Text, "Resource Limit Approached") || !strings.Contains(alerts[0].Text, "Goroutines: 190 of 200 (95%)") {
+		t.Errorf("unexpected warning:\n%s", alerts[0].Text)
+	}
+	if !strings.Contains(alerts[1].Text, "Resource Usage Recovered") {
+		t.Errorf("unexpected recovery notice:\n%s", alerts[1].Text)
+	}
+}
@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/clock"
+	"github.com/oxisoft/oxiwatch/internal/config"
+	"github.com/oxisoft/oxiwatch/internal/notifier"
+	"github.com/oxisoft/oxiwatch/internal/scheduler"
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+// SimulatedMessage is a notification sent during a simulation, with the
+// simulated time it was sent at.
+type SimulatedMessage struct {
+	At   time.Time
+	Text string
+}
+
+func (d *Daemon) setClock(c clock.Clock) {
+	d.clock = c
+	d.scheduler.SetClock(c)
+}
+
+// Simulate fast-forwards the scheduled tasks (reports, rollups, retention
+// cleanup, spike checks) over days from start on a fake clock against store,
+// and returns the notifications they would have sent. Checks of the host
+// itself and database downloads are left out since they cannot be replayed.
+func Simulate(cfg *config.Config, logger *slog.Logger, version string, store storage.Store, start time.Time, days int) ([]SimulatedMessage, error) {
+	simCfg := *cfg
+	simCfg.GeoIPEnabled = false
+	simCfg.ExposureCheckEnabled = false
+	simCfg.AdvisoryCheckEnabled = false
+	simCfg.DiskMinFreeMB = 0
+	simCfg.MemoryLimitMB = 0
+	simCfg.GoroutineLimit = 0
+	simCfg.ClockJumpThresholdSeconds = 0
+
+	recorder := notifier.NewRecorder()
+	telegram := notifier.NewWithTransport(recorder, cfg.ServerName, cfg.Metadata)
+	d := newDaemon(&simCfg, logger, version, store, telegram, nil)
+	if cfg.DailyReportTemplate != "" {
+		if err := d.report.LoadTemplate(cfg.DailyReportTemplate); err != nil {
+			return nil, fmt.Errorf("failed to load daily report template: %w", err)
+		}
+	}
+
+	clk := clock.NewFake(start)
+	d.setClock(clk)
+	// The simulated host is up from the start of the simulation.
+	d.report.SetUptime(func() (time.Duration, error) { return clk.Now().Sub(start), nil })
+	if err := d.scheduleTasks(); err != nil {
+		return nil, err
+	}
+	d.scheduler.Resume(start)
+
+	ctx := context.Background()
+	end := start.AddDate(0, 0, days)
+	var messages []SimulatedMessage
+	for clk.Now().Before(end) {
+		clk.Advance(scheduler.TickInterval)
+		d.scheduler.Tick(ctx)
+		for _, m := range recorder.Messages()[len(messages):] {
+			messages = append(messages, SimulatedMessage{At: clk.Now(), Text: m.Text})
+		}
+	}
+	return messages, nil
+}
@@ -0,0 +1,58 @@
+package daemon
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/fixture"
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+)
+
+func TestSimulate(t *testing.T) {
+	h := newHarness(t)
+	h.cfg.DailyReportEnabled = true
+	h.cfg.DailyReportTime = "08:00"
+	h.cfg.DailyReportTimezone = "UTC"
+	h.cfg.RetentionDays = 30
+	// Host checks are left out even when configured.
+	h.cfg.AdvisoryCheckEnabled = true
+
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	// Inside retention at the start, older than it from the second day on.
+	old := fixture.Failure("root", "203.0.113.5").At(start.AddDate(0, 0, -29)).Build()
+	if _, err := h.store.InsertEvent(old, geoip.Location{}); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	messages, err := Simulate(h.cfg, logger, "test", h.store, start, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 3 {
+		t.Fatalf("expected a daily report on each of the 3 days, got %d messages", len(messages))
+	}
+	for i, m := range messages {
+		if want := start.AddDate(0, 0, i).Add(8 * time.Hour); !m.At.Equal(want) {
+			t.Errorf("report %d sent at %v, want %v", i, m.At, want)
+		}
+		if strings.Contains(m.Text, "OpenSSH Advisories") {
+			t.Errorf("report %d checked the host for advisories:\n%s", i, m.Text)
+		}
+	}
+	if !strings.Contains(messages[1].Text, "Uptime: 1d 8h") {
+		t.Errorf("expected the simulated uptime in the report:\n%s", messages[1].Text)
+	}
+
+	stats, err := h.store.GetOverallStats(time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.FailedCount != 0 {
+		t.Errorf("expected retention cleanup to remove the old failure, %d left", stats.FailedCount)
+	}
+}
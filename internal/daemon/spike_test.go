@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/fixture"
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+)
+
+func TestSpikeAlert(t *testing.T) {
+	h := newHarness(t)
+	h.cfg.SpikeAlertMultiplier = 10
+	h.cfg.SpikeAlertMinAttempts = 20
+	h.cfg.SpikeBaselineDays = 7
+
+	// One failure every two hours for a week is an hourly average of 0.5.
+	for i := 0; i < 7*12; i++ {
+		h.addFailure(fmt.Sprintf("198.51.100.%d", i%200), time.Duration(2*i+2)*time.Hour)
+	}
+
+	d := h.daemon()
+	ctx := context.Background()
+
+	// 19 attempts is well above 10x the baseline but below the minimum.
+	for i := 0; i < 19; i++ {
+		h.addFailure(fmt.Sprintf("203.0.113.%d", i), time.Duration(i+1)*time.Minute)
+	}
+	if err := d.checkSpike(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if alerts := h.alerts(); len(alerts) != 0 {
+		t.Fatalf("expected no alert below the minimum, got %d", len(alerts))
+	}
+
+	h.addFailure("203.0.113.200", 30*time.Second)
+	for i := 0; i < 2; i++ {
+		if err := d.checkSpike(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	alerts := h.alerts()
+	if len(alerts) != 1 {
+		t.Fatalf("expected one spike alert, got %d", len(alerts))
+	}
+	if !strings.Contains(alerts[0].Text, "Last hour: 20 failed attempts") || !strings.Contains(alerts[0].Text, "40.0x the 7-day hourly average") {
+		t.Errorf("unexpected alert:\n%s", alerts[0].Text)
+	}
+}
+
+func (h *harness) addFailure(ip string, ago time.Duration) {
+	h.t.Helper()
+	if _, err := h.store.InsertEvent(fixture.Failure("root", ip).Ago(ago).Build(), geoip.Location{}); err != nil {
+		h.t.Fatal(err)
+	}
+}
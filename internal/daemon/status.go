@@ -0,0 +1,148 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/notifier"
+	"github.com/oxisoft/oxiwatch/internal/output"
+	"github.com/oxisoft/oxiwatch/internal/scheduler"
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+const stateDaemonStatus = "daemon_status"
+
+// statusInterval is how often the status snapshot is saved. A snapshot much
+// older than this means the daemon is stopped or its main loop is stuck.
+const statusInterval = time.Minute
+
+// Status is a snapshot of the daemon's internal state for debugging. It is
+// logged on SIGUSR1 and saved for `oxiwatch status`.
+type Status struct {
+	Time          time.Time                    `json:"time"`
+	Version       string                       `json:"version"`
+	Started       time.Time                    `json:"started"`
+	LastEvent     time.Time                    `json:"last_event,omitempty"`
+	Events        int                          `json:"events"`
+	Queues        map[string]int               `json:"queues"`
+	PendingSSSD   int                          `json:"pending_sssd"`
+	ActiveAttacks int                          `json:"active_attacks"`
+	Cooldowns     map[string]int               `json:"cooldowns"`
+	LowDisk       bool                         `json:"low_disk"`
+	Spike         bool                         `json:"spike"`
+	Throttled     bool                         `json:"throttled"`
+	Channels      map[string]notifier.Failures `json:"channels"`
+	NextRuns      []scheduler.NextRun          `json:"next_runs"`
+}
+
+func (d *Daemon) status(now time.Time) Status {
+	journalQueue := 0
+	if d.journal != nil {
+		journalQueue = len(d.journal.Events())
+	}
+	channels := map[string]notifier.Failures{"telegram": d.telegram.Failures()}
+	for _, o := range d.outputs {
+		if f, ok := o.(*output.Forward); ok {
+			channels["forward"] = f.Failures()
+		}
+	}
+	return Status{
+		Time:      now,
+		Version:   d.version,
+		Started:   d.started,
+		LastEvent: d.lastEvent,
+		Events:    d.events,
+		Queues: map[string]int{
+			"journal":   journalQueue,
+			"log_files": len(d.logEvents),
+			"failures":  d.failures.Len(),
+		},
+		PendingSSSD:   d.pendingSSSD.Len(),
+		ActiveAttacks: d.attacks.Len(),
+		Cooldowns: map[string]int{
+			"web_failures": d.webBursts.Cooldowns(now),
+			"web_admin":    d.adminBursts.Cooldowns(now),
+		},
+		LowDisk:   d.lowDisk.Load(),
+		Spike:     d.spiking.Load(),
+		Throttled: d.throttled.Load(),
+		Channels:  channels,
+		NextRuns:  d.scheduler.NextRuns(now),
+	}
+}
+
+// dumpStatus logs the current state and saves it for `oxiwatch status`.
+func (d *Daemon) dumpStatus(log bool) {
+	data, err := json.Marshal(d.status(d.clock.Now()))
+	if err != nil {
+		d.logger.Error("failed to encode status", "error", err)
+		return
+	}
+	if log {
+		d.logger.Info("state dump", "status", string(data))
+	}
+	if err := d.storage.SetState(stateDaemonStatus, string(data)); err != nil {
+		d.logger.Warn("failed to save status", "error", err)
+	}
+}
+
+// LoadStatus returns the last status snapshot saved by the daemon, or nil
+// if it has never saved one.
+func LoadStatus(store storage.Store) (*Status, error) {
+	value, err := store.GetState(stateDaemonStatus)
+	if err != nil || value == "" {
+		return nil, err
+	}
+	var status Status
+	if err := json.Unmarshal([]byte(value), &status); err != nil {
+		return nil, fmt.Errorf("invalid status snapshot: %w", err)
+	}
+	return &status, nil
+}
+
+// Format renders the snapshot for the terminal, warning when it is too old
+// to describe a running daemon.
+func (s *Status) Format(now time.Time) string {
+	var buf bytes.Buffer
+	age := now.Sub(s.Time).Round(time.Second)
+	buf.WriteString(fmt.Sprintf("Snapshot: %s (%s ago)\n", s.Time.Format("2006-01-02 15:04:05"), age))
+	if age > 3*statusInterval {
+		buf.WriteString("Warning: snapshot is stale, the daemon is stopped or stuck.\n")
+	}
+	buf.WriteString(fmt.Sprintf("Version: %s, running since %s\n", s.Version, s.Started.Format("2006-01-02 15:04:05")))
+
+	lastEvent := "none"
+	if !s.LastEvent.IsZero() {
+		lastEvent = s.LastEvent.Format("2006-01-02 15:04:05")
+	}
+	buf.WriteString(fmt.Sprintf("Events handled: %d, last at %s\n", s.Events, lastEvent))
+	buf.WriteString(fmt.Sprintf("Queued events: journal %d, log files %d, pending SSSD %d, batched failure buckets %d\n",
+		s.Queues["journal"], s.Queues["log_files"], s.PendingSSSD, s.Queues["failures"]))
+	buf.WriteString(fmt.Sprintf("Active attacks: %d\n", s.ActiveAttacks))
+	buf.WriteString(fmt.Sprintf("Burst cooldowns: web failures %d, web admin %d\n", s.Cooldowns["web_failures"], s.Cooldowns["web_admin"]))
+	buf.WriteString(fmt.Sprintf("Low disk mode: %t, failed-attempt spike: %t, throttled: %t\n", s.LowDisk, s.Spike, s.Throttled))
+
+	buf.WriteString("\nChannel failures:\n")
+	channels := make([]string, 0, len(s.Channels))
+	for name := range s.Channels {
+		channels = append(channels, name)
+	}
+	sort.Strings(channels)
+	for _, name := range channels {
+		f := s.Channels[name]
+		if f.Count == 0 {
+			buf.WriteString(fmt.Sprintf("  %-10s  none\n", name))
+			continue
+		}
+		buf.WriteString(fmt.Sprintf("  %-10s  %d, last at %s: %s\n", name, f.Count, f.LastAt.Format("2006-01-02 15:04:05"), f.LastError))
+	}
+
+	buf.WriteString("\nNext scheduled runs:\n")
+	for _, r := range s.NextRuns {
+		buf.WriteString(fmt.Sprintf("  %-18s  %s\n", r.Name, r.At.Local().Format("2006-01-02 15:04:05")))
+	}
+	return buf.String()
+}
@@ -0,0 +1,54 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/config"
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+// trustedRefreshInterval is how soon networks approved with `oxiwatch
+// trusted approve` take effect in a running daemon.
+const trustedRefreshInterval = time.Minute
+
+// TrustedNetworks returns trusted_networks from the config together with
+// the networks approved with `oxiwatch trusted approve`.
+func TrustedNetworks(cfg *config.Config, store storage.Store) ([]netip.Prefix, error) {
+	trusted, err := cfg.TrustedPrefixes()
+	if err != nil {
+		return nil, err
+	}
+	approved, err := store.GetTrustedNetworks()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range approved {
+		prefix, err := netip.ParsePrefix(n.IPRange)
+		if err != nil {
+			return nil, fmt.Errorf("invalid approved network %q: %w", n.IPRange, err)
+		}
+		trusted = append(trusted, prefix)
+	}
+	return trusted, nil
+}
+
+// refreshTrusted reloads the trusted networks so approvals and removals
+// apply without a restart.
+func (d *Daemon) refreshTrusted(ctx context.Context) error {
+	trusted, err := TrustedNetworks(d.cfg, d.storage)
+	if err != nil {
+		return err
+	}
+	d.trusted.Store(&trusted)
+	return nil
+}
+
+func (d *Daemon) trustedNetworks() []netip.Prefix {
+	if trusted := d.trusted.Load(); trusted != nil {
+		return *trusted
+	}
+	return nil
+}
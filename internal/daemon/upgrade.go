@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"github.com/oxisoft/oxiwatch/internal/notifier"
+	"github.com/oxisoft/oxiwatch/internal/version"
+)
+
+const stateVersion = "last_version"
+
+// whatsNewLines is how many lines of the release notes are sent.
+const whatsNewLines = 8
+
+// checkUpgrade sends a "what's new" message on the first start of a newer
+// version, whether it was installed with `oxiwatch upgrade` or otherwise.
+// It fetches the release notes, so Run calls it in the background.
+func (d *Daemon) checkUpgrade() {
+	previous, err := d.storage.GetState(stateVersion)
+	if err != nil {
+		d.logger.Warn("failed to load previous version", "error", err)
+		return
+	}
+	if previous == d.version {
+		return
+	}
+
+	if previous != "" && d.version != "dev" && version.IsNewer(d.version, previous) {
+		d.logger.Info("first start after upgrade", "previous", previous, "version", d.version)
+		if d.cfg.WhatsNewEnabled {
+			if err := d.announceUpgrade(previous); err != nil {
+				d.logger.Warn("failed to send what's new message", "error", err)
+				return
+			}
+		}
+	}
+
+	if err := d.storage.SetState(stateVersion, d.version); err != nil {
+		d.logger.Warn("failed to save version", "error", err)
+	}
+}
+
+// fetchRelease looks up the release notes of a version on GitHub.
+func fetchRelease(current string) (*version.Release, error) {
+	return version.NewChecker(current).GetRelease(current)
+}
+
+func (d *Daemon) announceUpgrade(previous string) error {
+	var notes []string
+	url := version.ReleasesURL
+	release, err := d.releaseNotes(d.version)
+	if err != nil {
+		d.logger.Warn("failed to fetch release notes", "version", d.version, "error", err)
+	} else {
+		notes = version.SummarizeNotes(release.Body, whatsNewLines)
+		if release.HTMLURL != "" {
+			url = release.HTMLURL
+		}
+	}
+
+	d.emit("upgrade", notifier.SeverityInfo, "upgraded from "+previous+" to "+d.version, map[string]string{
+		"previous": previous,
+		"version":  d.version,
+	})
+	return d.telegram.SendWhatsNew(previous, d.version, notes, url)
+}
@@ -0,0 +1,56 @@
+package daemon
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/oxisoft/oxiwatch/internal/version"
+)
+
+func TestWhatsNewAfterUpgrade(t *testing.T) {
+	h := newHarness(t)
+	d := h.daemon()
+	d.releaseNotes = func(v string) (*version.Release, error) {
+		return &version.Release{
+			TagName: "v" + v,
+			Body:    "## Changes\n- Attack campaigns\n- Trusted networks\n",
+			HTMLURL: "https://github.com/oxisoft/oxiwatch/releases/tag/v" + v,
+		}, nil
+	}
+
+	// The first start only records the version.
+	d.version = "1.0.0"
+	d.checkUpgrade()
+	if alerts := h.alerts(); len(alerts) != 0 {
+		t.Fatalf("expected no message on the first start, got %+v", alerts)
+	}
+
+	d.version = "1.1.0"
+	d.checkUpgrade()
+	d.checkUpgrade()
+	alerts := h.alerts()
+	if len(alerts) != 1 {
+		t.Fatalf("expected one what's new message, got %d", len(alerts))
+	}
+	for _, want := range []string{"OxiWatch Upgraded", "1.0.0 → 1.1.0", "• Attack campaigns\n• Trusted networks", "releases/tag/v1.1.0"} {
+		if !strings.Contains(alerts[0].Text, want) {
+			t.Errorf("expected %q in message:\n%s", want, alerts[0].Text)
+		}
+	}
+
+	// A downgrade is recorded without a message, notes that cannot be
+	// fetched leave only the link.
+	d.version = "1.0.5"
+	d.checkUpgrade()
+	d.releaseNotes = func(string) (*version.Release, error) { return nil, errors.New("offline") }
+	d.version = "1.2.0"
+	d.checkUpgrade()
+	alerts = h.alerts()
+	if len(alerts) != 2 || !strings.Contains(alerts[1].Text, "1.0.5 → 1.2.0") || !strings.Contains(alerts[1].Text, version.ReleasesURL) {
+		t.Fatalf("unexpected messages: %+v", alerts)
+	}
+	if strings.Contains(alerts[1].Text, "What's new") {
+		t.Errorf("expected no notes without release notes:\n%s", alerts[1].Text)
+	}
+}
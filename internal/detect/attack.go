@@ -0,0 +1,111 @@
+package detect
+
+import (
+	"sync"
+	"time"
+)
+
+// Attack is an ongoing burst that already produced an alert message, which
+// is edited with new counts instead of sending another one.
+type Attack struct {
+	Key       string
+	MessageID int
+	History   string
+	Count     int
+	First     time.Time
+	Last      time.Time
+	lastEdit  time.Time
+	shown     int
+}
+
+type AttackTracker struct {
+	mu           sync.Mutex
+	idle         time.Duration
+	editInterval time.Duration
+	attacks      map[string]*Attack
+}
+
+// NewAttackTracker ends an attack after idle without hits; editInterval
+// limits how often its message is edited to stay within Telegram rate limits.
+func NewAttackTracker(idle, editInterval time.Duration) *AttackTracker {
+	return &AttackTracker{
+		idle:         idle,
+		editInterval: editInterval,
+		attacks:      make(map[string]*Attack),
+	}
+}
+
+func (a *AttackTracker) Start(key string, messageID int, history string, count int, ts time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.attacks[key] = &Attack{
+		Key:       key,
+		MessageID: messageID,
+		History:   history,
+		Count:     count,
+		First:     ts,
+		Last:      ts,
+		lastEdit:  ts,
+		shown:     count,
+	}
+}
+
+// Hit counts another attempt for an ongoing attack. ok is false when there
+// is none for key; edit is true when the alert message is due for an update.
+func (a *AttackTracker) Hit(key string, ts time.Time) (attack Attack, ok, edit bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	current, ok := a.attacks[key]
+	if !ok {
+		return Attack{}, false, false
+	}
+	if ts.Sub(current.Last) > a.idle {
+		delete(a.attacks, key)
+		return Attack{}, false, false
+	}
+
+	current.Count++
+	if ts.After(current.Last) {
+		current.Last = ts
+	}
+	edit = ts.Sub(current.lastEdit) >= a.editInterval
+	if edit {
+		current.lastEdit = ts
+		current.shown = current.Count
+	}
+	return *current, true, edit
+}
+
+// Prune ends attacks idle for longer than the idle time. It returns those
+// whose message does not show the final count yet, since edits are only
+// made on hits and the last ones may have been throttled.
+func (a *AttackTracker) Prune(now time.Time) []Attack {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var stale []Attack
+	for key, attack := range a.attacks {
+		if now.Sub(attack.Last) > a.idle {
+			if attack.shown != attack.Count {
+				stale = append(stale, *attack)
+			}
+			delete(a.attacks, key)
+		}
+	}
+	return stale
+}
+
+func (a *AttackTracker) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.attacks)
+}
+
+func (a *AttackTracker) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.attacks = make(map[string]*Attack)
+}
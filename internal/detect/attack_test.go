@@ -0,0 +1,114 @@
+package detect
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAttackTrackerHit(t *testing.T) {
+	start := time.Date(2026, 1, 20, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		hits      []time.Duration
+		wantOK    []bool
+		wantEdit  []bool
+		wantCount int
+	}{
+		{
+			name:      "edits are throttled",
+			hits:      []time.Duration{10 * time.Second, 20 * time.Second, 31 * time.Second, 40 * time.Second},
+			wantOK:    []bool{true, true, true, true},
+			wantEdit:  []bool{false, false, true, false},
+			wantCount: 5,
+		},
+		{
+			name:      "idle attack ends",
+			hits:      []time.Duration{time.Minute, 12 * time.Minute},
+			wantOK:    []bool{true, false},
+			wantEdit:  []bool{true, false},
+			wantCount: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAttackTracker(10*time.Minute, 30*time.Second)
+			a.Start("burst|203.0.113.5", 7, "first time seen", 1, start)
+
+			var last Attack
+			for i, offset := range tt.hits {
+				attack, ok, edit := a.Hit("burst|203.0.113.5", start.Add(offset))
+				if ok != tt.wantOK[i] || edit != tt.wantEdit[i] {
+					t.Errorf("hit %d: got ok=%t edit=%t, want ok=%t edit=%t", i, ok, edit, tt.wantOK[i], tt.wantEdit[i])
+				}
+				if ok {
+					last = attack
+				}
+			}
+			if last.Count != tt.wantCount || last.MessageID != 7 || !last.First.Equal(start) {
+				t.Errorf("unexpected attack: %+v", last)
+			}
+		})
+	}
+}
+
+func TestAttackTrackerUnknownKey(t *testing.T) {
+	a := NewAttackTracker(10*time.Minute, 30*time.Second)
+	if _, ok, _ := a.Hit("burst|203.0.113.5", time.Now()); ok {
+		t.Error("expected no attack before Start")
+	}
+}
+
+func TestAttackTrackerPrune(t *testing.T) {
+	start := time.Date(2026, 1, 20, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		hits      []time.Duration
+		pruneAt   time.Duration
+		wantLeft  int
+		wantStale int
+	}{
+		{"ongoing attack is kept", []time.Duration{time.Minute}, 5 * time.Minute, 1, 0},
+		{"final count already shown", []time.Duration{time.Minute}, 12 * time.Minute, 0, 0},
+		{"final count not shown yet", []time.Duration{time.Minute, time.Minute + 10*time.Second}, 12 * time.Minute, 0, 1},
+		{"no hits after the alert", nil, 11 * time.Minute, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAttackTracker(10*time.Minute, 30*time.Second)
+			a.Start("burst|203.0.113.5", 7, "", 20, start)
+			for _, offset := range tt.hits {
+				a.Hit("burst|203.0.113.5", start.Add(offset))
+			}
+
+			stale := a.Prune(start.Add(tt.pruneAt))
+			if a.Len() != tt.wantLeft {
+				t.Errorf("expected %d attacks left, got %d", tt.wantLeft, a.Len())
+			}
+			if len(stale) != tt.wantStale {
+				t.Fatalf("expected %d attacks to edit, got %+v", tt.wantStale, stale)
+			}
+			if len(stale) > 0 && (stale[0].Key != "burst|203.0.113.5" || stale[0].Count != 20+len(tt.hits)) {
+				t.Errorf("unexpected attack to edit: %+v", stale[0])
+			}
+		})
+	}
+}
+
+func TestAttackTrackerReset(t *testing.T) {
+	now := time.Now()
+	a := NewAttackTracker(10*time.Minute, 30*time.Second)
+	a.Start("burst|203.0.113.5", 7, "", 20, now)
+	a.Start("burst|198.51.100.7", 8, "", 20, now)
+
+	a.Reset()
+	if a.Len() != 0 {
+		t.Errorf("expected no attacks after Reset, got %d", a.Len())
+	}
+	if _, ok, _ := a.Hit("burst|203.0.113.5", now); ok {
+		t.Error("expected the attack to be forgotten")
+	}
+}
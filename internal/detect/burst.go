@@ -0,0 +1,92 @@
+package detect
+
+import (
+	"sync"
+	"time"
+)
+
+type BurstTracker struct {
+	mu        sync.Mutex
+	threshold int
+	window    time.Duration
+	hits      map[string][]time.Time
+	alerted   map[string]time.Time
+}
+
+func NewBurstTracker(threshold int, window time.Duration) *BurstTracker {
+	return &BurstTracker{
+		threshold: threshold,
+		window:    window,
+		hits:      make(map[string][]time.Time),
+		alerted:   make(map[string]time.Time),
+	}
+}
+
+// Add records a hit for key and reports the number of hits inside the window.
+// fire is true the first time the threshold is reached; further hits for the
+// same key stay quiet until a full window has passed since that alert.
+func (b *BurstTracker) Add(key string, ts time.Time) (count int, fire bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cutoff := ts.Add(-b.window)
+	hits := b.hits[key]
+	i := 0
+	for i < len(hits) && hits[i].Before(cutoff) {
+		i++
+	}
+	hits = append(hits[i:], ts)
+	b.hits[key] = hits
+
+	if b.threshold <= 0 || len(hits) < b.threshold {
+		return len(hits), false
+	}
+
+	if last, ok := b.alerted[key]; ok && ts.Sub(last) < b.window {
+		return len(hits), false
+	}
+	b.alerted[key] = ts
+	return len(hits), true
+}
+
+func (b *BurstTracker) Prune(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cutoff := now.Add(-b.window)
+	for key, hits := range b.hits {
+		if len(hits) == 0 || hits[len(hits)-1].Before(cutoff) {
+			delete(b.hits, key)
+		}
+	}
+	for key, last := range b.alerted {
+		if last.Before(cutoff) {
+			delete(b.alerted, key)
+		}
+	}
+}
+
+// Cooldowns returns how many keys alerted within the last window and are
+// therefore kept quiet.
+func (b *BurstTracker) Cooldowns(now time.Time) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := 0
+	for _, last := range b.alerted {
+		if now.Sub(last) < b.window {
+			n++
+		}
+	}
+	return n
+}
+
+// Reset forgets all hits and cooldowns, e.g. after the system clock jumped and
+// the stored timestamps can no longer be compared with new ones.
+func (b *BurstTracker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.hits = make(map[string][]time.Time)
+	b.alerted = make(map[string]time.Time)
+}
@@ -0,0 +1,97 @@
+package detect
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBurstTrackerAdd(t *testing.T) {
+	start := time.Date(2026, 1, 20, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		threshold int
+		hits      []time.Duration
+		wantFire  []bool
+		wantCount int
+	}{
+		{
+			name:      "fires once at the threshold",
+			threshold: 3,
+			hits:      []time.Duration{0, time.Minute, 2 * time.Minute, 3 * time.Minute},
+			wantFire:  []bool{false, false, true, false},
+			wantCount: 4,
+		},
+		{
+			name:      "hits outside the window do not count",
+			threshold: 3,
+			hits:      []time.Duration{0, time.Minute, 12 * time.Minute, 13 * time.Minute},
+			wantFire:  []bool{false, false, false, false},
+			wantCount: 2,
+		},
+		{
+			name:      "fires again after a full window",
+			threshold: 2,
+			hits:      []time.Duration{0, time.Minute, 5 * time.Minute, 11 * time.Minute, 12 * time.Minute},
+			wantFire:  []bool{false, true, false, true, false},
+			wantCount: 3,
+		},
+		{
+			name:      "zero threshold never fires",
+			threshold: 0,
+			hits:      []time.Duration{0, 0, 0},
+			wantFire:  []bool{false, false, false},
+			wantCount: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBurstTracker(tt.threshold, 10*time.Minute)
+			var count int
+			for i, offset := range tt.hits {
+				var fire bool
+				count, fire = b.Add("203.0.113.5", start.Add(offset))
+				if fire != tt.wantFire[i] {
+					t.Errorf("hit %d: got fire=%t, want %t", i, fire, tt.wantFire[i])
+				}
+			}
+			if count != tt.wantCount {
+				t.Errorf("expected %d hits in the window, got %d", tt.wantCount, count)
+			}
+		})
+	}
+}
+
+func TestBurstTrackerKeysAreSeparate(t *testing.T) {
+	now := time.Now()
+	b := NewBurstTracker(2, 10*time.Minute)
+	b.Add("203.0.113.5", now)
+	if _, fire := b.Add("198.51.100.7", now); fire {
+		t.Error("expected hits from another IP not to count")
+	}
+}
+
+func TestBurstTrackerCooldownsAndPrune(t *testing.T) {
+	start := time.Date(2026, 1, 20, 14, 0, 0, 0, time.UTC)
+	b := NewBurstTracker(1, 10*time.Minute)
+	b.Add("203.0.113.5", start)
+	b.Add("198.51.100.7", start.Add(5*time.Minute))
+
+	if n := b.Cooldowns(start.Add(6 * time.Minute)); n != 2 {
+		t.Errorf("expected 2 cooldowns, got %d", n)
+	}
+	if n := b.Cooldowns(start.Add(12 * time.Minute)); n != 1 {
+		t.Errorf("expected 1 cooldown after the first window, got %d", n)
+	}
+
+	b.Prune(start.Add(12 * time.Minute))
+	if len(b.hits) != 1 || len(b.alerted) != 1 {
+		t.Errorf("expected only the recent IP to be kept, got %d hits and %d alerts", len(b.hits), len(b.alerted))
+	}
+
+	b.Reset()
+	if _, fire := b.Add("198.51.100.7", start.Add(13*time.Minute)); !fire {
+		t.Error("expected Reset to clear the cooldown")
+	}
+}
@@ -0,0 +1,88 @@
+package detect
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+// Cluster is a set of IPs in one network that all tried exactly the same
+// usernames, which points to one attacker spreading over many addresses.
+type Cluster struct {
+	ASN       uint
+	ASOrg     string
+	Usernames []string
+	IPs       []string
+	Attempts  int
+	First     time.Time
+	Last      time.Time
+}
+
+// Key identifies the network and username list, which stay the same while
+// a campaign goes on even as it adds IPs.
+func (c Cluster) Key() string {
+	return strconv.FormatUint(uint64(c.ASN), 10) + "|" + strings.Join(c.Usernames, "\n")
+}
+
+// FindCampaigns clusters failed attempts by network and by the sorted list
+// of usernames each IP tried, and returns the clusters of at least minIPs
+// IPs, largest first.
+func FindCampaigns(attempts []storage.IPUserAttempts, minIPs int) []Cluster {
+	// One cluster per IP first, then merged by key.
+	var sources []*Cluster
+	byIP := make(map[string]*Cluster)
+	for _, a := range attempts {
+		ipKey := strconv.FormatUint(uint64(a.ASN), 10) + "|" + a.IP
+		s, ok := byIP[ipKey]
+		if !ok {
+			s = &Cluster{ASN: a.ASN, IPs: []string{a.IP}, First: a.First, Last: a.Last}
+			byIP[ipKey] = s
+			sources = append(sources, s)
+		}
+		s.ASOrg = max(s.ASOrg, a.ASOrg)
+		s.Usernames = append(s.Usernames, a.Username)
+		s.merge(a.Count, a.First, a.Last)
+	}
+
+	var clusters []*Cluster
+	byKey := make(map[string]*Cluster)
+	for _, s := range sources {
+		sort.Strings(s.Usernames)
+		c, ok := byKey[s.Key()]
+		if !ok {
+			c = &Cluster{ASN: s.ASN, ASOrg: s.ASOrg, Usernames: s.Usernames, First: s.First, Last: s.Last}
+			byKey[s.Key()] = c
+			clusters = append(clusters, c)
+		}
+		c.IPs = append(c.IPs, s.IPs...)
+		c.merge(s.Attempts, s.First, s.Last)
+	}
+
+	var result []Cluster
+	for _, c := range clusters {
+		if len(c.IPs) >= minIPs {
+			sort.Strings(c.IPs)
+			result = append(result, *c)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if len(result[i].IPs) != len(result[j].IPs) {
+			return len(result[i].IPs) > len(result[j].IPs)
+		}
+		return result[i].Attempts > result[j].Attempts
+	})
+	return result
+}
+
+func (c *Cluster) merge(attempts int, first, last time.Time) {
+	c.Attempts += attempts
+	if first.Before(c.First) {
+		c.First = first
+	}
+	if last.After(c.Last) {
+		c.Last = last
+	}
+}
@@ -0,0 +1,65 @@
+package detect
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+func TestFindCampaigns(t *testing.T) {
+	start := time.Date(2026, 1, 20, 14, 0, 0, 0, time.UTC)
+	attempt := func(asn uint, ip, user string, minutes int) storage.IPUserAttempts {
+		ts := start.Add(time.Duration(minutes) * time.Minute)
+		return storage.IPUserAttempts{ASN: asn, ASOrg: "AS" + ip, IP: ip, Username: user, Count: 2, First: ts, Last: ts.Add(time.Minute)}
+	}
+
+	attempts := []storage.IPUserAttempts{
+		// Three IPs in one network trying root and admin.
+		attempt(64500, "203.0.113.1", "admin", 0),
+		attempt(64500, "203.0.113.1", "root", 0),
+		attempt(64500, "203.0.113.2", "root", 5),
+		attempt(64500, "203.0.113.2", "admin", 5),
+		attempt(64500, "203.0.113.3", "admin", 10),
+		attempt(64500, "203.0.113.3", "root", 10),
+		// Same network, different usernames.
+		attempt(64500, "203.0.113.4", "root", 0),
+		// Same usernames, other network.
+		attempt(64501, "198.51.100.1", "admin", 0),
+		attempt(64501, "198.51.100.1", "root", 0),
+		// Two IPs in a third network.
+		attempt(64502, "192.0.2.1", "oracle", 0),
+		attempt(64502, "192.0.2.2", "oracle", 0),
+	}
+
+	tests := []struct {
+		minIPs int
+		want   [][]string
+	}{
+		{3, [][]string{{"203.0.113.1", "203.0.113.2", "203.0.113.3"}}},
+		{2, [][]string{{"203.0.113.1", "203.0.113.2", "203.0.113.3"}, {"192.0.2.1", "192.0.2.2"}}},
+		{4, nil},
+	}
+	for _, tt := range tests {
+		clusters := FindCampaigns(attempts, tt.minIPs)
+		var got [][]string
+		for _, c := range clusters {
+			got = append(got, c.IPs)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("minIPs %d: expected %v, got %v", tt.minIPs, tt.want, got)
+		}
+	}
+
+	c := FindCampaigns(attempts, 3)[0]
+	if c.ASN != 64500 || !reflect.DeepEqual(c.Usernames, []string{"admin", "root"}) || c.Attempts != 12 {
+		t.Errorf("unexpected cluster: %+v", c)
+	}
+	if !c.First.Equal(start) || !c.Last.Equal(start.Add(11*time.Minute)) {
+		t.Errorf("expected the cluster to span %v to %v, got %v to %v", start, start.Add(11*time.Minute), c.First, c.Last)
+	}
+	if c.Key() != "64500|admin\nroot" {
+		t.Errorf("unexpected key %q", c.Key())
+	}
+}
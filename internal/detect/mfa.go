@@ -0,0 +1,51 @@
+package detect
+
+import (
+	"sync"
+	"time"
+)
+
+type FactorTracker struct {
+	mu      sync.Mutex
+	window  time.Duration
+	factors map[string]time.Time
+}
+
+func NewFactorTracker(window time.Duration) *FactorTracker {
+	return &FactorTracker{
+		window:  window,
+		factors: make(map[string]time.Time),
+	}
+}
+
+func (f *FactorTracker) Record(key string, ts time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.factors[key] = ts
+}
+
+// Consume reports whether a factor was recorded for key within the window
+// before ts, and forgets it so it cannot vouch for a second login.
+func (f *FactorTracker) Consume(key string, ts time.Time) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	recorded, ok := f.factors[key]
+	if !ok {
+		return false
+	}
+	delete(f.factors, key)
+	return ts.Sub(recorded) <= f.window && !recorded.After(ts.Add(time.Second))
+}
+
+func (f *FactorTracker) Prune(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cutoff := now.Add(-f.window)
+	for key, ts := range f.factors {
+		if ts.Before(cutoff) {
+			delete(f.factors, key)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package detect
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFactorTrackerConsume(t *testing.T) {
+	start := time.Date(2026, 1, 20, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		recorded time.Duration
+		login    time.Duration
+		want     bool
+	}{
+		{"factor just before the login", 0, 5 * time.Second, true},
+		{"factor in the same second", time.Second, 0, true},
+		{"factor too long before", 0, 3 * time.Minute, false},
+		{"factor after the login", 10 * time.Second, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFactorTracker(2 * time.Minute)
+			f.Record("alice", start.Add(tt.recorded))
+			if got := f.Consume("alice", start.Add(tt.login)); got != tt.want {
+				t.Errorf("expected %t, got %t", tt.want, got)
+			}
+			if f.Consume("alice", start.Add(tt.login)) {
+				t.Error("expected a factor to vouch for one login only")
+			}
+		})
+	}
+}
+
+func TestFactorTrackerPrune(t *testing.T) {
+	start := time.Date(2026, 1, 20, 14, 0, 0, 0, time.UTC)
+	f := NewFactorTracker(2 * time.Minute)
+	f.Record("alice", start)
+	f.Record("bob", start.Add(2*time.Minute))
+
+	f.Prune(start.Add(3 * time.Minute))
+	if f.Consume("alice", start.Add(3*time.Minute)) {
+		t.Error("expected the old factor to be pruned")
+	}
+	if !f.Consume("bob", start.Add(3*time.Minute)) {
+		t.Error("expected the recent factor to be kept")
+	}
+}
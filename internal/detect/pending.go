@@ -0,0 +1,78 @@
+package detect
+
+import (
+	"sync"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/parser"
+)
+
+// PendingEvents holds auxiliary events (e.g. pam_sss results) until the
+// matching sshd line arrives, or until they expire and must stand on their own.
+type PendingEvents struct {
+	mu     sync.Mutex
+	window time.Duration
+	events map[string][]*parser.SSHEvent
+}
+
+func NewPendingEvents(window time.Duration) *PendingEvents {
+	return &PendingEvents{
+		window: window,
+		events: make(map[string][]*parser.SSHEvent),
+	}
+}
+
+func (p *PendingEvents) Add(key string, event *parser.SSHEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events[key] = append(p.events[key], event)
+}
+
+func (p *PendingEvents) Take(key string) *parser.SSHEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	queue := p.events[key]
+	if len(queue) == 0 {
+		return nil
+	}
+	event := queue[0]
+	if len(queue) == 1 {
+		delete(p.events, key)
+	} else {
+		p.events[key] = queue[1:]
+	}
+	return event
+}
+
+func (p *PendingEvents) Expired(now time.Time) []*parser.SSHEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cutoff := now.Add(-p.window)
+	var expired []*parser.SSHEvent
+	for key, queue := range p.events {
+		i := 0
+		for i < len(queue) && queue[i].Timestamp.Before(cutoff) {
+			expired = append(expired, queue[i])
+			i++
+		}
+		if i == len(queue) {
+			delete(p.events, key)
+		} else {
+			p.events[key] = queue[i:]
+		}
+	}
+	return expired
+}
+
+func (p *PendingEvents) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := 0
+	for _, queue := range p.events {
+		n += len(queue)
+	}
+	return n
+}
@@ -0,0 +1,39 @@
+package detect
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/parser"
+)
+
+func TestPendingEvents(t *testing.T) {
+	start := time.Date(2026, 1, 20, 14, 0, 0, 0, time.UTC)
+	event := func(user string, seconds int) *parser.SSHEvent {
+		return &parser.SSHEvent{Timestamp: start.Add(time.Duration(seconds) * time.Second), Username: user}
+	}
+
+	p := NewPendingEvents(10 * time.Second)
+	first, second := event("alice", 0), event("alice", 8)
+	p.Add("alice|198.51.100.7", first)
+	p.Add("alice|198.51.100.7", second)
+	p.Add("bob|203.0.113.5", event("bob", 1))
+	if p.Len() != 3 {
+		t.Fatalf("expected 3 pending events, got %d", p.Len())
+	}
+
+	if got := p.Take("alice|198.51.100.7"); got != first {
+		t.Errorf("expected the oldest event first, got %+v", got)
+	}
+	if got := p.Take("carol|192.0.2.1"); got != nil {
+		t.Errorf("expected nothing for an unknown key, got %+v", got)
+	}
+
+	expired := p.Expired(start.Add(15 * time.Second))
+	if len(expired) != 1 || expired[0].Username != "bob" {
+		t.Errorf("expected bob's event to expire, got %+v", expired)
+	}
+	if got := p.Take("alice|198.51.100.7"); got != second || p.Len() != 0 {
+		t.Errorf("expected alice's second event to be left, got %+v with %d pending", got, p.Len())
+	}
+}
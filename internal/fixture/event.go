@@ -0,0 +1,124 @@
+// Package fixture builds fake events and locations for tests, to be stored
+// in a storage.Memory or fed to the detectors and report generator.
+package fixture
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+	"github.com/oxisoft/oxiwatch/internal/parser"
+)
+
+// Each event gets its own source port, like real connections, so that events
+// built with otherwise identical fields are not deduplicated by the store.
+var nextPort atomic.Int32
+
+type EventBuilder struct {
+	event parser.SSHEvent
+}
+
+// Success starts an accepted SSH password login happening now.
+func Success(user, ip string) *EventBuilder {
+	return newEvent(parser.EventSuccess, user, ip)
+}
+
+// Failure starts a failed SSH password attempt happening now.
+func Failure(user, ip string) *EventBuilder {
+	return newEvent(parser.EventFailure, user, ip)
+}
+
+func newEvent(eventType parser.EventType, user, ip string) *EventBuilder {
+	return &EventBuilder{event: parser.SSHEvent{
+		Timestamp: time.Now().Truncate(time.Second),
+		EventType: eventType,
+		Service:   parser.ServiceSSH,
+		Username:  user,
+		IP:        ip,
+		Port:      40000 + int(nextPort.Add(1)),
+		Method:    "password",
+	}}
+}
+
+func (b *EventBuilder) At(t time.Time) *EventBuilder {
+	b.event.Timestamp = t
+	return b
+}
+
+// Ago sets the timestamp relative to now.
+func (b *EventBuilder) Ago(d time.Duration) *EventBuilder {
+	b.event.Timestamp = time.Now().Add(-d).Truncate(time.Second)
+	return b
+}
+
+func (b *EventBuilder) Service(service string) *EventBuilder {
+	b.event.Service = service
+	return b
+}
+
+func (b *EventBuilder) Port(port int) *EventBuilder {
+	b.event.Port = port
+	return b
+}
+
+func (b *EventBuilder) Method(method string) *EventBuilder {
+	b.event.Method = method
+	return b
+}
+
+// PublicKey switches to a publickey login with the given key.
+func (b *EventBuilder) PublicKey(keyType, fingerprint string) *EventBuilder {
+	b.event.Method = "publickey"
+	b.event.KeyType = keyType
+	b.event.Fingerprint = fingerprint
+	return b
+}
+
+func (b *EventBuilder) InvalidUser() *EventBuilder {
+	b.event.InvalidUser = true
+	return b
+}
+
+func (b *EventBuilder) MFA() *EventBuilder {
+	b.event.MFA = true
+	return b
+}
+
+func (b *EventBuilder) AuthSource(source string) *EventBuilder {
+	b.event.AuthSource = source
+	return b
+}
+
+// Build returns a new event each time, so a builder can be reused as a template.
+func (b *EventBuilder) Build() *parser.SSHEvent {
+	event := b.event
+	return &event
+}
+
+// Location returns a GeoIP result for a country code, with the continent
+// filled in for the codes used in tests.
+func Location(countryCode, country, city string) geoip.Location {
+	return geoip.Location{
+		Country:       country,
+		CountryCode:   countryCode,
+		Continent:     continents[countryCode][1],
+		ContinentCode: continents[countryCode][0],
+		City:          city,
+	}
+}
+
+var continents = map[string][2]string{
+	"DE": {"EU", "Europe"},
+	"FR": {"EU", "Europe"},
+	"NL": {"EU", "Europe"},
+	"GB": {"EU", "Europe"},
+	"RU": {"EU", "Europe"},
+	"US": {"NA", "North America"},
+	"CA": {"NA", "North America"},
+	"BR": {"SA", "South America"},
+	"CN": {"AS", "Asia"},
+	"IN": {"AS", "Asia"},
+	"SG": {"AS", "Asia"},
+	"AU": {"OC", "Oceania"},
+	"ZA": {"AF", "Africa"},
+}
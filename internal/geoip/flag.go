@@ -0,0 +1,21 @@
+package geoip
+
+import "strings"
+
+// Flag turns an ISO 3166-1 alpha-2 country code into its flag emoji, made of
+// two regional indicator symbols. Anything else yields an empty string.
+func Flag(code string) string {
+	if len(code) != 2 {
+		return ""
+	}
+	code = strings.ToUpper(code)
+
+	var b strings.Builder
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+		b.WriteRune(0x1F1E6 + c - 'A')
+	}
+	return b.String()
+}
@@ -0,0 +1,19 @@
+package geoip
+
+import "testing"
+
+func TestFlag(t *testing.T) {
+	tests := map[string]string{
+		"DE":  "🇩🇪",
+		"us":  "🇺🇸",
+		"":    "",
+		"EU1": "",
+		"1A":  "",
+	}
+
+	for code, expected := range tests {
+		if got := Flag(code); got != expected {
+			t.Errorf("Flag(%q) = %q, expected %q", code, got, expected)
+		}
+	}
+}
@@ -7,23 +7,39 @@ import (
 )
 
 type Location struct {
-	Country string
-	City    string
+	Country       string
+	CountryCode   string
+	Continent     string
+	ContinentCode string
+	City          string
+	ASN           uint
+	ASOrg         string
 }
 
 type Resolver struct {
-	db *maxminddb.Reader
+	db  *maxminddb.Reader
+	asn *maxminddb.Reader
 }
 
 type geoRecord struct {
-	Country struct {
+	Continent struct {
+		Code  string            `maxminddb:"code"`
 		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"continent"`
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
 	} `maxminddb:"country"`
 	City struct {
 		Names map[string]string `maxminddb:"names"`
 	} `maxminddb:"city"`
 }
 
+type asnRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
 func NewResolver(dbPath string) (*Resolver, error) {
 	db, err := maxminddb.Open(dbPath)
 	if err != nil {
@@ -32,6 +48,19 @@ func NewResolver(dbPath string) (*Resolver, error) {
 	return &Resolver{db: db}, nil
 }
 
+// LoadASN opens an ASN database so lookups also fill in ASN and ASOrg.
+func (r *Resolver) LoadASN(dbPath string) error {
+	db, err := maxminddb.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	if r.asn != nil {
+		r.asn.Close()
+	}
+	r.asn = db
+	return nil
+}
+
 func (r *Resolver) Lookup(ipStr string) (*Location, error) {
 	ip := net.ParseIP(ipStr)
 	if ip == nil {
@@ -43,13 +72,29 @@ func (r *Resolver) Lookup(ipStr string) (*Location, error) {
 		return nil, err
 	}
 
-	return &Location{
-		Country: record.Country.Names["en"],
-		City:    record.City.Names["en"],
-	}, nil
+	loc := &Location{
+		Country:       record.Country.Names["en"],
+		CountryCode:   record.Country.ISOCode,
+		Continent:     record.Continent.Names["en"],
+		ContinentCode: record.Continent.Code,
+		City:          record.City.Names["en"],
+	}
+
+	if r.asn != nil {
+		var asn asnRecord
+		if err := r.asn.Lookup(ip, &asn); err == nil {
+			loc.ASN = asn.Number
+			loc.ASOrg = asn.Organization
+		}
+	}
+
+	return loc, nil
 }
 
 func (r *Resolver) Close() error {
+	if r.asn != nil {
+		r.asn.Close()
+	}
 	if r.db != nil {
 		return r.db.Close()
 	}
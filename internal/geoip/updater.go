@@ -12,18 +12,29 @@ import (
 )
 
 const (
-	dbipDownloadURL = "https://download.db-ip.com/free/dbip-city-lite-%d-%02d.mmdb.gz"
+	dbipDownloadURL    = "https://download.db-ip.com/free/dbip-city-lite-%d-%02d.mmdb.gz"
+	dbipASNDownloadURL = "https://download.db-ip.com/free/dbip-asn-lite-%d-%02d.mmdb.gz"
 )
 
 type Updater struct {
-	dbPath string
-	logger *slog.Logger
+	dbPath      string
+	urlTemplate string
+	logger      *slog.Logger
 }
 
 func NewUpdater(dbPath string, logger *slog.Logger) *Updater {
 	return &Updater{
-		dbPath: dbPath,
-		logger: logger,
+		dbPath:      dbPath,
+		urlTemplate: dbipDownloadURL,
+		logger:      logger,
+	}
+}
+
+func NewASNUpdater(dbPath string, logger *slog.Logger) *Updater {
+	return &Updater{
+		dbPath:      dbPath,
+		urlTemplate: dbipASNDownloadURL,
+		logger:      logger,
 	}
 }
 
@@ -52,7 +63,7 @@ func (u *Updater) GetLocalVersion() (year int, month int, err error) {
 func (u *Updater) GetLatestRemoteVersion() (year int, month int, err error) {
 	now := time.Now()
 
-	url := fmt.Sprintf(dbipDownloadURL, now.Year(), int(now.Month()))
+	url := fmt.Sprintf(u.urlTemplate, now.Year(), int(now.Month()))
 	resp, err := http.Head(url)
 	if err != nil {
 		return 0, 0, err
@@ -64,7 +75,7 @@ func (u *Updater) GetLatestRemoteVersion() (year int, month int, err error) {
 	}
 
 	prev := now.AddDate(0, -1, 0)
-	url = fmt.Sprintf(dbipDownloadURL, prev.Year(), int(prev.Month()))
+	url = fmt.Sprintf(u.urlTemplate, prev.Year(), int(prev.Month()))
 	resp, err = http.Head(url)
 	if err != nil {
 		return 0, 0, err
@@ -104,10 +115,10 @@ func (u *Updater) NeedsUpdate() (bool, error) {
 }
 
 func (u *Updater) Update() error {
-	u.logger.Info("downloading GeoIP database from DB-IP")
+	u.logger.Info("downloading GeoIP database from DB-IP", "path", u.dbPath)
 
 	now := time.Now()
-	url := fmt.Sprintf(dbipDownloadURL, now.Year(), int(now.Month()))
+	url := fmt.Sprintf(u.urlTemplate, now.Year(), int(now.Month()))
 
 	resp, err := http.Get(url)
 	if err != nil {
@@ -117,7 +128,7 @@ func (u *Updater) Update() error {
 	if resp.StatusCode == http.StatusNotFound {
 		resp.Body.Close()
 		prev := now.AddDate(0, -1, 0)
-		url = fmt.Sprintf(dbipDownloadURL, prev.Year(), int(prev.Month()))
+		url = fmt.Sprintf(u.urlTemplate, prev.Year(), int(prev.Month()))
 		resp, err = http.Get(url)
 		if err != nil {
 			return fmt.Errorf("failed to download: %w", err)
@@ -0,0 +1,16 @@
+package host
+
+import (
+	"path/filepath"
+	"syscall"
+)
+
+// FreeSpace reports the bytes available to unprivileged users on the
+// filesystem holding path. path itself does not need to exist yet.
+func FreeSpace(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(filepath.Dir(path), &st); err != nil {
+		return 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), nil
+}
@@ -0,0 +1,59 @@
+package host
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	bootIDPath = "/proc/sys/kernel/random/boot_id"
+	uptimePath = "/proc/uptime"
+)
+
+// BootID returns the kernel's random boot ID, which changes on every boot.
+// It is the same value journald records as _BOOT_ID.
+func BootID() (string, error) {
+	data, err := os.ReadFile(bootIDPath)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func Uptime() (time.Duration, error) {
+	data, err := os.ReadFile(uptimePath)
+	if err != nil {
+		return 0, err
+	}
+	return parseUptime(string(data))
+}
+
+func parseUptime(data string) (time.Duration, error) {
+	fields := strings.Fields(data)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty uptime")
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid uptime %q: %w", fields[0], err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func FormatUptime(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
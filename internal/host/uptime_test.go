@@ -0,0 +1,38 @@
+package host
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseUptime(t *testing.T) {
+	d, err := parseUptime("350735.47 234388.90\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := 350735*time.Second + 470*time.Millisecond
+	if d.Round(time.Millisecond) != expected {
+		t.Errorf("expected %v, got %v", expected, d)
+	}
+
+	if _, err := parseUptime(""); err == nil {
+		t.Error("expected error for empty input")
+	}
+}
+
+func TestFormatUptime(t *testing.T) {
+	tests := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{4*24*time.Hour + 3*time.Hour + 20*time.Minute, "4d 3h"},
+		{5*time.Hour + 7*time.Minute, "5h 7m"},
+		{42 * time.Minute, "42m"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatUptime(tt.d); got != tt.expected {
+			t.Errorf("FormatUptime(%v) = %s, expected %s", tt.d, got, tt.expected)
+		}
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"io"
 	"log/slog"
 	"os/exec"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/oxisoft/oxiwatch/internal/parser"
@@ -14,20 +17,49 @@ import (
 
 type Reader struct {
 	logger *slog.Logger
+	units  []string
 	events chan *parser.SSHEvent
 	cmd    *exec.Cmd
+	source io.Reader
+	after  string
+	// occurrences and u2f are only used by the reading goroutine.
+	occurrences *parser.OccurrenceTracker
+	u2f         *parser.U2FTracker
+
+	mu     sync.Mutex
+	cursor string
 }
 
 type journalEntry struct {
+	Cursor            string `json:"__CURSOR"`
 	RealtimeTimestamp string `json:"__REALTIME_TIMESTAMP"`
 	Message           string `json:"MESSAGE"`
 	SyslogIdentifier  string `json:"SYSLOG_IDENTIFIER"`
+	PID               string `json:"_PID"`
 }
 
-func New(logger *slog.Logger) *Reader {
+func New(logger *slog.Logger, units []string) *Reader {
 	return &Reader{
 		logger: logger,
+		units:  units,
 		events: make(chan *parser.SSHEvent, 100),
+
+		occurrences: parser.NewOccurrenceTracker(),
+		u2f:         parser.NewU2FTracker(),
+	}
+}
+
+// NewScripted returns a Reader that parses journalctl JSON lines from source
+// instead of following the journal, and closes its events channel at the end
+// of source. It lets tests drive the daemon with recorded journal output.
+func NewScripted(logger *slog.Logger, source io.Reader) *Reader {
+	return &Reader{
+		logger: logger,
+		events: make(chan *parser.SSHEvent, 100),
+		source: source,
+
+		occurrences: parser.NewOccurrenceTracker(),
+		u2f:         parser.NewU2FTracker(),
 	}
 }
 
@@ -35,8 +67,38 @@ func (r *Reader) Events() <-chan *parser.SSHEvent {
 	return r.events
 }
 
+// ResumeAfter makes Start continue after cursor, as returned by Cursor
+// before a restart, instead of from the current time. Call it before Start.
+func (r *Reader) ResumeAfter(cursor string) {
+	r.after = cursor
+}
+
+// Cursor returns the journal cursor of the last entry read, or "" if none
+// has been read yet.
+func (r *Reader) Cursor() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cursor
+}
+
 func (r *Reader) Start(ctx context.Context) error {
-	r.cmd = exec.CommandContext(ctx, "journalctl", "-u", "ssh", "-f", "-o", "json", "--since", "now")
+	if r.source != nil {
+		go r.read(ctx, r.source)
+		return nil
+	}
+
+	var args []string
+	for _, unit := range r.units {
+		args = append(args, "-u", unit)
+	}
+	args = append(args, "-f", "-o", "json")
+	if r.after != "" {
+		args = append(args, "--after-cursor", r.after)
+	} else {
+		args = append(args, "--since", "now")
+	}
+
+	r.cmd = exec.CommandContext(ctx, "journalctl", args...)
 	stdout, err := r.cmd.StdoutPipe()
 	if err != nil {
 		return err
@@ -46,27 +108,29 @@ func (r *Reader) Start(ctx context.Context) error {
 		return err
 	}
 
-	go func() {
-		defer close(r.events)
+	go r.read(ctx, stdout)
 
-		scanner := bufio.NewScanner(stdout)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if event := r.parseJournalLine(line); event != nil {
-				select {
-				case r.events <- event:
-				case <-ctx.Done():
-					return
-				}
-			}
-		}
+	return nil
+}
 
-		if err := scanner.Err(); err != nil {
-			r.logger.Error("journal reader error", "error", err)
+func (r *Reader) read(ctx context.Context, source io.Reader) {
+	defer close(r.events)
+
+	scanner := bufio.NewScanner(source)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if event := r.parseJournalLine(line); event != nil {
+			select {
+			case r.events <- event:
+			case <-ctx.Done():
+				return
+			}
 		}
-	}()
+	}
 
-	return nil
+	if err := scanner.Err(); err != nil {
+		r.logger.Error("journal reader error", "error", err)
+	}
 }
 
 func (r *Reader) parseJournalLine(line string) *parser.SSHEvent {
@@ -76,19 +140,41 @@ func (r *Reader) parseJournalLine(line string) *parser.SSHEvent {
 		return nil
 	}
 
+	if entry.Cursor != "" {
+		r.mu.Lock()
+		r.cursor = entry.Cursor
+		r.mu.Unlock()
+	}
+
 	r.logger.Debug("journal entry", "identifier", entry.SyslogIdentifier, "message", entry.Message)
 
-	if entry.SyslogIdentifier != "sshd" && entry.SyslogIdentifier != "sshd-session" {
-		r.logger.Debug("skipping non-sshd entry", "identifier", entry.SyslogIdentifier)
+	timestamp := r.parseTimestamp(entry.RealtimeTimestamp)
+
+	var event *parser.SSHEvent
+	switch {
+	case strings.HasPrefix(entry.SyslogIdentifier, "sshd"), entry.SyslogIdentifier == "pam_duo":
+		event = parser.ParseMessage(entry.Message, timestamp)
+		if event == nil {
+			event = parser.ParseMFAMessage(entry.SyslogIdentifier, entry.Message, timestamp)
+		}
+		if event == nil {
+			event = r.u2f.Parse(entry.PID, entry.Message, timestamp)
+		}
+		if event == nil {
+			event = parser.ParseSSSDMessage(entry.Message, timestamp)
+		}
+	case entry.SyslogIdentifier == "smbd":
+		event = parser.ParseSambaMessage(entry.Message, timestamp)
+	default:
+		r.logger.Debug("skipping unsupported entry", "identifier", entry.SyslogIdentifier)
 		return nil
 	}
-
-	timestamp := r.parseTimestamp(entry.RealtimeTimestamp)
-	event := parser.ParseMessage(entry.Message, timestamp)
 	if event == nil {
 		r.logger.Debug("message not parsed", "message", entry.Message)
 	} else {
-		r.logger.Debug("parsed event", "type", event.EventType, "user", event.Username, "ip", event.IP)
+		event.PID = entry.PID
+		r.occurrences.Mark(event)
+		r.logger.Debug("parsed event", "type", event.EventType, "service", event.Service, "user", event.Username, "ip", event.IP)
 	}
 	return event
 }
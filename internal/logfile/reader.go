@@ -0,0 +1,81 @@
+package logfile
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"os/exec"
+
+	"github.com/oxisoft/oxiwatch/internal/parser"
+)
+
+type Reader struct {
+	logger *slog.Logger
+	path   string
+	format string
+	events chan *parser.SSHEvent
+	cmd    *exec.Cmd
+}
+
+func New(logger *slog.Logger, path, format string) *Reader {
+	return &Reader{
+		logger: logger,
+		path:   path,
+		format: format,
+		events: make(chan *parser.SSHEvent, 100),
+	}
+}
+
+func (r *Reader) Path() string {
+	return r.path
+}
+
+func (r *Reader) Events() <-chan *parser.SSHEvent {
+	return r.events
+}
+
+func (r *Reader) Start(ctx context.Context) error {
+	r.cmd = exec.CommandContext(ctx, "tail", "-n", "0", "-F", r.path)
+	stdout, err := r.cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := r.cmd.Start(); err != nil {
+		return err
+	}
+
+	go func() {
+		defer close(r.events)
+
+		occurrences := parser.NewOccurrenceTracker()
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			event := parser.ParseLogLine(r.format, scanner.Text())
+			if event == nil {
+				continue
+			}
+			occurrences.Mark(event)
+			r.logger.Debug("parsed log event", "path", r.path, "type", event.EventType, "service", event.Service, "ip", event.IP)
+
+			select {
+			case r.events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			r.logger.Error("log file reader error", "path", r.path, "error", err)
+		}
+	}()
+
+	return nil
+}
+
+func (r *Reader) Stop() error {
+	if r.cmd != nil && r.cmd.Process != nil {
+		return r.cmd.Process.Kill()
+	}
+	return nil
+}
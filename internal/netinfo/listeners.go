@@ -0,0 +1,115 @@
+package netinfo
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const tcpListen = "0A"
+
+type Listener struct {
+	IP   net.IP
+	Port int
+}
+
+func (l Listener) String() string {
+	return net.JoinHostPort(l.IP.String(), strconv.Itoa(l.Port))
+}
+
+// Public reports whether the socket accepts connections from the internet:
+// either bound to all interfaces or to a globally routable address.
+func (l Listener) Public() bool {
+	if l.IP.IsUnspecified() {
+		return true
+	}
+	return l.IP.IsGlobalUnicast() && !l.IP.IsPrivate()
+}
+
+func TCPListeners(ports []int) ([]Listener, error) {
+	wanted := make(map[int]bool)
+	for _, p := range ports {
+		wanted[p] = true
+	}
+
+	var listeners []Listener
+	for _, path := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		found, err := parseProcNetTCP(f, wanted)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		listeners = append(listeners, found...)
+	}
+
+	sort.Slice(listeners, func(i, j int) bool {
+		return listeners[i].String() < listeners[j].String()
+	})
+	return listeners, nil
+}
+
+func parseProcNetTCP(r io.Reader, ports map[int]bool) ([]Listener, error) {
+	var listeners []Listener
+
+	scanner := bufio.NewScanner(r)
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[3] != tcpListen {
+			continue
+		}
+
+		hostHex, portHex, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+		port, err := strconv.ParseInt(portHex, 16, 32)
+		if err != nil {
+			return nil, err
+		}
+		if !ports[int(port)] {
+			continue
+		}
+
+		ip, err := decodeProcIP(hostHex)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, Listener{IP: ip, Port: int(port)})
+	}
+	return listeners, scanner.Err()
+}
+
+// decodeProcIP converts the kernel's representation, which stores each
+// 32-bit word of the address in host (little-endian) byte order.
+func decodeProcIP(s string) (net.IP, error) {
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != net.IPv4len && len(raw) != net.IPv6len {
+		return nil, fmt.Errorf("unexpected address length %d", len(raw))
+	}
+
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4, nil
+	}
+	return ip, nil
+}
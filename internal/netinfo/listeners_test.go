@@ -0,0 +1,56 @@
+package netinfo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProcNetTCP(t *testing.T) {
+	data := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 2 1 0000000000000000 100 0 0 10 0
+   2: 0A00000A:0016 0500000A:C350 01 00000000:00000000 02:00000000 00000000     0        0 3 1 0000000000000000 20 4 30 10 -1
+   3: 0101A8C0:08AE 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 4 1 0000000000000000 100 0 0 10 0
+`
+	listeners, err := parseProcNetTCP(strings.NewReader(data), map[int]bool{22: true, 2222: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(listeners) != 2 {
+		t.Fatalf("expected 2 listeners, got %d: %v", len(listeners), listeners)
+	}
+	if got := listeners[0].String(); got != "0.0.0.0:22" {
+		t.Errorf("expected 0.0.0.0:22, got %s", got)
+	}
+	if !listeners[0].Public() {
+		t.Error("expected wildcard listener to be public")
+	}
+	if got := listeners[1].String(); got != "192.168.1.1:2222" {
+		t.Errorf("expected 192.168.1.1:2222, got %s", got)
+	}
+	if listeners[1].Public() {
+		t.Error("expected private listener not to be public")
+	}
+}
+
+func TestParseProcNetTCP6(t *testing.T) {
+	data := `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0016 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1 1 0000000000000000 100 0 0 10 0
+   1: B80D0120000000000000000001000000:0016 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 2 1 0000000000000000 100 0 0 10 0
+`
+	listeners, err := parseProcNetTCP(strings.NewReader(data), map[int]bool{22: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(listeners) != 2 {
+		t.Fatalf("expected 2 listeners, got %d", len(listeners))
+	}
+	if got := listeners[0].String(); got != "[::]:22" {
+		t.Errorf("expected [::]:22, got %s", got)
+	}
+	if got := listeners[1].String(); got != "[2001:db8::1]:22" {
+		t.Errorf("expected [2001:db8::1]:22, got %s", got)
+	}
+}
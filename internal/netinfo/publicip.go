@@ -0,0 +1,32 @@
+package netinfo
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func PublicIPs() (ipv4, ipv6 string) {
+	return getPublicIP("https://api.ipify.org"), getPublicIP("https://api6.ipify.org")
+}
+
+func getPublicIP(url string) string {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,26 @@
+package notifier
+
+import "time"
+
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Alert is a detection in structured form, for sinks that feed log
+// pipelines rather than people. Fields holds kind-specific details such as
+// "ip" or "user"; empty values are left out by the sinks.
+type Alert struct {
+	Time     time.Time
+	Server   string
+	Kind     string
+	Severity string
+	Message  string
+	Fields   map[string]string
+}
+
+// Sink receives every alert the daemon raises, next to the chat message.
+type Sink interface {
+	Emit(alert Alert) error
+}
@@ -0,0 +1,129 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// JournaldSocket is where systemd-journald accepts native protocol messages.
+const JournaldSocket = "/run/systemd/journal/socket"
+
+// Journald writes alerts to the systemd journal with structured fields
+// (OXIWATCH_KIND, OXIWATCH_IP, ...), so hosts that ship their journal to a
+// central collector capture them without a chat channel.
+type Journald struct {
+	mu   sync.Mutex
+	path string
+	conn *net.UnixConn
+}
+
+func NewJournald(socketPath string) *Journald {
+	return &Journald{path: socketPath}
+}
+
+func (j *Journald) Emit(alert Alert) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.conn == nil {
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: j.path, Net: "unixgram"})
+		if err != nil {
+			return err
+		}
+		j.conn = conn
+	}
+
+	if _, err := j.conn.Write(journalEntry(alert)); err != nil {
+		// journald may have restarted; dial again next time.
+		j.conn.Close()
+		j.conn = nil
+		return err
+	}
+	return nil
+}
+
+var journalPriorities = map[string]string{
+	SeverityCritical: "2",
+	SeverityWarning:  "4",
+	SeverityInfo:     "6",
+}
+
+// journalEntry encodes alert in the journal's native protocol: one
+// KEY=value line per field, or KEY, a little-endian length and the raw
+// value for values containing newlines.
+func journalEntry(alert Alert) []byte {
+	var buf bytes.Buffer
+	for _, field := range journalFields(alert) {
+		if !strings.Contains(field.value, "\n") {
+			buf.WriteString(field.key + "=" + field.value + "\n")
+			continue
+		}
+		buf.WriteString(field.key + "\n")
+		binary.Write(&buf, binary.LittleEndian, uint64(len(field.value)))
+		buf.WriteString(field.value + "\n")
+	}
+	return buf.Bytes()
+}
+
+// JournalFields returns the fields Emit writes for alert as KEY=value
+// strings, for previewing an alert without a journal.
+func JournalFields(alert Alert) []string {
+	var fields []string
+	for _, field := range journalFields(alert) {
+		fields = append(fields, field.key+"="+field.value)
+	}
+	return fields
+}
+
+type journalField struct {
+	key, value string
+}
+
+func journalFields(alert Alert) []journalField {
+	var fields []journalField
+	add := func(key, value string) {
+		if value != "" {
+			fields = append(fields, journalField{key, value})
+		}
+	}
+
+	priority := journalPriorities[alert.Severity]
+	if priority == "" {
+		priority = journalPriorities[SeverityInfo]
+	}
+	add("MESSAGE", alert.Message)
+	add("PRIORITY", priority)
+	add("SYSLOG_IDENTIFIER", "oxiwatch")
+	add("OXIWATCH_KIND", alert.Kind)
+	add("OXIWATCH_SEVERITY", alert.Severity)
+	add("OXIWATCH_SERVER", alert.Server)
+
+	keys := make([]string, 0, len(alert.Fields))
+	for k := range alert.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		add("OXIWATCH_"+journalFieldName(k), alert.Fields[k])
+	}
+	return fields
+}
+
+// journalFieldName maps a field name to the journal's allowed characters:
+// upper case letters, digits and underscores.
+func journalFieldName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
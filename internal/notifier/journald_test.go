@@ -0,0 +1,104 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestJournaldEmit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	j := NewJournald(path)
+	err = j.Emit(Alert{
+		Time:     time.Now(),
+		Server:   "web-1",
+		Kind:     "exposure",
+		Severity: SeverityWarning,
+		Message:  "SSH exposure changed",
+		Fields:   map[string]string{"current": "0.0.0.0:22\n[::]:22", "auth-source": "", "ip": "203.0.113.5"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := parseJournalEntry(t, buf[:n])
+
+	want := map[string]string{
+		"MESSAGE":           "SSH exposure changed",
+		"PRIORITY":          "4",
+		"SYSLOG_IDENTIFIER": "oxiwatch",
+		"OXIWATCH_KIND":     "exposure",
+		"OXIWATCH_SEVERITY": "warning",
+		"OXIWATCH_SERVER":   "web-1",
+		"OXIWATCH_CURRENT":  "0.0.0.0:22\n[::]:22",
+		"OXIWATCH_IP":       "203.0.113.5",
+	}
+	if len(fields) != len(want) {
+		t.Errorf("expected %d fields, got %v", len(want), fields)
+	}
+	for k, v := range want {
+		if fields[k] != v {
+			t.Errorf("%s = %q, want %q", k, fields[k], v)
+		}
+	}
+}
+
+func TestJournalFields(t *testing.T) {
+	fields := JournalFields(Alert{
+		Server:   "web-1",
+		Kind:     "login",
+		Severity: SeverityCritical,
+		Message:  "ssh login as alice from 198.51.100.7",
+		Fields:   map[string]string{"user": "alice", "warning": ""},
+	})
+
+	want := []string{
+		"MESSAGE=ssh login as alice from 198.51.100.7",
+		"PRIORITY=2",
+		"SYSLOG_IDENTIFIER=oxiwatch",
+		"OXIWATCH_KIND=login",
+		"OXIWATCH_SEVERITY=critical",
+		"OXIWATCH_SERVER=web-1",
+		"OXIWATCH_USER=alice",
+	}
+	if !slices.Equal(fields, want) {
+		t.Errorf("got %q, want %q", fields, want)
+	}
+}
+
+// parseJournalEntry decodes the native protocol written by journalEntry.
+func parseJournalEntry(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	fields := make(map[string]string)
+	for len(data) > 0 {
+		nl := bytes.IndexByte(data, '\n')
+		if nl < 0 {
+			t.Fatalf("unterminated field in %q", data)
+		}
+		line := data[:nl]
+		data = data[nl+1:]
+		if eq := bytes.IndexByte(line, '='); eq >= 0 {
+			fields[string(line[:eq])] = string(line[eq+1:])
+			continue
+		}
+		size := binary.LittleEndian.Uint64(data[:8])
+		fields[string(line)] = string(data[8 : 8+size])
+		data = data[8+size+1:]
+	}
+	return fields
+}
@@ -2,24 +2,39 @@ package notifier
 
 import (
 	"fmt"
-	"io"
-	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/oxisoft/oxiwatch/internal/config"
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+	"github.com/oxisoft/oxiwatch/internal/netinfo"
 	"github.com/oxisoft/oxiwatch/internal/parser"
+	"github.com/oxisoft/oxiwatch/internal/storage"
 )
 
 type Telegram struct {
-	bot        *tgbotapi.BotAPI
-	chatID     int64
+	transport  Transport
 	serverName string
 	serverInfo string
+	metadata   config.ServerMetadata
+	fields     map[string]bool
+	theme      string
+
+	mu       sync.Mutex
+	failures Failures
+}
+
+// Failures counts messages a channel failed to deliver since startup.
+type Failures struct {
+	Count     int       `json:"count"`
+	LastError string    `json:"last_error,omitempty"`
+	LastAt    time.Time `json:"last_at,omitempty"`
 }
 
-func NewTelegram(botToken, chatID, serverName string) (*Telegram, error) {
+func NewTelegram(botToken, chatID, serverName string, metadata config.ServerMetadata) (*Telegram, error) {
 	bot, err := tgbotapi.NewBotAPI(botToken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create telegram bot: %w", err)
@@ -31,18 +46,50 @@ func NewTelegram(botToken, chatID, serverName string) (*Telegram, error) {
 	}
 
 	t := &Telegram{
-		bot:        bot,
-		chatID:     id,
+		transport:  &botTransport{bot: bot, chatID: id},
 		serverName: serverName,
+		metadata:   metadata,
 	}
 	t.serverInfo = t.buildServerInfo()
 
 	return t, nil
 }
 
+// NewWithTransport creates a notifier that delivers through transport, e.g.
+// a Recorder. The server line shows only serverName, without looking up the
+// public IPs.
+func NewWithTransport(transport Transport, serverName string, metadata config.ServerMetadata) *Telegram {
+	return &Telegram{
+		transport:  transport,
+		serverName: serverName,
+		serverInfo: serverName,
+		metadata:   metadata,
+	}
+}
+
+// SetLoginAlertFields selects which lines appear in login alerts. Names are
+// those in config.LoginAlertFieldNames; unset means the default selection.
+func (t *Telegram) SetLoginAlertFields(fields []string) {
+	t.fields = make(map[string]bool, len(fields))
+	for _, f := range fields {
+		t.fields[f] = true
+	}
+}
+
+// SetTheme selects how messages are formatted, see applyTheme.
+func (t *Telegram) SetTheme(theme string) {
+	t.theme = theme
+}
+
+func (t *Telegram) showField(name string) bool {
+	if t.fields == nil {
+		return name != "port" && name != "asn" && name != "fingerprint"
+	}
+	return t.fields[name]
+}
+
 func (t *Telegram) buildServerInfo() string {
-	ipv4 := getPublicIP("https://api.ipify.org")
-	ipv6 := getPublicIP("https://api6.ipify.org")
+	ipv4, ipv6 := netinfo.PublicIPs()
 
 	info := t.serverName
 	if ipv4 != "" || ipv6 != "" {
@@ -58,63 +105,338 @@ func (t *Telegram) buildServerInfo() string {
 	return info
 }
 
-func getPublicIP(url string) string {
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(url)
-	if err != nil {
-		return ""
+func (t *Telegram) serverHeader() string {
+	header := "🖥️ Server: " + escapeHTML(t.serverInfo)
+	if labels := t.metadata.Labels(); len(labels) > 0 {
+		header += "\n🏷️ " + escapeHTML(strings.Join(labels, " | "))
 	}
-	defer resp.Body.Close()
+	if url := t.metadata.RunbookURL; url != "" {
+		href := escapeHTML(strings.ReplaceAll(url, `"`, "%22"))
+		header += fmt.Sprintf("\n📘 Runbook: <a href=\"%s\">%s</a>", href, escapeHTML(url))
+	}
+	return header
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return ""
+// SendLoginAlert sends the alert for a successful login. Escalated alerts
+// are marked critical and always show the key, for logins with a key the
+// account has never used before.
+func (t *Telegram) SendLoginAlert(event *parser.SSHEvent, loc geoip.Location, history, warning string, escalate bool) error {
+	icon := "🔐"
+	if escalate {
+		icon = "🚨"
 	}
+	msg := fmt.Sprintf("%s <b>%s Login Alert</b>\n%s\n",
+		icon,
+		parser.ServiceLabel(event.Service),
+		t.serverHeader(),
+	)
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return ""
+	if t.showField("user") {
+		msg += "\n👤 User: " + escapeHTML(event.Username)
+	}
+	if t.showField("time") {
+		msg += "\n📅 Time: " + event.Timestamp.Format("2006-01-02 15:04:05")
+	}
+	if t.showField("method") {
+		msg += "\n🔓 Method: " + event.Method
+	}
+	if t.showField("ip") {
+		msg += "\n🌐 IP: " + escapeHTML(event.IP)
+	}
+	if t.showField("port") && event.Port != 0 {
+		msg += fmt.Sprintf("\n🔌 Port: %d", event.Port)
+	}
+	if t.showField("location") {
+		msg += "\n📍 Location: " + escapeHTML(formatLocation(event.IP, loc))
+	}
+	if t.showField("asn") && loc.ASN != 0 {
+		msg += fmt.Sprintf("\n🛰️ Network: AS%d %s", loc.ASN, escapeHTML(loc.ASOrg))
+	}
+	if (t.showField("fingerprint") || escalate) && event.Fingerprint != "" {
+		msg += "\n🗝️ Key: " + escapeHTML(strings.TrimSpace(event.KeyType+" "+event.Fingerprint))
+	}
+
+	if event.MFA {
+		msg += "\n🔑 MFA: completed"
+	}
+	if event.AuthSource == parser.AuthSourceSSSD {
+		msg += "\n🏢 Account: domain (SSSD)"
+	}
+	if history != "" && t.showField("history") {
+		msg += "\n📜 History: " + escapeHTML(history)
+	}
+
+	if warning != "" {
+		msg += fmt.Sprintf("\n\n⚠️ %s", escapeHTML(warning))
 	}
 
-	return strings.TrimSpace(string(body))
+	return t.send(msg)
 }
 
-func (t *Telegram) SendLoginAlert(event *parser.SSHEvent, country, city, warning string) error {
-	location := formatLocation(event.IP, country, city)
+func (t *Telegram) SendBurstAlert(kind, ip string, loc geoip.Location, history string, count int, window time.Duration) (int, error) {
+	attempts := fmt.Sprintf("%d in %d min", count, int(window.Minutes()))
+	return t.sendWithID(t.burstMessage(kind, ip, loc, history, attempts))
+}
 
-	msg := fmt.Sprintf(`🔐 <b>SSH Login Alert</b>
-🖥️ Server: %s
+// EditBurstAlert updates a previously sent burst alert while the attack from
+// the same IP is still going on.
+func (t *Telegram) EditBurstAlert(messageID int, kind, ip string, loc geoip.Location, history string, count int, first, last time.Time) error {
+	attempts := fmt.Sprintf("%d since %s (ongoing, last %s)", count, first.Format("15:04:05"), last.Format("15:04:05"))
+	return t.edit(messageID, t.burstMessage(kind, ip, loc, history, attempts))
+}
+
+// EndBurstAlert edits a burst alert a last time with the final count once
+// the attack has stopped.
+func (t *Telegram) EndBurstAlert(messageID int, kind, ip string, loc geoip.Location, history string, count int, first, last time.Time) error {
+	attempts := fmt.Sprintf("%d from %s to %s (ended)", count, first.Format("15:04:05"), last.Format("15:04:05"))
+	return t.edit(messageID, t.burstMessage(kind, ip, loc, history, attempts))
+}
+
+func (t *Telegram) burstMessage(kind, ip string, loc geoip.Location, history, attempts string) string {
+	location := formatLocation(ip, loc)
+
+	msg := fmt.Sprintf(`🚨 <b>%s</b>
+%s
 
-👤 User: %s
-📅 Time: %s
-🔓 Method: %s
 🌐 IP: %s
-📍 Location: %s`,
-		escapeHTML(t.serverInfo),
-		escapeHTML(event.Username),
-		event.Timestamp.Format("2006-01-02 15:04:05"),
-		event.Method,
-		escapeHTML(event.IP),
+📍 Location: %s
+🔢 Attempts: %s`,
+		escapeHTML(kind),
+		t.serverHeader(),
+		escapeHTML(ip),
 		escapeHTML(location),
+		attempts,
+	)
+	if history != "" {
+		msg += "\n📜 History: " + escapeHTML(history)
+	}
+	return msg
+}
+
+func (t *Telegram) SendExposureAlert(previous, current string) error {
+	msg := fmt.Sprintf(`🛡️ <b>SSH Exposure Changed</b>
+%s
+
+<b>Before:</b>
+%s
+
+<b>Now:</b>
+%s`,
+		t.serverHeader(),
+		escapeHTML(previous),
+		escapeHTML(current),
 	)
 
-	if warning != "" {
-		msg += fmt.Sprintf("\n\n⚠️ %s", escapeHTML(warning))
+	return t.send(msg)
+}
+
+func (t *Telegram) SendRebootAlert(bootTime time.Time) error {
+	msg := fmt.Sprintf(`🔄 <b>Server Rebooted</b>
+%s
+📅 Booted: %s`,
+		t.serverHeader(),
+		bootTime.Format("2006-01-02 15:04:05"),
+	)
+	return t.send(msg)
+}
+
+func (t *Telegram) SendDiskSpaceAlert(path string, free, threshold uint64, low bool) error {
+	title := "🔴 <b>Low Disk Space</b>"
+	status := "Failed attempts are now stored as hourly per-IP counts only."
+	if !low {
+		title = "🟢 <b>Disk Space Recovered</b>"
+		status = "Full event storage resumed."
 	}
 
+	msg := fmt.Sprintf(`%s
+%s
+
+💾 Database: %s
+📉 Free: %d MB (threshold %d MB)
+
+%s`,
+		title,
+		t.serverHeader(),
+		escapeHTML(path),
+		free/(1024*1024),
+		threshold/(1024*1024),
+		status,
+	)
 	return t.send(msg)
 }
 
+func (t *Telegram) SendResourceAlert(memoryMB, memoryLimitMB, goroutines, goroutineLimit int, high bool) error {
+	title := "🔴 <b>Resource Limit Approached</b>"
+	status := "IP history and GeoIP comparison lookups are paused and in-memory trackers pruned until usage drops."
+	if !high {
+		title = "🟢 <b>Resource Usage Recovered</b>"
+		status = "Lookups resumed."
+	}
+
+	msg := fmt.Sprintf(`%s
+%s
+
+🧠 Memory: %s
+🧵 Goroutines: %s
+
+%s`,
+		title,
+		t.serverHeader(),
+		formatUsage(memoryMB, memoryLimitMB, " MB"),
+		formatUsage(goroutines, goroutineLimit, ""),
+		status,
+	)
+	return t.send(msg)
+}
+
+func (t *Telegram) SendCampaignAlert(c storage.Campaign) error {
+	network := fmt.Sprintf("AS%d", c.ASN)
+	if c.ASOrg != "" {
+		network += " " + c.ASOrg
+	}
+
+	msg := fmt.Sprintf(`🎯 <b>Attack Campaign</b>
+%s
+
+🆔 Campaign: %s
+🛰️ Network: %s
+🌐 IPs: %d (%s)
+👤 Usernames: %s
+🔢 Attempts: %d since %s
+
+Further attempts from this network against these usernames join the campaign without new alerts. See <code>oxiwatch campaigns</code>.`,
+		t.serverHeader(),
+		escapeHTML(c.ID),
+		escapeHTML(network),
+		len(c.IPs),
+		escapeHTML(preview(c.IPs, 5)),
+		escapeHTML(preview(c.Usernames, 10)),
+		c.Attempts,
+		c.FirstSeen.Format("15:04:05"),
+	)
+	return t.send(msg)
+}
+
+// preview lists up to limit items and how many more there are.
+func preview(items []string, limit int) string {
+	if len(items) <= limit {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s, +%d more", strings.Join(items[:limit], ", "), len(items)-limit)
+}
+
+func formatUsage(used, limit int, unit string) string {
+	if limit <= 0 {
+		return fmt.Sprintf("%d%s (no limit)", used, unit)
+	}
+	return fmt.Sprintf("%d%s of %d%s (%d%%)", used, unit, limit, unit, used*100/limit)
+}
+
+func (t *Telegram) SendSpikeAlert(attempts int, hourlyAverage float64, baselineDays int) error {
+	ratio := "no failed attempts in the baseline"
+	if hourlyAverage > 0 {
+		ratio = fmt.Sprintf("%.1fx the %d-day hourly average of %.1f", float64(attempts)/hourlyAverage, baselineDays, hourlyAverage)
+	}
+
+	msg := fmt.Sprintf(`📈 <b>Failed Login Spike</b>
+%s
+📅 Time: %s
+🔢 Last hour: %d failed attempts
+📊 Baseline: %s
+
+A new attack wave may be under way even if no single IP stands out.`,
+		t.serverHeader(),
+		time.Now().Format("2006-01-02 15:04:05"),
+		attempts,
+		ratio,
+	)
+	return t.send(msg)
+}
+
+func (t *Telegram) SendClockJumpAlert(jump time.Duration) error {
+	direction := "forward"
+	if jump < 0 {
+		direction = "backward"
+		jump = -jump
+	}
+
+	msg := fmt.Sprintf(`⏰ <b>System Clock Jumped</b>
+%s
+📅 Time: %s
+
+The clock moved %s by %s. Check NTP synchronisation; login timestamps, cooldowns and location-change warnings around this time may be inaccurate.`,
+		t.serverHeader(),
+		time.Now().Format("2006-01-02 15:04:05"),
+		direction,
+		jump.Round(time.Second),
+	)
+	return t.send(msg)
+}
+
+type StatusCounter struct {
+	Service   string
+	Success   int
+	Failed    int
+	UniqueIPs int
+}
+
+type Status struct {
+	Counters      []StatusCounter
+	ActiveAttacks int
+	LowDisk       bool
+	Updated       time.Time
+}
+
+func (t *Telegram) SendStatus(status Status) (int, error) {
+	return t.sendWithID(t.statusMessage(status))
+}
+
+func (t *Telegram) EditStatus(messageID int, status Status) error {
+	return t.edit(messageID, t.statusMessage(status))
+}
+
+func (t *Telegram) statusMessage(status Status) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("📌 <b>Status for %s</b>\n%s\n\n", status.Updated.Format("2006-01-02"), t.serverHeader()))
+
+	if len(status.Counters) == 0 {
+		b.WriteString("No login activity today.\n")
+	}
+	for _, c := range status.Counters {
+		b.WriteString(fmt.Sprintf("<b>%s</b>: %d successful, %d failed, %d IPs\n",
+			escapeHTML(parser.ServiceLabel(c.Service)), c.Success, c.Failed, c.UniqueIPs))
+	}
+
+	if status.ActiveAttacks > 0 {
+		b.WriteString(fmt.Sprintf("\n🚨 Active detections: %d\n", status.ActiveAttacks))
+	}
+	if status.LowDisk {
+		b.WriteString("\n💾 Low disk space: aggregating failed attempts\n")
+	}
+
+	b.WriteString(fmt.Sprintf("\n🕐 Updated %s", status.Updated.Format("15:04")))
+	return b.String()
+}
+
+func (t *Telegram) Pin(messageID int) error {
+	return t.transport.Pin(messageID)
+}
+
+func (t *Telegram) Unpin(messageID int) error {
+	return t.transport.Unpin(messageID)
+}
+
 func (t *Telegram) SendDailyReport(report string) error {
 	return t.send(report)
 }
 
 func (t *Telegram) SendTestMessage() error {
 	msg := fmt.Sprintf(`✅ <b>OxiWatch Test Message</b>
-🖥️ Server: %s
+%s
 📅 Time: %s
 
 Connection successful!`,
-		escapeHTML(t.serverInfo),
+		t.serverHeader(),
 		time.Now().Format("2006-01-02 15:04:05"),
 	)
 	return t.send(msg)
@@ -122,45 +444,100 @@ Connection successful!`,
 
 func (t *Telegram) SendStartupMessage(version string) error {
 	msg := fmt.Sprintf(`🟢 <b>OxiWatch Started</b>
-🖥️ Server: %s
+%s
 📅 Time: %s
 📦 Version: %s`,
-		escapeHTML(t.serverInfo),
+		t.serverHeader(),
 		time.Now().Format("2006-01-02 15:04:05"),
 		escapeHTML(version),
 	)
 	return t.send(msg)
 }
 
+// SendWhatsNew announces the first start after an upgrade with a summary
+// of the release notes, or only a link when they could not be fetched.
+func (t *Telegram) SendWhatsNew(previous, current string, notes []string, url string) error {
+	msg := fmt.Sprintf(`🆕 <b>OxiWatch Upgraded</b>
+%s
+📦 Version: %s → %s
+`,
+		t.serverHeader(),
+		escapeHTML(previous),
+		escapeHTML(current),
+	)
+	if len(notes) > 0 {
+		msg += "\n<b>What's new:</b>\n"
+		for _, note := range notes {
+			msg += "• " + escapeHTML(note) + "\n"
+		}
+	}
+	msg += fmt.Sprintf("\nRelease notes: %s", escapeHTML(url))
+	return t.send(msg)
+}
+
 func (t *Telegram) SendShutdownMessage() error {
 	msg := fmt.Sprintf(`🔴 <b>OxiWatch Stopped</b>
-🖥️ Server: %s
+%s
 📅 Time: %s`,
-		escapeHTML(t.serverInfo),
+		t.serverHeader(),
 		time.Now().Format("2006-01-02 15:04:05"),
 	)
 	return t.send(msg)
 }
 
 func (t *Telegram) send(text string) error {
-	msg := tgbotapi.NewMessage(t.chatID, text)
-	msg.ParseMode = tgbotapi.ModeHTML
+	_, err := t.sendWithID(text)
+	return err
+}
 
-	_, err := t.bot.Send(msg)
+func (t *Telegram) sendWithID(text string) (int, error) {
+	text, isHTML := applyTheme(t.theme, text)
+	id, err := t.transport.Send(text, isHTML)
+	t.recordFailure(err)
+	return id, err
+}
+
+func (t *Telegram) edit(messageID int, text string) error {
+	text, isHTML := applyTheme(t.theme, text)
+	err := t.transport.Edit(messageID, text, isHTML)
+	t.recordFailure(err)
 	return err
 }
 
-func formatLocation(ip, country, city string) string {
-	if country == "" && city == "" {
-		return ip
+func (t *Telegram) recordFailure(err error) {
+	if err == nil {
+		return
 	}
-	if city != "" && country != "" {
-		return fmt.Sprintf("%s, %s", city, country)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failures.Count++
+	t.failures.LastError = err.Error()
+	t.failures.LastAt = time.Now()
+}
+
+func (t *Telegram) Failures() Failures {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.failures
+}
+
+func formatLocation(ip string, loc geoip.Location) string {
+	var location string
+	switch {
+	case loc.Country == "" && loc.City == "":
+		return ip
+	case loc.City != "" && loc.Country != "":
+		location = fmt.Sprintf("%s, %s", loc.City, loc.Country)
+	case loc.Country != "":
+		location = loc.Country
+	default:
+		location = loc.City
 	}
-	if country != "" {
-		return country
+
+	if flag := geoip.Flag(loc.CountryCode); flag != "" {
+		location = flag + " " + location
 	}
-	return city
+	return location
 }
 
 func escapeHTML(s string) string {
@@ -0,0 +1,101 @@
+package notifier
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	ThemeEmoji   = "emoji"
+	ThemeMinimal = "minimal"
+	ThemePlain   = "plain"
+)
+
+// severities maps the leading emoji of a message title to the tag used by
+// the plain theme, which has no other way to show how urgent a message is.
+var severities = map[string]string{
+	"🚨":  "[CRIT]",
+	"🔴":  "[CRIT]",
+	"🔐":  "[WARN]",
+	"🛡️": "[WARN]",
+	"🔄":  "[WARN]",
+	"⏰":  "[WARN]",
+	"📈":  "[WARN]",
+}
+
+var htmlTag = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+
+// applyTheme rewrites an emoji-rich HTML message for the given theme and
+// reports whether the result is still HTML.
+//
+// minimal keeps the title emoji so the severity stays visible at a glance
+// and drops the decorative ones in front of every other line. plain removes
+// all emoji and markup and tags the title with its severity, for channels
+// such as SMS gateways and pagers that forward text verbatim.
+func applyTheme(theme, text string) (string, bool) {
+	switch theme {
+	case ThemeMinimal:
+		lines := strings.Split(text, "\n")
+		for i := 1; i < len(lines); i++ {
+			lines[i] = stripLeadingEmoji(lines[i])
+		}
+		return strings.Join(lines, "\n"), true
+	case ThemePlain:
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			line = html.UnescapeString(htmlTag.ReplaceAllString(line, ""))
+			if i == 0 {
+				line = severityTag(line) + stripEmoji(line)
+			} else {
+				line = stripEmoji(line)
+			}
+			lines[i] = strings.TrimSpace(line)
+		}
+		return strings.Join(lines, "\n"), false
+	default:
+		return text, true
+	}
+}
+
+func severityTag(title string) string {
+	for prefix, tag := range severities {
+		if strings.HasPrefix(title, prefix) {
+			return tag + " "
+		}
+	}
+	return "[INFO] "
+}
+
+func stripLeadingEmoji(line string) string {
+	trimmed := strings.TrimLeftFunc(line, func(r rune) bool {
+		return isEmoji(r) || r == ' '
+	})
+	if len(trimmed) == len(line) {
+		return line
+	}
+	return trimmed
+}
+
+func stripEmoji(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if isEmoji(r) {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF, // pictographs, flags, emoticons
+		r >= 0x2600 && r <= 0x27BF, // misc symbols and dingbats
+		r >= 0x2300 && r <= 0x23FF, // technical (⏰, ⏱)
+		r >= 0x2B00 && r <= 0x2BFF, // arrows (⬆)
+		r == 0xFE0F, r == 0x200D:
+		return true
+	}
+	return unicode.Is(unicode.Variation_Selector, r)
+}
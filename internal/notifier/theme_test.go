@@ -0,0 +1,33 @@
+package notifier
+
+import "testing"
+
+const themeSample = "🚨 <b>Web Burst</b>\n🖥️ Server: web1 &amp; co\n\n🌐 IP: 1.2.3.4\n📍 Location: 🇩🇪 Berlin, Germany"
+
+func TestApplyThemeEmoji(t *testing.T) {
+	got, isHTML := applyTheme(ThemeEmoji, themeSample)
+	if got != themeSample || !isHTML {
+		t.Errorf("expected message unchanged, got %q (html=%v)", got, isHTML)
+	}
+}
+
+func TestApplyThemeMinimal(t *testing.T) {
+	want := "🚨 <b>Web Burst</b>\nServer: web1 &amp; co\n\nIP: 1.2.3.4\nLocation: 🇩🇪 Berlin, Germany"
+	got, isHTML := applyTheme(ThemeMinimal, themeSample)
+	if got != want || !isHTML {
+		t.Errorf("expected %q, got %q (html=%v)", want, got, isHTML)
+	}
+}
+
+func TestApplyThemePlain(t *testing.T) {
+	want := "[CRIT] Web Burst\nServer: web1 & co\n\nIP: 1.2.3.4\nLocation: Berlin, Germany"
+	got, isHTML := applyTheme(ThemePlain, themeSample)
+	if got != want || isHTML {
+		t.Errorf("expected %q, got %q (html=%v)", want, got, isHTML)
+	}
+
+	got, _ = applyTheme(ThemePlain, "📊 <b>Report</b>")
+	if got != "[INFO] Report" {
+		t.Errorf("expected [INFO] tag, got %q", got)
+	}
+}
@@ -0,0 +1,123 @@
+package notifier
+
+import (
+	"fmt"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Transport delivers rendered messages. The Telegram Bot API is the only
+// real one; Recorder keeps messages in memory for tests.
+type Transport interface {
+	Send(text string, html bool) (int, error)
+	Edit(messageID int, text string, html bool) error
+	Pin(messageID int) error
+	Unpin(messageID int) error
+}
+
+type botTransport struct {
+	bot    *tgbotapi.BotAPI
+	chatID int64
+}
+
+func (b *botTransport) Send(text string, html bool) (int, error) {
+	msg := tgbotapi.NewMessage(b.chatID, text)
+	if html {
+		msg.ParseMode = tgbotapi.ModeHTML
+	}
+
+	sent, err := b.bot.Send(msg)
+	if err != nil {
+		return 0, err
+	}
+	return sent.MessageID, nil
+}
+
+func (b *botTransport) Edit(messageID int, text string, html bool) error {
+	msg := tgbotapi.NewEditMessageText(b.chatID, messageID, text)
+	if html {
+		msg.ParseMode = tgbotapi.ModeHTML
+	}
+
+	_, err := b.bot.Send(msg)
+	return err
+}
+
+func (b *botTransport) Pin(messageID int) error {
+	_, err := b.bot.Request(tgbotapi.PinChatMessageConfig{
+		ChatID:              b.chatID,
+		MessageID:           messageID,
+		DisableNotification: true,
+	})
+	return err
+}
+
+func (b *botTransport) Unpin(messageID int) error {
+	_, err := b.bot.Request(tgbotapi.UnpinChatMessageConfig{
+		ChatID:    b.chatID,
+		MessageID: messageID,
+	})
+	return err
+}
+
+// Message is a message as seen by a Recorder, after all edits.
+type Message struct {
+	ID     int
+	Text   string
+	HTML   bool
+	Edits  int
+	Pinned bool
+}
+
+type Recorder struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) Send(text string, html bool) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := len(r.messages) + 1
+	r.messages = append(r.messages, Message{ID: id, Text: text, HTML: html})
+	return id, nil
+}
+
+func (r *Recorder) Edit(messageID int, text string, html bool) error {
+	return r.update(messageID, func(m *Message) {
+		m.Text = text
+		m.HTML = html
+		m.Edits++
+	})
+}
+
+func (r *Recorder) Pin(messageID int) error {
+	return r.update(messageID, func(m *Message) { m.Pinned = true })
+}
+
+func (r *Recorder) Unpin(messageID int) error {
+	return r.update(messageID, func(m *Message) { m.Pinned = false })
+}
+
+func (r *Recorder) update(messageID int, fn func(*Message)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if messageID < 1 || messageID > len(r.messages) {
+		return fmt.Errorf("message %d not found", messageID)
+	}
+	fn(&r.messages[messageID-1])
+	return nil
+}
+
+// Messages returns a copy of everything sent so far, in order.
+func (r *Recorder) Messages() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Message(nil), r.messages...)
+}
@@ -0,0 +1,65 @@
+// Package output ships every stored event to log pipelines, as opposed to
+// the alerts in package notifier, which only cover detections.
+package output
+
+import (
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+	"github.com/oxisoft/oxiwatch/internal/parser"
+)
+
+// Event is a parsed and enriched event in the form written to outputs.
+type Event struct {
+	Time        time.Time `json:"time"`
+	Server      string    `json:"server"`
+	Service     string    `json:"service"`
+	Type        string    `json:"type"`
+	User        string    `json:"user"`
+	IP          string    `json:"ip"`
+	Port        int       `json:"port,omitempty"`
+	Method      string    `json:"method,omitempty"`
+	InvalidUser bool      `json:"invalid_user,omitempty"`
+	MFA         bool      `json:"mfa,omitempty"`
+	AuthSource  string    `json:"auth_source,omitempty"`
+	KeyType     string    `json:"key_type,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Country     string    `json:"country,omitempty"`
+	CountryCode string    `json:"country_code,omitempty"`
+	City        string    `json:"city,omitempty"`
+	ASN         uint      `json:"asn,omitempty"`
+	ASOrg       string    `json:"as_org,omitempty"`
+}
+
+func NewEvent(server string, event *parser.SSHEvent, loc geoip.Location) Event {
+	service := event.Service
+	if service == "" {
+		service = parser.ServiceSSH
+	}
+	return Event{
+		Time:        event.Timestamp,
+		Server:      server,
+		Service:     service,
+		Type:        string(event.EventType),
+		User:        event.Username,
+		IP:          event.IP,
+		Port:        event.Port,
+		Method:      event.Method,
+		InvalidUser: event.InvalidUser,
+		MFA:         event.MFA,
+		AuthSource:  event.AuthSource,
+		KeyType:     event.KeyType,
+		Fingerprint: event.Fingerprint,
+		Country:     loc.Country,
+		CountryCode: loc.CountryCode,
+		City:        loc.City,
+		ASN:         loc.ASN,
+		ASOrg:       loc.ASOrg,
+	}
+}
+
+// Sink receives every event the daemon stores.
+type Sink interface {
+	Write(event Event) error
+	Close() error
+}
@@ -0,0 +1,82 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/fixture"
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+)
+
+func TestFileRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	event := NewEvent("web-1", fixture.Failure("root", "203.0.113.5").Build(), fixture.Location("DE", "Germany", "Berlin"))
+	line, _ := json.Marshal(event)
+
+	// Room for two lines per file, two rotated files kept.
+	f := NewFile(path, int64(2*(len(line)+1)), 2)
+	for i := 0; i < 7; i++ {
+		if err := f.Write(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]int{"": 1, ".1": 2, ".2": 2} {
+		if got := countLines(t, path+name); got != want {
+			t.Errorf("expected %d lines in events.ndjson%s, got %d", want, name, got)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected no third rotated file, got %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var got Event
+	if err := json.NewDecoder(file).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Server != "web-1" || got.Type != "failure" || got.Service != "ssh" || got.User != "root" || got.CountryCode != "DE" {
+		t.Errorf("unexpected event: %+v", got)
+	}
+}
+
+func TestFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	event := NewEvent("web-1", fixture.Success("alice", "192.0.2.1").At(time.Now()).Build(), geoip.Location{})
+	for i := 0; i < 2; i++ {
+		f := NewFile(path, 1<<20, 1)
+		if err := f.Write(event); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	if got := countLines(t, path); got != 2 {
+		t.Errorf("expected a reopened file to be appended to, got %d lines", got)
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	n := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,271 @@
+package output
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/notifier"
+)
+
+const (
+	forwardBatchSize     = 100
+	forwardFlushInterval = time.Second
+	forwardTimeout       = 30 * time.Second
+	forwardMaxBackoff    = time.Minute
+	forwardCloseTimeout  = 5 * time.Second
+)
+
+// Forward ships events to Fluentd, Fluent Bit or Vector over the Fluent
+// Forward protocol. Events are sent in batches, and each batch waits for
+// the receiver's ack before the next one goes out. While the receiver is
+// slow or down, events queue up to the buffer size; beyond that new
+// events are dropped rather than blocking the daemon.
+type Forward struct {
+	network string
+	address string
+	tag     string
+	logger  *slog.Logger
+	queue   chan Event
+	done    chan struct{}
+	stopped chan struct{}
+	conn    net.Conn
+	reader  *bufio.Reader
+
+	mu       sync.Mutex
+	failures notifier.Failures
+	dropped  int
+}
+
+// NewForward starts shipping to address, either host:port or
+// unix:/path/to/socket, under tag.
+func NewForward(address, tag string, buffer int, logger *slog.Logger) *Forward {
+	network := "tcp"
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		network, address = "unix", path
+	}
+	f := &Forward{
+		network: network,
+		address: address,
+		tag:     tag,
+		logger:  logger,
+		queue:   make(chan Event, buffer),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go f.run()
+	return f
+}
+
+func (f *Forward) Write(event Event) error {
+	select {
+	case f.queue <- event:
+		return nil
+	default:
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.dropped == 0 {
+		f.logger.Warn("forward output buffer full, dropping events", "address", f.address, "buffer", cap(f.queue))
+	}
+	f.dropped++
+	return nil
+}
+
+// Close sends what is still queued, giving up after a few seconds if the
+// receiver does not take it.
+func (f *Forward) Close() error {
+	close(f.done)
+	select {
+	case <-f.stopped:
+	case <-time.After(forwardCloseTimeout):
+		return fmt.Errorf("forward output: %d events not sent", len(f.queue))
+	}
+	return nil
+}
+
+func (f *Forward) Failures() notifier.Failures {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.failures
+}
+
+func (f *Forward) run() {
+	defer close(f.stopped)
+	ticker := time.NewTicker(forwardFlushInterval)
+	defer ticker.Stop()
+
+	var batch []Event
+	for {
+		select {
+		case event := <-f.queue:
+			batch = append(batch, event)
+			if len(batch) < forwardBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-f.done:
+			for len(f.queue) > 0 {
+				batch = append(batch, <-f.queue)
+			}
+			if len(batch) > 0 {
+				if err := f.send(batch); err != nil {
+					f.logger.Warn("failed to forward events on shutdown", "count", len(batch), "error", err)
+				}
+			}
+			f.disconnect()
+			return
+		}
+		f.sendRetrying(batch)
+		batch = nil
+	}
+}
+
+// sendRetrying sends batch until the receiver acks it, backing off between
+// attempts. It only gives up when the output is closed.
+func (f *Forward) sendRetrying(batch []Event) {
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		err := f.send(batch)
+		if err == nil {
+			if attempt > 0 {
+				f.recovered()
+			}
+			return
+		}
+		f.failed(err, attempt == 0)
+
+		select {
+		case <-f.done:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, forwardMaxBackoff)
+	}
+}
+
+func (f *Forward) failed(err error, first bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures.Count++
+	f.failures.LastError = err.Error()
+	f.failures.LastAt = time.Now()
+	if first {
+		f.logger.Warn("failed to forward events, retrying", "address", f.address, "error", err)
+	}
+}
+
+func (f *Forward) recovered() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.logger.Info("forward output recovered", "address", f.address, "dropped", f.dropped)
+	f.dropped = 0
+}
+
+// send writes batch in Forward mode and waits for the ack of its chunk ID.
+// Any error drops the connection, so the next attempt starts clean.
+func (f *Forward) send(batch []Event) error {
+	chunk, err := chunkID()
+	if err != nil {
+		return err
+	}
+	msg, err := encodeForward(f.tag, batch, chunk)
+	if err != nil {
+		return err
+	}
+
+	if f.conn == nil {
+		conn, err := net.DialTimeout(f.network, f.address, forwardTimeout)
+		if err != nil {
+			return err
+		}
+		f.conn, f.reader = conn, bufio.NewReader(conn)
+	}
+
+	err = f.exchange(msg, chunk)
+	if err != nil {
+		f.disconnect()
+	}
+	return err
+}
+
+func (f *Forward) exchange(msg []byte, chunk string) error {
+	f.conn.SetDeadline(time.Now().Add(forwardTimeout))
+	if _, err := f.conn.Write(msg); err != nil {
+		return err
+	}
+	resp, err := readValue(f.reader)
+	if err != nil {
+		return fmt.Errorf("waiting for ack: %w", err)
+	}
+	if m, ok := resp.(map[string]any); !ok || m["ack"] != chunk {
+		return fmt.Errorf("unexpected response %v", resp)
+	}
+	return nil
+}
+
+func (f *Forward) disconnect() {
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn, f.reader = nil, nil
+	}
+}
+
+func chunkID() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(id[:]), nil
+}
+
+// encodeForward builds a Forward mode message:
+// [tag, [[time, record], ...], {"chunk": id, "size": n}].
+func encodeForward(tag string, batch []Event, chunk string) ([]byte, error) {
+	b := appendArrayHeader(nil, 3)
+	b = appendString(b, tag)
+	b = appendArrayHeader(b, len(batch))
+	for _, event := range batch {
+		record, err := eventRecord(event)
+		if err != nil {
+			return nil, err
+		}
+		b = appendArrayHeader(b, 2)
+		b = appendEventTime(b, event.Time)
+		if b, err = appendValue(b, record); err != nil {
+			return nil, err
+		}
+	}
+	b = appendMapHeader(b, 2)
+	b = appendString(b, "chunk")
+	b = appendString(b, chunk)
+	b = appendString(b, "size")
+	b = appendInt(b, int64(len(batch)))
+	return b, nil
+}
+
+// eventRecord turns event into the same fields as the event log, minus the
+// time, which travels as the entry's EventTime.
+func eventRecord(event Event) (map[string]any, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	var record map[string]any
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	delete(record, "time")
+	return record, nil
+}
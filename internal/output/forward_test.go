@@ -0,0 +1,113 @@
+package output
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/fixture"
+)
+
+// forwardServer accepts Forward mode messages and acks them, except that
+// it drops the first dropFirst connections without an ack.
+func forwardServer(t *testing.T, dropFirst int) (string, <-chan []any) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	messages := make(chan []any, 10)
+	go func() {
+		for n := 0; ; n++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn, drop bool) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					v, err := readValue(r)
+					if err != nil {
+						return
+					}
+					msg := v.([]any)
+					messages <- msg
+					if drop {
+						return
+					}
+					chunk := msg[2].(map[string]any)["chunk"].(string)
+					ack := appendString(appendString(appendMapHeader(nil, 1), "ack"), chunk)
+					conn.Write(ack)
+				}
+			}(conn, n < dropFirst)
+		}
+	}()
+	return ln.Addr().String(), messages
+}
+
+func receive(t *testing.T, messages <-chan []any) []any {
+	t.Helper()
+	select {
+	case msg := <-messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a forward message")
+		return nil
+	}
+}
+
+func TestForwardSendsBatches(t *testing.T) {
+	address, messages := forwardServer(t, 0)
+	f := NewForward(address, "oxiwatch.event", 100, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer f.Close()
+
+	ts := time.Date(2026, 3, 1, 12, 0, 0, 500, time.UTC)
+	f.Write(NewEvent("web-1", fixture.Failure("root", "203.0.113.5").At(ts).Port(40000).Build(), fixture.Location("DE", "Germany", "Berlin")))
+	f.Write(NewEvent("web-1", fixture.Success("alice", "192.0.2.1").At(ts).Build(), fixture.Location("DE", "Germany", "Berlin")))
+
+	msg := receive(t, messages)
+	if msg[0] != "oxiwatch.event" {
+		t.Errorf("unexpected tag %v", msg[0])
+	}
+	entries := msg[1].([]any)
+	if len(entries) != 2 {
+		t.Fatalf("expected both events in one batch, got %d", len(entries))
+	}
+	entry := entries[0].([]any)
+	eventTime := entry[0].([]byte)
+	if eventTime[0] != 0 || bigEndian(eventTime[1:5]) != uint64(ts.Unix()) || bigEndian(eventTime[5:]) != 500 {
+		t.Errorf("unexpected event time %v", eventTime)
+	}
+	record := entry[1].(map[string]any)
+	if record["type"] != "failure" || record["ip"] != "203.0.113.5" || record["port"] != uint64(40000) || record["country_code"] != "DE" {
+		t.Errorf("unexpected record %v", record)
+	}
+	if _, ok := record["time"]; ok {
+		t.Error("expected the time only in the entry's event time")
+	}
+	if options := msg[2].(map[string]any); options["size"] != int64(2) {
+		t.Errorf("unexpected options %v", options)
+	}
+}
+
+func TestForwardResendsUnackedBatch(t *testing.T) {
+	address, messages := forwardServer(t, 1)
+	f := NewForward(address, "oxiwatch.event", 100, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer f.Close()
+
+	f.Write(NewEvent("web-1", fixture.Failure("root", "203.0.113.5").Build(), fixture.Location("", "", "")))
+
+	first := receive(t, messages)
+	second := receive(t, messages)
+	if len(second[1].([]any)) != 1 || second[2].(map[string]any)["chunk"] == first[2].(map[string]any)["chunk"] {
+		t.Errorf("expected the event resent in a new chunk, got %v", second)
+	}
+	if failures := f.Failures(); failures.Count != 1 {
+		t.Errorf("expected one failed attempt, got %+v", failures)
+	}
+}
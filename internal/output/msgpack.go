@@ -0,0 +1,248 @@
+package output
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// Just enough MessagePack for the Fluent Forward protocol: encoding the
+// JSON-like values of an event record, and decoding the server's ack.
+
+func appendMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n < 1<<16:
+		return binary.BigEndian.AppendUint16(append(b, 0xde), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdf), uint32(n))
+	}
+}
+
+func appendArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n < 1<<16:
+		return binary.BigEndian.AppendUint16(append(b, 0xdc), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdd), uint32(n))
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n < 1<<8:
+		b = append(b, 0xd9, byte(n))
+	case n < 1<<16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendInt(b []byte, n int64) []byte {
+	switch {
+	case n >= 0 && n < 128:
+		return append(b, byte(n))
+	case n >= 0:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), uint64(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(n))
+	}
+}
+
+// appendEventTime encodes t as the protocol's EventTime extension, which
+// keeps nanoseconds unlike a plain integer timestamp.
+func appendEventTime(b []byte, t time.Time) []byte {
+	b = append(b, 0xd7, 0x00)
+	b = binary.BigEndian.AppendUint32(b, uint32(t.Unix()))
+	return binary.BigEndian.AppendUint32(b, uint32(t.Nanosecond()))
+}
+
+// appendValue encodes a value as produced by encoding/json decoding into
+// an interface{}. Map keys are sorted for stable output.
+func appendValue(b []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case string:
+		return appendString(b, v), nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return appendInt(b, int64(v)), nil
+		}
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(v)), nil
+	case []any:
+		b = appendArrayHeader(b, len(v))
+		for _, item := range v {
+			var err error
+			if b, err = appendValue(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = appendMapHeader(b, len(v))
+		for _, k := range keys {
+			b = appendString(b, k)
+			var err error
+			if b, err = appendValue(b, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+}
+
+// readValue decodes one value. Strings and binaries become string, integers
+// int64 or uint64, and extension types their raw payload as []byte.
+func readValue(r *bufio.Reader) (any, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case tag <= 0x7f:
+		return int64(tag), nil
+	case tag >= 0xe0:
+		return int64(int8(tag)), nil
+	case tag&0xf0 == 0x80:
+		return readMap(r, int(tag&0x0f))
+	case tag&0xf0 == 0x90:
+		return readArray(r, int(tag&0x0f))
+	case tag&0xe0 == 0xa0:
+		return readString(r, int(tag&0x1f))
+	}
+
+	switch tag {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xd9:
+		return readSized(r, 1, readString)
+	case 0xc5, 0xda:
+		return readSized(r, 2, readString)
+	case 0xc6, 0xdb:
+		return readSized(r, 4, readString)
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		raw, err := readBytes(r, 1<<(tag-0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return bigEndian(raw), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (tag - 0xd0)
+		raw, err := readBytes(r, size)
+		if err != nil {
+			return nil, err
+		}
+		shift := 64 - 8*size
+		return int64(bigEndian(raw)<<shift) >> shift, nil
+	case 0xca:
+		raw, err := readBytes(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(uint32(bigEndian(raw)))), nil
+	case 0xcb:
+		raw, err := readBytes(r, 8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(bigEndian(raw)), nil
+	case 0xdc:
+		return readSized(r, 2, readArray)
+	case 0xdd:
+		return readSized(r, 4, readArray)
+	case 0xde:
+		return readSized(r, 2, readMap)
+	case 0xdf:
+		return readSized(r, 4, readMap)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return readBytes(r, 1+1<<(tag-0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		return readSized(r, 1<<(tag-0xc7), func(r *bufio.Reader, n int) (any, error) {
+			return readBytes(r, 1+n)
+		})
+	}
+	return nil, fmt.Errorf("msgpack: invalid type byte 0x%02x", tag)
+}
+
+func readSized(r *bufio.Reader, lenSize int, read func(*bufio.Reader, int) (any, error)) (any, error) {
+	raw, err := readBytes(r, lenSize)
+	if err != nil {
+		return nil, err
+	}
+	return read(r, int(bigEndian(raw)))
+}
+
+func readString(r *bufio.Reader, n int) (any, error) {
+	raw, err := readBytes(r, n)
+	return string(raw), err
+}
+
+func readArray(r *bufio.Reader, n int) (any, error) {
+	items := make([]any, 0, min(n, 1024))
+	for i := 0; i < n; i++ {
+		v, err := readValue(r)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
+
+func readMap(r *bufio.Reader, n int) (any, error) {
+	m := make(map[string]any, min(n, 1024))
+	for i := 0; i < n; i++ {
+		k, err := readValue(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readValue(r)
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(k)] = v
+	}
+	return m, nil
+}
+
+func readBytes(r *bufio.Reader, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	_, err := io.ReadFull(r, buf)
+	return buf, err
+}
+
+func bigEndian(raw []byte) uint64 {
+	var n uint64
+	for _, c := range raw {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
@@ -0,0 +1,149 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EventMFA     EventType = "mfa"
+	EventPartial EventType = "partial"
+)
+
+const (
+	MFAGoogleAuthenticator = "google_authenticator"
+	MFADuo                 = "duo"
+	MFAU2F                 = "u2f"
+)
+
+// IsMFAMethod reports whether method names a second factor provider.
+func IsMFAMethod(method string) bool {
+	return method == MFAGoogleAuthenticator || method == MFADuo || method == MFAU2F
+}
+
+var (
+	messagePartialPattern = regexp.MustCompile(
+		`^Partial\s+(\S+)\s+for\s+(\S+)\s+from\s+(\S+)\s+port\s+(\d+)`,
+	)
+
+	googleAuthSuccessPattern = regexp.MustCompile(`^Accepted google_authenticator for (\S+)`)
+	googleAuthFailedPattern  = regexp.MustCompile(`^Invalid verification code for (\S+)`)
+
+	duoPattern = regexp.MustCompile(`^(Successful|Failed) Duo login for '([^']+)'(?: from (\S+))?`)
+
+	u2fRequestPattern = regexp.MustCompile(`pam_u2f\b.*: Requesting authentication for user (\S+)`)
+	u2fDonePattern    = regexp.MustCompile(`pam_u2f\b.*: done\. \[([^\]]+)\]`)
+)
+
+// ParseMFAMessage returns EventMFA/EventPartial for completed factors so they
+// can be matched with the following Accepted line; rejected codes are failures.
+func ParseMFAMessage(identifier, message string, timestamp time.Time) *SSHEvent {
+	if matches := messagePartialPattern.FindStringSubmatch(message); matches != nil {
+		port, _ := strconv.Atoi(matches[4])
+		return &SSHEvent{
+			Timestamp: timestamp,
+			EventType: EventPartial,
+			Service:   ServiceSSH,
+			Method:    matches[1],
+			Username:  matches[2],
+			IP:        matches[3],
+			Port:      port,
+		}
+	}
+
+	if strings.Contains(identifier, "pam_google_authenticator") {
+		if matches := googleAuthSuccessPattern.FindStringSubmatch(message); matches != nil {
+			return &SSHEvent{
+				Timestamp: timestamp,
+				EventType: EventMFA,
+				Service:   ServiceSSH,
+				Method:    MFAGoogleAuthenticator,
+				Username:  matches[1],
+			}
+		}
+		if matches := googleAuthFailedPattern.FindStringSubmatch(message); matches != nil {
+			return &SSHEvent{
+				Timestamp: timestamp,
+				EventType: EventFailure,
+				Service:   ServiceSSH,
+				Method:    MFAGoogleAuthenticator,
+				Username:  matches[1],
+			}
+		}
+		return nil
+	}
+
+	if matches := duoPattern.FindStringSubmatch(message); matches != nil {
+		eventType := EventFailure
+		if matches[1] == "Successful" {
+			eventType = EventMFA
+		}
+		return &SSHEvent{
+			Timestamp: timestamp,
+			EventType: eventType,
+			Service:   ServiceSSH,
+			Method:    MFADuo,
+			Username:  matches[2],
+			IP:        matches[3],
+		}
+	}
+
+	return nil
+}
+
+// maxU2FPending bounds the processes U2FTracker waits on; more means result
+// lines are being missed, and the oldest state is dropped.
+const maxU2FPending = 1000
+
+// U2FTracker follows pam_u2f's debug output, logged with `debug
+// debug_file=syslog`. The result line does not name the user, so it is
+// matched by process ID with the preceding request line.
+type U2FTracker struct {
+	users map[string]string
+}
+
+func NewU2FTracker() *U2FTracker {
+	return &U2FTracker{users: make(map[string]string)}
+}
+
+// Parse returns EventMFA for a touched security key and EventFailure for a
+// rejected one, like ParseMFAMessage, and nil for other lines.
+func (u *U2FTracker) Parse(pid, message string, timestamp time.Time) *SSHEvent {
+	if matches := u2fRequestPattern.FindStringSubmatch(message); matches != nil {
+		if len(u.users) >= maxU2FPending {
+			clear(u.users)
+		}
+		u.users[pid] = matches[1]
+		return nil
+	}
+
+	matches := u2fDonePattern.FindStringSubmatch(message)
+	if matches == nil {
+		return nil
+	}
+	username, ok := u.users[pid]
+	if !ok {
+		return nil
+	}
+	delete(u.users, pid)
+
+	var eventType EventType
+	switch matches[1] {
+	case "Success":
+		eventType = EventMFA
+	case "Authentication failure":
+		eventType = EventFailure
+	default:
+		// Skipped, e.g. for a user without registered keys.
+		return nil
+	}
+	return &SSHEvent{
+		Timestamp: timestamp,
+		EventType: eventType,
+		Service:   ServiceSSH,
+		Method:    MFAU2F,
+		Username:  username,
+	}
+}
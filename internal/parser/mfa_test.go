@@ -0,0 +1,144 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseMFAGoogleAuthenticator(t *testing.T) {
+	ts := time.Date(2026, time.January, 20, 14, 32, 15, 0, time.UTC)
+
+	event := ParseMFAMessage("sshd(pam_google_authenticator)", "Accepted google_authenticator for alice", ts)
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if event.EventType != EventMFA {
+		t.Errorf("expected EventMFA, got %s", event.EventType)
+	}
+	if event.Username != "alice" {
+		t.Errorf("expected username alice, got %s", event.Username)
+	}
+	if event.Method != MFAGoogleAuthenticator {
+		t.Errorf("expected method %s, got %s", MFAGoogleAuthenticator, event.Method)
+	}
+
+	event = ParseMFAMessage("sshd(pam_google_authenticator)", "Invalid verification code for alice", ts)
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if event.EventType != EventFailure {
+		t.Errorf("expected EventFailure, got %s", event.EventType)
+	}
+}
+
+func TestParseMFADuo(t *testing.T) {
+	ts := time.Date(2026, time.January, 20, 14, 32, 15, 0, time.UTC)
+
+	event := ParseMFAMessage("pam_duo", "Successful Duo login for 'bob' from 203.0.113.7", ts)
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if event.EventType != EventMFA {
+		t.Errorf("expected EventMFA, got %s", event.EventType)
+	}
+	if event.Username != "bob" {
+		t.Errorf("expected username bob, got %s", event.Username)
+	}
+	if event.IP != "203.0.113.7" {
+		t.Errorf("expected IP 203.0.113.7, got %s", event.IP)
+	}
+
+	event = ParseMFAMessage("pam_duo", "Failed Duo login for 'bob' from 203.0.113.7", ts)
+	if event == nil || event.EventType != EventFailure {
+		t.Errorf("expected failure event, got %+v", event)
+	}
+}
+
+func TestParseMFAPartial(t *testing.T) {
+	ts := time.Date(2026, time.January, 20, 14, 32, 15, 0, time.UTC)
+	message := "Partial publickey for alice from 10.0.0.5 port 50022 ssh2: ED25519 SHA256:abc"
+
+	event := ParseMFAMessage("sshd", message, ts)
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if event.EventType != EventPartial {
+		t.Errorf("expected EventPartial, got %s", event.EventType)
+	}
+	if event.IP != "10.0.0.5" || event.Port != 50022 {
+		t.Errorf("expected 10.0.0.5:50022, got %s:%d", event.IP, event.Port)
+	}
+}
+
+func TestParseMFAU2F(t *testing.T) {
+	ts := time.Date(2026, time.January, 20, 14, 32, 15, 0, time.UTC)
+	const (
+		request = "debug(pam_u2f): pam-u2f.c:288 (pam_sm_authenticate): Requesting authentication for user %s"
+		done    = "debug(pam_u2f): pam-u2f.c:415 (pam_sm_authenticate): done. [%s]"
+	)
+
+	tests := []struct {
+		name   string
+		result string
+		want   EventType
+	}{
+		{"touched key", "Success", EventMFA},
+		{"wrong key", "Authentication failure", EventFailure},
+		{"no registered keys", "Ignore module", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewU2FTracker()
+			if event := tracker.Parse("4242", fmt.Sprintf(request, "alice"), ts); event != nil {
+				t.Fatalf("expected no event for the request line, got %+v", event)
+			}
+			// Another session's result must not be attributed to alice.
+			if event := tracker.Parse("4243", fmt.Sprintf(done, "Success"), ts); event != nil {
+				t.Fatalf("expected no event for an unknown process, got %+v", event)
+			}
+
+			event := tracker.Parse("4242", fmt.Sprintf(done, tt.result), ts)
+			if tt.want == "" {
+				if event != nil {
+					t.Errorf("expected no event, got %+v", event)
+				}
+				return
+			}
+			if event == nil {
+				t.Fatal("expected event, got nil")
+			}
+			if event.EventType != tt.want || event.Username != "alice" || event.Method != MFAU2F {
+				t.Errorf("unexpected event: %+v", event)
+			}
+			if again := tracker.Parse("4242", fmt.Sprintf(done, tt.result), ts); again != nil {
+				t.Errorf("expected the result to be used once, got %+v", again)
+			}
+		})
+	}
+}
+
+func TestParseMessageKeyboardInteractive(t *testing.T) {
+	ts := time.Date(2026, time.January, 20, 14, 32, 15, 0, time.UTC)
+	event := ParseMessage("Accepted keyboard-interactive/pam for alice from 10.0.0.5 port 50022 ssh2", ts)
+
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if event.Method != "keyboard-interactive/pam" {
+		t.Errorf("expected method keyboard-interactive/pam, got %s", event.Method)
+	}
+}
+
+func TestParseMFANonMFA(t *testing.T) {
+	messages := []string{
+		"pam_unix(sshd:session): session opened for user alice",
+		"Accepted publickey for alice from 10.0.0.5 port 50022 ssh2",
+		"",
+	}
+	for _, msg := range messages {
+		if event := ParseMFAMessage("sshd", msg, time.Now()); event != nil {
+			t.Errorf("expected nil for message %q, got %+v", msg, event)
+		}
+	}
+}
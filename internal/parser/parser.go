@@ -1,6 +1,9 @@
 package parser
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"regexp"
 	"strconv"
 	"time"
@@ -13,34 +16,145 @@ const (
 	EventFailure EventType = "failure"
 )
 
+const (
+	ServiceSSH = "ssh"
+	ServiceWeb = "web"
+)
+
 type SSHEvent struct {
 	Timestamp   time.Time
 	EventType   EventType
+	Service     string
 	Username    string
 	IP          string
 	Port        int
 	Method      string
 	InvalidUser bool
+	MFA         bool
+	AuthSource  string
+	KeyType     string
+	Fingerprint string
+	// Occurrence numbers events identical down to the second within one
+	// source; see OccurrenceTracker.
+	Occurrence int
+	// PID is the process that logged the event, where the source records
+	// it. It pairs the PAM and sshd lines of one connection.
+	PID string
+}
+
+// Hash identifies the event independently of where it was read from, so the
+// same line seen twice (journal replay, import of an overlapping file) maps
+// to the same value. Timestamps are compared at second precision because
+// syslog files carry no fractions.
+func (e *SSHEvent) Hash() string {
+	key := e.key()
+	if e.Occurrence > 0 {
+		key += "|" + strconv.Itoa(e.Occurrence)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
 }
 
+func (e *SSHEvent) key() string {
+	service := e.Service
+	if service == "" {
+		service = ServiceSSH
+	}
+	return fmt.Sprintf("%d|%s|%s|%s|%s|%d|%s",
+		e.Timestamp.Unix(), service, e.EventType, e.Username, e.IP, e.Port, e.Method)
+}
+
+// OccurrenceTracker numbers events read in order from one source that are
+// identical down to the second. Web and SSO events carry no port, so two
+// 401s from one IP in the same second would otherwise share a hash and the
+// second would be dropped as a duplicate. Each gets its own hash, while
+// reading the same lines again numbers them the same way and still matches.
+// SSH and Samba events are left alone: their source port tells connections
+// apart.
+type OccurrenceTracker struct {
+	second int64
+	counts map[string]int
+}
+
+func NewOccurrenceTracker() *OccurrenceTracker {
+	return &OccurrenceTracker{counts: make(map[string]int)}
+}
+
+func (o *OccurrenceTracker) Mark(e *SSHEvent) {
+	if e.Port != 0 {
+		return
+	}
+	if second := e.Timestamp.Unix(); second != o.second {
+		o.second = second
+		clear(o.counts)
+	}
+	key := e.key()
+	e.Occurrence = o.counts[key]
+	o.counts[key]++
+}
+
+// keySuffix captures the key type and fingerprint sshd appends to publickey
+// logins, e.g. "ssh2: ED25519 SHA256:...".
+const keySuffix = `(?:\s+ssh2)?(?::\s+(\S+)\s+((?:SHA256|MD5):\S+))?`
+
 var (
 	successPattern = regexp.MustCompile(
-		`^(\w{3}\s+\d{1,2}\s+\d{2}:\d{2}:\d{2})\s+\S+\s+sshd\[\d+\]:\s+Accepted\s+(password|publickey)\s+for\s+(\S+)\s+from\s+(\S+)\s+port\s+(\d+)`,
+		`^(\w{3}\s+\d{1,2}\s+\d{2}:\d{2}:\d{2})\s+\S+\s+sshd\[\d+\]:\s+Accepted\s+(password|publickey|keyboard-interactive/pam|keyboard-interactive|gssapi-with-mic|gssapi-keyex)\s+for\s+(\S+)\s+from\s+(\S+)\s+port\s+(\d+)` + keySuffix,
 	)
 
 	failedPattern = regexp.MustCompile(
-		`^(\w{3}\s+\d{1,2}\s+\d{2}:\d{2}:\d{2})\s+\S+\s+sshd\[\d+\]:\s+Failed\s+(password|publickey)\s+for\s+(invalid user\s+)?(\S+)\s+from\s+(\S+)\s+port\s+(\d+)`,
+		`^(\w{3}\s+\d{1,2}\s+\d{2}:\d{2}:\d{2})\s+\S+\s+sshd\[\d+\]:\s+Failed\s+(password|publickey|keyboard-interactive/pam|keyboard-interactive|gssapi-with-mic|gssapi-keyex)\s+for\s+(invalid user\s+)?(\S+)\s+from\s+(\S+)\s+port\s+(\d+)`,
 	)
 
 	messageSuccessPattern = regexp.MustCompile(
-		`^Accepted\s+(password|publickey)\s+for\s+(\S+)\s+from\s+(\S+)\s+port\s+(\d+)`,
+		`^Accepted\s+(password|publickey|keyboard-interactive/pam|keyboard-interactive|gssapi-with-mic|gssapi-keyex)\s+for\s+(\S+)\s+from\s+(\S+)\s+port\s+(\d+)` + keySuffix,
 	)
 
 	messageFailedPattern = regexp.MustCompile(
-		`^Failed\s+(password|publickey)\s+for\s+(invalid user\s+)?(\S+)\s+from\s+(\S+)\s+port\s+(\d+)`,
+		`^Failed\s+(password|publickey|keyboard-interactive/pam|keyboard-interactive|gssapi-with-mic|gssapi-keyex)\s+for\s+(invalid user\s+)?(\S+)\s+from\s+(\S+)\s+port\s+(\d+)`,
 	)
 )
 
+var logFormats = map[string]func(line string) *SSHEvent{
+	"nginx":     ParseAccessLog,
+	"apache":    ParseAccessLog,
+	"keycloak":  ParseKeycloakLine,
+	"authelia":  ParseAutheliaLine,
+	"authentik": ParseAuthentikLine,
+}
+
+func IsLogFormat(format string) bool {
+	_, ok := logFormats[format]
+	return ok
+}
+
+func ParseLogLine(format, line string) *SSHEvent {
+	parse, ok := logFormats[format]
+	if !ok {
+		return nil
+	}
+	return parse(line)
+}
+
+func ServiceLabel(service string) string {
+	switch service {
+	case "", ServiceSSH:
+		return "SSH"
+	case ServiceWeb:
+		return "Web"
+	case ServiceSMB:
+		return "SMB"
+	case ServiceKeycloak:
+		return "Keycloak"
+	case ServiceAuthelia:
+		return "Authelia"
+	case ServiceAuthentik:
+		return "authentik"
+	default:
+		return service
+	}
+}
+
 func ParseLine(line string, year int) *SSHEvent {
 	if event := parseSuccess(line, year); event != nil {
 		return event
@@ -62,12 +176,15 @@ func parseSuccess(line string, year int) *SSHEvent {
 	port, _ := strconv.Atoi(matches[5])
 
 	return &SSHEvent{
-		Timestamp: timestamp,
-		EventType: EventSuccess,
-		Method:    matches[2],
-		Username:  matches[3],
-		IP:        matches[4],
-		Port:      port,
+		Timestamp:   timestamp,
+		EventType:   EventSuccess,
+		Service:     ServiceSSH,
+		Method:      matches[2],
+		Username:    matches[3],
+		IP:          matches[4],
+		Port:        port,
+		KeyType:     matches[6],
+		Fingerprint: matches[7],
 	}
 }
 
@@ -87,6 +204,7 @@ func parseFailure(line string, year int) *SSHEvent {
 	return &SSHEvent{
 		Timestamp:   timestamp,
 		EventType:   EventFailure,
+		Service:     ServiceSSH,
 		Method:      matches[2],
 		InvalidUser: matches[3] != "",
 		Username:    matches[4],
@@ -124,12 +242,15 @@ func parseMessageSuccess(message string, timestamp time.Time) *SSHEvent {
 	port, _ := strconv.Atoi(matches[4])
 
 	return &SSHEvent{
-		Timestamp: timestamp,
-		EventType: EventSuccess,
-		Method:    matches[1],
-		Username:  matches[2],
-		IP:        matches[3],
-		Port:      port,
+		Timestamp:   timestamp,
+		EventType:   EventSuccess,
+		Service:     ServiceSSH,
+		Method:      matches[1],
+		Username:    matches[2],
+		IP:          matches[3],
+		Port:        port,
+		KeyType:     matches[5],
+		Fingerprint: matches[6],
 	}
 }
 
@@ -144,6 +265,7 @@ func parseMessageFailure(message string, timestamp time.Time) *SSHEvent {
 	return &SSHEvent{
 		Timestamp:   timestamp,
 		EventType:   EventFailure,
+		Service:     ServiceSSH,
 		Method:      matches[1],
 		InvalidUser: matches[2] != "",
 		Username:    matches[3],
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"reflect"
 	"testing"
 	"time"
 )
@@ -155,6 +156,9 @@ func TestParseMessageSuccess(t *testing.T) {
 	if event.Method != "publickey" {
 		t.Errorf("expected method publickey, got %s", event.Method)
 	}
+	if event.KeyType != "ED25519" || event.Fingerprint != "SHA256:xxx" {
+		t.Errorf("expected ED25519 SHA256:xxx, got %s %s", event.KeyType, event.Fingerprint)
+	}
 	if !event.Timestamp.Equal(ts) {
 		t.Errorf("expected timestamp %v, got %v", ts, event.Timestamp)
 	}
@@ -236,3 +240,56 @@ func TestParseMessageNonSSH(t *testing.T) {
 		}
 	}
 }
+
+func TestEventHash(t *testing.T) {
+	journal := ParseMessage("Accepted publickey for admin from 192.168.1.100 port 54321 ssh2",
+		time.Date(2026, time.January, 15, 10, 30, 45, 123456000, time.Local))
+	syslog := ParseLine("Jan 15 10:30:45 server sshd[1234]: Accepted publickey for admin from 192.168.1.100 port 54321 ssh2", 2026)
+
+	if journal.Hash() != syslog.Hash() {
+		t.Errorf("expected journal and syslog copies of the same event to share a hash")
+	}
+
+	other := *syslog
+	other.Port = 54322
+	if other.Hash() == syslog.Hash() {
+		t.Errorf("expected different ports to produce different hashes")
+	}
+}
+
+func TestOccurrenceTracker(t *testing.T) {
+	lines := []string{
+		`203.0.113.7 - - [20/Jan/2026:14:32:15 +0000] "POST /wp-login.php HTTP/1.1" 200 381 "-" "curl/8.5.0"`,
+		`203.0.113.7 - - [20/Jan/2026:14:32:15 +0000] "POST /wp-login.php HTTP/1.1" 200 381 "-" "curl/8.5.0"`,
+		`203.0.113.7 - - [20/Jan/2026:14:32:16 +0000] "POST /wp-login.php HTTP/1.1" 200 381 "-" "curl/8.5.0"`,
+	}
+	read := func() []string {
+		occurrences := NewOccurrenceTracker()
+		var hashes []string
+		for _, line := range lines {
+			event := ParseAccessLog(line)
+			occurrences.Mark(event)
+			hashes = append(hashes, event.Hash())
+		}
+		return hashes
+	}
+
+	first := read()
+	if first[0] == first[1] {
+		t.Error("expected identical same-second access log lines to get different hashes")
+	}
+	if plain := ParseAccessLog(lines[2]); first[2] != plain.Hash() {
+		t.Error("expected the first line of a new second to keep the plain hash")
+	}
+	if again := read(); !reflect.DeepEqual(again, first) {
+		t.Errorf("expected a second read of the same lines to match, got %v and %v", first, again)
+	}
+
+	occurrences := NewOccurrenceTracker()
+	ssh := ParseLine("Jan 15 10:30:45 server sshd[1234]: Failed password for root from 192.168.1.100 port 54321 ssh2", 2026)
+	occurrences.Mark(ssh)
+	occurrences.Mark(ssh)
+	if ssh.Occurrence != 0 {
+		t.Errorf("expected SSH events with a port not to be numbered, got %d", ssh.Occurrence)
+	}
+}
@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const ServiceSMB = "smb"
+
+var sambaAuthPattern = regexp.MustCompile(
+	`Auth:\s+\[[^\]]*\]\s+user\s+\[([^\]]*)\]\\\[([^\]]*)\]\s+at\s+\[[^\]]*\]\s+with\s+\[([^\]]*)\]\s+status\s+\[(\w+)\].*?remote host\s+\[ipv[46]:(.+?):(\d+)\]`,
+)
+
+func ParseSambaMessage(message string, timestamp time.Time) *SSHEvent {
+	matches := sambaAuthPattern.FindStringSubmatch(message)
+	if matches == nil {
+		return nil
+	}
+
+	username := matches[2]
+	if username == "" {
+		return nil
+	}
+
+	eventType := EventFailure
+	if matches[4] == "NT_STATUS_OK" {
+		eventType = EventSuccess
+	}
+
+	port, _ := strconv.Atoi(matches[6])
+
+	return &SSHEvent{
+		Timestamp:   timestamp,
+		EventType:   eventType,
+		Service:     ServiceSMB,
+		Username:    username,
+		IP:          matches[5],
+		Port:        port,
+		Method:      matches[3],
+		InvalidUser: matches[4] == "NT_STATUS_NO_SUCH_USER",
+	}
+}
@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSambaFailure(t *testing.T) {
+	ts := time.Date(2026, time.January, 20, 14, 32, 15, 0, time.UTC)
+	message := `  Auth: [SMB2,(null)] user [WORKGROUP]\[bob] at [Tue, 20 Jan 2026 14:32:15.123456 UTC] with [NTLMv2] status [NT_STATUS_WRONG_PASSWORD] workstation [DESKTOP] remote host [ipv4:192.168.1.50:52314] mapped to [WORKGROUP]\[bob]. local host [ipv4:192.168.1.2:445]`
+	event := ParseSambaMessage(message, ts)
+
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if event.EventType != EventFailure {
+		t.Errorf("expected EventFailure, got %s", event.EventType)
+	}
+	if event.Service != ServiceSMB {
+		t.Errorf("expected service smb, got %s", event.Service)
+	}
+	if event.Username != "bob" {
+		t.Errorf("expected username bob, got %s", event.Username)
+	}
+	if event.IP != "192.168.1.50" {
+		t.Errorf("expected IP 192.168.1.50, got %s", event.IP)
+	}
+	if event.Port != 52314 {
+		t.Errorf("expected port 52314, got %d", event.Port)
+	}
+	if event.Method != "NTLMv2" {
+		t.Errorf("expected method NTLMv2, got %s", event.Method)
+	}
+	if event.InvalidUser {
+		t.Error("expected InvalidUser false")
+	}
+}
+
+func TestParseSambaSuccessIPv6(t *testing.T) {
+	ts := time.Date(2026, time.January, 20, 14, 32, 15, 0, time.UTC)
+	message := `Auth: [SMB2,(null)] user [NAS]\[alice] at [Tue, 20 Jan 2026 14:32:15.123456 UTC] with [NTLMv2] status [NT_STATUS_OK] workstation [LAPTOP] remote host [ipv6:fd00::12:50112] mapped to [NAS]\[alice]. local host [ipv6:fd00::1:445]`
+	event := ParseSambaMessage(message, ts)
+
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if event.EventType != EventSuccess {
+		t.Errorf("expected EventSuccess, got %s", event.EventType)
+	}
+	if event.IP != "fd00::12" {
+		t.Errorf("expected IP fd00::12, got %s", event.IP)
+	}
+}
+
+func TestParseSambaNoSuchUser(t *testing.T) {
+	message := `Auth: [SMB2,(null)] user [WORKGROUP]\[admin] at [Tue, 20 Jan 2026 14:32:15.123456 UTC] with [NTLMv2] status [NT_STATUS_NO_SUCH_USER] workstation [X] remote host [ipv4:203.0.113.9:41000] mapped to [WORKGROUP]\[admin]. local host [ipv4:192.168.1.2:445]`
+	event := ParseSambaMessage(message, time.Now())
+
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if !event.InvalidUser {
+		t.Error("expected InvalidUser true")
+	}
+}
+
+func TestParseSambaIgnored(t *testing.T) {
+	messages := []string{
+		`Auth: [SMB2,(null)] user []\[] at [Tue, 20 Jan 2026 14:32:15.123456 UTC] with [NTLMv2] status [NT_STATUS_OK] workstation [X] remote host [ipv4:192.168.1.50:52314] mapped to []\[]. local host [ipv4:192.168.1.2:445]`,
+		"[2026/01/20 14:32:15.123456,  2] ../../auth/auth_log.c:876(log_authentication_event_human_readable)",
+		"",
+	}
+
+	for _, msg := range messages {
+		if event := ParseSambaMessage(msg, time.Now()); event != nil {
+			t.Errorf("expected nil for message %q, got %+v", msg, event)
+		}
+	}
+}
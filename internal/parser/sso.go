@@ -0,0 +1,206 @@
+package parser
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	ServiceKeycloak  = "keycloak"
+	ServiceAuthelia  = "authelia"
+	ServiceAuthentik = "authentik"
+)
+
+var (
+	keycloakTimestampPattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}),\d+`)
+
+	keyValuePattern = regexp.MustCompile(`(\w+)=(?:"((?:[^"\\]|\\.)*)"|([^\s,]*))`)
+
+	autheliaMessagePattern = regexp.MustCompile(
+		`^(Successful|Unsuccessful)\s+(\S+)\s+authentication attempt\s+(?:made\s+)?by user\s+'([^']+)'`,
+	)
+)
+
+func ParseKeycloakLine(line string) *SSHEvent {
+	if !strings.Contains(line, "type=") {
+		return nil
+	}
+
+	fields := parseKeyValues(line)
+
+	var eventType EventType
+	switch fields["type"] {
+	case "LOGIN":
+		eventType = EventSuccess
+	case "LOGIN_ERROR":
+		eventType = EventFailure
+	default:
+		return nil
+	}
+
+	ip := fields["ipAddress"]
+	if ip == "" {
+		return nil
+	}
+
+	username := fields["username"]
+	if username == "" && fields["userId"] != "null" {
+		username = fields["userId"]
+	}
+
+	method := fields["auth_method"]
+	if method == "" {
+		method = "sso"
+	}
+
+	m := keycloakTimestampPattern.FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	timestamp, err := time.ParseInLocation("2006-01-02 15:04:05", m[1], time.Local)
+	if err != nil {
+		return nil
+	}
+
+	return &SSHEvent{
+		Timestamp:   timestamp,
+		EventType:   eventType,
+		Service:     ServiceKeycloak,
+		Username:    username,
+		IP:          ip,
+		Method:      method,
+		InvalidUser: fields["error"] == "user_not_found",
+	}
+}
+
+func ParseAutheliaLine(line string) *SSHEvent {
+	fields := make(map[string]string)
+	if strings.HasPrefix(strings.TrimSpace(line), "{") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil
+		}
+		for k, v := range entry {
+			if s, ok := v.(string); ok {
+				fields[k] = s
+			}
+		}
+	} else {
+		fields = parseKeyValues(line)
+	}
+
+	matches := autheliaMessagePattern.FindStringSubmatch(fields["msg"])
+	if matches == nil {
+		return nil
+	}
+
+	ip := fields["remote_ip"]
+	if ip == "" {
+		return nil
+	}
+
+	eventType := EventFailure
+	if matches[1] == "Successful" {
+		eventType = EventSuccess
+	}
+
+	timestamp, err := parseSSOTimestamp(fields["time"])
+	if err != nil {
+		return nil
+	}
+
+	return &SSHEvent{
+		Timestamp: timestamp,
+		EventType: eventType,
+		Service:   ServiceAuthelia,
+		Username:  matches[3],
+		IP:        ip,
+		Method:    strings.ToLower(matches[2]),
+	}
+}
+
+type authentikEntry struct {
+	Action    string `json:"action"`
+	ClientIP  string `json:"client_ip"`
+	Timestamp string `json:"timestamp"`
+	User      struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Context struct {
+		Username   string `json:"username"`
+		AuthMethod string `json:"auth_method"`
+	} `json:"context"`
+}
+
+func ParseAuthentikLine(line string) *SSHEvent {
+	if !strings.Contains(line, `"action"`) {
+		return nil
+	}
+
+	var entry authentikEntry
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		return nil
+	}
+
+	var eventType EventType
+	switch entry.Action {
+	case "login":
+		eventType = EventSuccess
+	case "login_failed":
+		eventType = EventFailure
+	default:
+		return nil
+	}
+
+	if entry.ClientIP == "" {
+		return nil
+	}
+
+	username := entry.Context.Username
+	if username == "" {
+		username = entry.User.Username
+	}
+
+	method := entry.Context.AuthMethod
+	if method == "" {
+		method = "sso"
+	}
+
+	timestamp, err := parseSSOTimestamp(entry.Timestamp)
+	if err != nil {
+		return nil
+	}
+
+	return &SSHEvent{
+		Timestamp: timestamp,
+		EventType: eventType,
+		Service:   ServiceAuthentik,
+		Username:  username,
+		IP:        entry.ClientIP,
+		Method:    method,
+	}
+}
+
+// parseSSOTimestamp parses an RFC 3339 timestamp, or one without a zone as
+// local time, and returns it in the local zone like the syslog timestamps.
+// The store compares timestamps as text, so they must all share one zone.
+func parseSSOTimestamp(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.Local(), nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04:05.999999", value, time.Local)
+}
+
+func parseKeyValues(line string) map[string]string {
+	fields := make(map[string]string)
+	for _, m := range keyValuePattern.FindAllStringSubmatch(line, -1) {
+		if m[2] != "" {
+			fields[m[1]] = strings.ReplaceAll(m[2], `\"`, `"`)
+		} else {
+			fields[m[1]] = m[3]
+		}
+	}
+	return fields
+}
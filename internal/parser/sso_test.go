@@ -0,0 +1,182 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseKeycloakLoginError(t *testing.T) {
+	line := `2026-01-20 14:32:15,123 WARN  [org.keycloak.events] (executor-thread-1) type="LOGIN_ERROR", realmId="a1b2", realmName="master", clientId="account-console", userId="null", ipAddress="203.0.113.7", error="user_not_found", auth_method="openid-connect", username="bob"`
+	event := ParseKeycloakLine(line)
+
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if event.EventType != EventFailure {
+		t.Errorf("expected EventFailure, got %s", event.EventType)
+	}
+	if event.Service != ServiceKeycloak {
+		t.Errorf("expected service keycloak, got %s", event.Service)
+	}
+	if event.Username != "bob" {
+		t.Errorf("expected username bob, got %s", event.Username)
+	}
+	if event.IP != "203.0.113.7" {
+		t.Errorf("expected IP 203.0.113.7, got %s", event.IP)
+	}
+	if event.Method != "openid-connect" {
+		t.Errorf("expected method openid-connect, got %s", event.Method)
+	}
+	if !event.InvalidUser {
+		t.Error("expected InvalidUser true")
+	}
+
+	expected := time.Date(2026, time.January, 20, 14, 32, 15, 0, time.Local)
+	if !event.Timestamp.Equal(expected) {
+		t.Errorf("expected timestamp %v, got %v", expected, event.Timestamp)
+	}
+}
+
+func TestParseKeycloakLoginUnquoted(t *testing.T) {
+	line := `2026-01-20 14:32:15,123 INFO  [org.keycloak.events] (executor-thread-1) type=LOGIN, realmId=master, clientId=grafana, userId=5f0c, ipAddress=10.0.0.5, auth_method=openid-connect, username=alice`
+	event := ParseKeycloakLine(line)
+
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if event.EventType != EventSuccess {
+		t.Errorf("expected EventSuccess, got %s", event.EventType)
+	}
+	if event.Username != "alice" {
+		t.Errorf("expected username alice, got %s", event.Username)
+	}
+	if event.IP != "10.0.0.5" {
+		t.Errorf("expected IP 10.0.0.5, got %s", event.IP)
+	}
+}
+
+func TestParseAutheliaText(t *testing.T) {
+	line := `time="2026-01-20T14:32:15Z" level=error msg="Unsuccessful 1FA authentication attempt by user 'bob'" method=POST path=/api/firstfactor remote_ip=203.0.113.7`
+	event := ParseAutheliaLine(line)
+
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if event.EventType != EventFailure {
+		t.Errorf("expected EventFailure, got %s", event.EventType)
+	}
+	if event.Service != ServiceAuthelia {
+		t.Errorf("expected service authelia, got %s", event.Service)
+	}
+	if event.Username != "bob" {
+		t.Errorf("expected username bob, got %s", event.Username)
+	}
+	if event.Method != "1fa" {
+		t.Errorf("expected method 1fa, got %s", event.Method)
+	}
+
+	expected := time.Date(2026, time.January, 20, 14, 32, 15, 0, time.UTC)
+	if !event.Timestamp.Equal(expected) {
+		t.Errorf("expected timestamp %v, got %v", expected, event.Timestamp)
+	}
+}
+
+func TestParseAutheliaJSON(t *testing.T) {
+	line := `{"level":"debug","method":"POST","msg":"Successful 1FA authentication attempt made by user 'alice'","path":"/api/firstfactor","remote_ip":"10.0.0.5","time":"2026-01-20T14:32:15Z"}`
+	event := ParseAutheliaLine(line)
+
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if event.EventType != EventSuccess {
+		t.Errorf("expected EventSuccess, got %s", event.EventType)
+	}
+	if event.Username != "alice" {
+		t.Errorf("expected username alice, got %s", event.Username)
+	}
+	if event.IP != "10.0.0.5" {
+		t.Errorf("expected IP 10.0.0.5, got %s", event.IP)
+	}
+}
+
+func TestParseAuthentik(t *testing.T) {
+	line := `{"action": "login_failed", "client_ip": "203.0.113.7", "context": {"username": "bob"}, "event": "Created Event", "level": "info", "logger": "authentik.events.models", "timestamp": "2026-01-20T14:32:15.123456"}`
+	event := ParseAuthentikLine(line)
+
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if event.EventType != EventFailure {
+		t.Errorf("expected EventFailure, got %s", event.EventType)
+	}
+	if event.Service != ServiceAuthentik {
+		t.Errorf("expected service authentik, got %s", event.Service)
+	}
+	if event.Username != "bob" {
+		t.Errorf("expected username bob, got %s", event.Username)
+	}
+	if event.IP != "203.0.113.7" {
+		t.Errorf("expected IP 203.0.113.7, got %s", event.IP)
+	}
+
+	expected := time.Date(2026, time.January, 20, 14, 32, 15, 123456000, time.Local)
+	if !event.Timestamp.Equal(expected) {
+		t.Errorf("expected timestamp %v, got %v", expected, event.Timestamp)
+	}
+}
+
+func TestParseSSOTimestampZone(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string) *SSHEvent
+		line  string
+	}{
+		{"keycloak", ParseKeycloakLine, `2026-01-20 13:32:15,123 WARN  [org.keycloak.events] type=LOGIN_ERROR, ipAddress=203.0.113.7, username=bob`},
+		{"authelia", ParseAutheliaLine, `time="2026-01-20T14:32:15+01:00" level=error msg="Unsuccessful 1FA authentication attempt by user 'bob'" remote_ip=203.0.113.7`},
+		{"authentik", ParseAuthentikLine, `{"action": "login_failed", "client_ip": "203.0.113.7", "context": {"username": "bob"}, "timestamp": "2026-01-20T14:32:15+01:00"}`},
+	}
+
+	// Keycloak writes local time, the others carry their offset.
+	keycloak := time.Date(2026, time.January, 20, 13, 32, 15, 0, time.Local)
+	utc := time.Date(2026, time.January, 20, 13, 32, 15, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := tt.parse(tt.line)
+			if event == nil {
+				t.Fatal("expected event, got nil")
+			}
+			expected := utc
+			if tt.name == "keycloak" {
+				expected = keycloak
+			}
+			if !event.Timestamp.Equal(expected) || event.Timestamp.Location() != time.Local {
+				t.Errorf("expected %v in the local zone, got %v", expected, event.Timestamp)
+			}
+		})
+	}
+}
+
+func TestParseSSOInvalidTimestamp(t *testing.T) {
+	// Without a timestamp the event hash would change on every replay.
+	if event := ParseKeycloakLine(`WARN  [org.keycloak.events] type=LOGIN_ERROR, ipAddress=203.0.113.7, username=bob`); event != nil {
+		t.Errorf("expected nil for keycloak line without timestamp, got %+v", event)
+	}
+	if event := ParseAutheliaLine(`time="yesterday" level=error msg="Unsuccessful 1FA authentication attempt by user 'bob'" remote_ip=203.0.113.7`); event != nil {
+		t.Errorf("expected nil for authelia line with invalid timestamp, got %+v", event)
+	}
+	if event := ParseAuthentikLine(`{"action": "login_failed", "client_ip": "203.0.113.7", "context": {"username": "bob"}}`); event != nil {
+		t.Errorf("expected nil for authentik line without timestamp, got %+v", event)
+	}
+}
+
+func TestParseSSONonAuth(t *testing.T) {
+	if event := ParseKeycloakLine(`2026-01-20 14:32:15,123 INFO  [org.keycloak.events] type=CODE_TO_TOKEN, ipAddress=10.0.0.5`); event != nil {
+		t.Errorf("expected nil for keycloak CODE_TO_TOKEN, got %+v", event)
+	}
+	if event := ParseAutheliaLine(`time="2026-01-20T14:32:15Z" level=info msg="Authelia is listening"`); event != nil {
+		t.Errorf("expected nil for authelia startup line, got %+v", event)
+	}
+	if event := ParseAuthentikLine(`{"action": "model_updated", "client_ip": "10.0.0.5"}`); event != nil {
+		t.Errorf("expected nil for authentik model_updated, got %+v", event)
+	}
+}
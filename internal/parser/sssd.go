@@ -0,0 +1,34 @@
+package parser
+
+import (
+	"regexp"
+	"time"
+)
+
+const AuthSourceSSSD = "sssd"
+
+var pamSSSPattern = regexp.MustCompile(
+	`^pam_sss\((\S+?):auth\):\s+authentication\s+(success|failure);.*?\brhost=(\S+)\s+user=(\S+)`,
+)
+
+func ParseSSSDMessage(message string, timestamp time.Time) *SSHEvent {
+	matches := pamSSSPattern.FindStringSubmatch(message)
+	if matches == nil {
+		return nil
+	}
+
+	eventType := EventFailure
+	if matches[2] == "success" {
+		eventType = EventSuccess
+	}
+
+	return &SSHEvent{
+		Timestamp:  timestamp,
+		EventType:  eventType,
+		Service:    ServiceSSH,
+		Username:   matches[4],
+		IP:         matches[3],
+		Method:     "pam_sss",
+		AuthSource: AuthSourceSSSD,
+	}
+}
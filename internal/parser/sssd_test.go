@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSSSDSuccess(t *testing.T) {
+	ts := time.Date(2026, time.January, 20, 14, 32, 15, 0, time.UTC)
+	message := "pam_sss(sshd:auth): authentication success; logname= uid=0 euid=0 tty=ssh ruser= rhost=203.0.113.7 user=bob@corp.example"
+	event := ParseSSSDMessage(message, ts)
+
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if event.EventType != EventSuccess {
+		t.Errorf("expected EventSuccess, got %s", event.EventType)
+	}
+	if event.Username != "bob@corp.example" {
+		t.Errorf("expected username bob@corp.example, got %s", event.Username)
+	}
+	if event.IP != "203.0.113.7" {
+		t.Errorf("expected IP 203.0.113.7, got %s", event.IP)
+	}
+	if event.AuthSource != AuthSourceSSSD {
+		t.Errorf("expected auth source sssd, got %s", event.AuthSource)
+	}
+}
+
+func TestParseSSSDFailure(t *testing.T) {
+	message := "pam_sss(sshd:auth): authentication failure; logname= uid=0 euid=0 tty=ssh ruser= rhost=198.51.100.4 user=alice"
+	event := ParseSSSDMessage(message, time.Now())
+
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if event.EventType != EventFailure {
+		t.Errorf("expected EventFailure, got %s", event.EventType)
+	}
+	if event.Username != "alice" {
+		t.Errorf("expected username alice, got %s", event.Username)
+	}
+}
+
+func TestParseSSSDIgnored(t *testing.T) {
+	messages := []string{
+		"pam_sss(sshd:auth): received for user alice: 7 (Authentication failure)",
+		"pam_sss(login:auth): authentication failure; logname= uid=0 euid=0 tty=tty1 ruser= rhost= user=alice",
+		"pam_unix(sshd:auth): authentication failure; logname= uid=0 euid=0 tty=ssh ruser= rhost=198.51.100.4  user=root",
+		"",
+	}
+	for _, msg := range messages {
+		if event := ParseSSSDMessage(msg, time.Now()); event != nil {
+			t.Errorf("expected nil for message %q, got %+v", msg, event)
+		}
+	}
+}
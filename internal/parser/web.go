@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MethodHTTPAuth   = "http"
+	MethodAdminLogin = "admin-login"
+)
+
+var (
+	accessLogPattern = regexp.MustCompile(
+		`^(\S+)\s+\S+\s+(\S+)\s+\[([^\]]+)\]\s+"(\S+)\s+(\S+)[^"]*"\s+(\d{3})\s`,
+	)
+
+	adminLoginPaths = []string{
+		"/wp-login.php",
+		"/xmlrpc.php",
+		"/administrator/index.php",
+		"/user/login",
+		"/admin/login",
+		"/phpmyadmin/index.php",
+		"/login.action",
+	}
+)
+
+func ParseAccessLog(line string) *SSHEvent {
+	matches := accessLogPattern.FindStringSubmatch(line)
+	if matches == nil {
+		return nil
+	}
+
+	status, _ := strconv.Atoi(matches[6])
+	path := matches[5]
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+
+	var method string
+	switch {
+	case matches[4] == "POST" && isAdminLoginPath(path) && (status < 300 || status == 401 || status == 403):
+		method = MethodAdminLogin
+	case status == 401 || status == 403:
+		method = MethodHTTPAuth
+	default:
+		return nil
+	}
+
+	// The store compares timestamps as text, so they all have to be in the
+	// local zone like the syslog ones, whatever offset the log was written in.
+	timestamp, err := time.Parse("02/Jan/2006:15:04:05 -0700", matches[3])
+	if err != nil {
+		return nil
+	}
+	timestamp = timestamp.Local()
+
+	username := matches[2]
+	if username == "-" {
+		username = ""
+	}
+
+	return &SSHEvent{
+		Timestamp: timestamp,
+		EventType: EventFailure,
+		Service:   ServiceWeb,
+		Username:  username,
+		IP:        matches[1],
+		Method:    method,
+	}
+}
+
+func isAdminLoginPath(path string) bool {
+	path = strings.ToLower(strings.TrimSuffix(path, "/"))
+	for _, p := range adminLoginPaths {
+		if path == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAccessLogUnauthorized(t *testing.T) {
+	line := `203.0.113.7 - admin [20/Jan/2026:14:32:15 +0000] "GET /private/ HTTP/1.1" 401 381 "-" "curl/8.5.0"`
+	event := ParseAccessLog(line)
+
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if event.EventType != EventFailure {
+		t.Errorf("expected EventFailure, got %s", event.EventType)
+	}
+	if event.Service != ServiceWeb {
+		t.Errorf("expected service web, got %s", event.Service)
+	}
+	if event.Method != MethodHTTPAuth {
+		t.Errorf("expected method %s, got %s", MethodHTTPAuth, event.Method)
+	}
+	if event.Username != "admin" {
+		t.Errorf("expected username admin, got %s", event.Username)
+	}
+	if event.IP != "203.0.113.7" {
+		t.Errorf("expected IP 203.0.113.7, got %s", event.IP)
+	}
+
+	expected := time.Date(2026, time.January, 20, 14, 32, 15, 0, time.UTC)
+	if !event.Timestamp.Equal(expected) {
+		t.Errorf("expected timestamp %v, got %v", expected, event.Timestamp)
+	}
+}
+
+func TestParseAccessLogForbiddenNoUser(t *testing.T) {
+	line := `198.51.100.4 - - [20/Jan/2026:14:32:15 +0100] "GET /.env HTTP/1.1" 403 153 "-" "Mozilla/5.0"`
+	event := ParseAccessLog(line)
+
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if event.Username != "" {
+		t.Errorf("expected empty username, got %s", event.Username)
+	}
+	if event.Method != MethodHTTPAuth {
+		t.Errorf("expected method %s, got %s", MethodHTTPAuth, event.Method)
+	}
+
+	expected := time.Date(2026, time.January, 20, 13, 32, 15, 0, time.UTC)
+	if !event.Timestamp.Equal(expected) || event.Timestamp.Location() != time.Local {
+		t.Errorf("expected timestamp %v in the local zone, got %v", expected, event.Timestamp)
+	}
+}
+
+func TestParseAccessLogAdminLogin(t *testing.T) {
+	line := `192.0.2.10 - - [20/Jan/2026:14:32:15 +0000] "POST /wp-login.php HTTP/1.1" 200 4523 "-" "Mozilla/5.0"`
+	event := ParseAccessLog(line)
+
+	if event == nil {
+		t.Fatal("expected event, got nil")
+	}
+	if event.Method != MethodAdminLogin {
+		t.Errorf("expected method %s, got %s", MethodAdminLogin, event.Method)
+	}
+}
+
+func TestParseAccessLogIgnored(t *testing.T) {
+	lines := []string{
+		`192.0.2.10 - - [20/Jan/2026:14:32:15 +0000] "GET /wp-login.php HTTP/1.1" 200 4523 "-" "Mozilla/5.0"`,
+		`192.0.2.10 - - [20/Jan/2026:14:32:15 +0000] "POST /wp-login.php HTTP/1.1" 302 0 "-" "Mozilla/5.0"`,
+		`192.0.2.10 - - [20/Jan/2026:14:32:15 +0000] "GET / HTTP/1.1" 200 612 "-" "Mozilla/5.0"`,
+		`192.0.2.10 - - [20/Jan/2026:14:32:15 +0000] "GET /missing HTTP/1.1" 404 0 "-" "Mozilla/5.0"`,
+		"random garbage",
+		"",
+	}
+
+	for _, line := range lines {
+		if event := ParseAccessLog(line); event != nil {
+			t.Errorf("expected nil for line %q, got %+v", line, event)
+		}
+	}
+}
+
+func TestParseLogLineUnknownFormat(t *testing.T) {
+	line := `203.0.113.7 - admin [20/Jan/2026:14:32:15 +0000] "GET /private/ HTTP/1.1" 401 381 "-" "curl/8.5.0"`
+	if event := ParseLogLine("unknown", line); event != nil {
+		t.Errorf("expected nil for unknown format, got %+v", event)
+	}
+	if event := ParseLogLine("nginx", line); event == nil {
+		t.Error("expected event for nginx format, got nil")
+	}
+}
@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"regexp"
+	"time"
+)
+
+var syslogDatePattern = regexp.MustCompile(`^(\w{3})\s+(\d{1,2})\s`)
+
+// SyslogDate extracts month and day from a traditional syslog line, which
+// carries no year.
+func SyslogDate(line string) (time.Month, int, bool) {
+	matches := syslogDatePattern.FindStringSubmatch(line)
+	if matches == nil {
+		return 0, 0, false
+	}
+	t, err := time.Parse("Jan 2", matches[1]+" "+matches[2])
+	if err != nil {
+		return 0, 0, false
+	}
+	return t.Month(), t.Day(), true
+}
+
+// InferYear picks the year for a month/day read at now: the current year,
+// unless that would put the date in the future (Dec 31 read on Jan 1), in
+// which case the previous one.
+func InferYear(month time.Month, day int, now time.Time) int {
+	candidate := time.Date(now.Year(), month, day, 0, 0, 0, 0, now.Location())
+	if candidate.After(now.AddDate(0, 0, 1)) {
+		return now.Year() - 1
+	}
+	return now.Year()
+}
+
+// YearTracker assigns years to syslog lines fed in file order, starting from
+// the year of the first line, which comes from the file's modification time
+// or --year. Lines only cross into a new year by stepping back more than six
+// months (December to January). Any step forward stays in the same year, even
+// a long one across a gap in the log (February to September); the only
+// exception is a December line right after a January one, which is a
+// straggler from before the rollover and keeps the previous year. A smaller
+// step back is kept in the current year without moving the tracker.
+type YearTracker struct {
+	year  int
+	month time.Month
+}
+
+func NewYearTracker(startYear int) *YearTracker {
+	return &YearTracker{year: startYear}
+}
+
+func (y *YearTracker) Year(month time.Month) int {
+	if y.month == 0 {
+		y.month = month
+		return y.year
+	}
+
+	switch {
+	case month+6 < y.month:
+		y.year++
+		y.month = month
+	case month == time.December && y.month == time.January:
+		return y.year - 1
+	case month > y.month:
+		y.month = month
+	}
+	return y.year
+}
+
+func (y *YearTracker) Current() int {
+	return y.year
+}
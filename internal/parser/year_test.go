@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSyslogDate(t *testing.T) {
+	month, day, ok := SyslogDate("Dec  31 23:59:58 server sshd[1234]: Accepted publickey for admin from 192.168.1.100 port 54321 ssh2")
+	if !ok || month != time.December || day != 31 {
+		t.Errorf("expected December 31, got %s %d (ok=%v)", month, day, ok)
+	}
+
+	if _, _, ok := SyslogDate("2026-01-01T00:00:01+00:00 server sshd[1]: hello"); ok {
+		t.Error("expected no date for RFC3339 line")
+	}
+}
+
+func TestInferYear(t *testing.T) {
+	newYear := time.Date(2026, time.January, 1, 0, 5, 0, 0, time.UTC)
+
+	if year := InferYear(time.December, 31, newYear); year != 2025 {
+		t.Errorf("expected 2025 for Dec 31 read on Jan 1, got %d", year)
+	}
+	if year := InferYear(time.January, 1, newYear); year != 2026 {
+		t.Errorf("expected 2026 for Jan 1 read on Jan 1, got %d", year)
+	}
+	if year := InferYear(time.January, 2, newYear); year != 2026 {
+		t.Errorf("expected 2026 within a day of clock slack, got %d", year)
+	}
+}
+
+func TestYearTracker(t *testing.T) {
+	tracker := NewYearTracker(2024)
+
+	months := []time.Month{
+		time.November, time.December, time.December,
+		time.January,  // rollover to 2025
+		time.December, // late straggler, still 2024
+		time.January, time.June, time.December,
+		time.February, // rollover to 2026
+	}
+	expected := []int{2024, 2024, 2024, 2025, 2024, 2025, 2025, 2025, 2026}
+
+	for i, month := range months {
+		if year := tracker.Year(month); year != expected[i] {
+			t.Errorf("line %d (%s): expected %d, got %d", i, month, expected[i], year)
+		}
+	}
+	if tracker.Current() != 2026 {
+		t.Errorf("expected current year 2026, got %d", tracker.Current())
+	}
+}
+
+func TestYearTrackerForwardGap(t *testing.T) {
+	tests := []struct {
+		name     string
+		months   []time.Month
+		expected []int
+	}{
+		{"rotation gap", []time.Month{time.February, time.September, time.October}, []int{2025, 2025, 2025}},
+		{"gap after rollover", []time.Month{time.December, time.January, time.August}, []int{2024, 2025, 2025}},
+		{"straggler", []time.Month{time.December, time.January, time.December, time.January}, []int{2024, 2025, 2024, 2025}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewYearTracker(tt.expected[0])
+			for i, month := range tt.months {
+				if year := tracker.Year(month); year != tt.expected[i] {
+					t.Errorf("line %d (%s): expected %d, got %d", i, month, tt.expected[i], year)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GenerateCampaigns lists the attack campaigns active in the last days,
+// most recently started first.
+func (g *Generator) GenerateCampaigns(days int, now time.Time) (string, error) {
+	campaigns, err := g.storage.GetCampaigns(now.AddDate(0, 0, -days))
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("Attack Campaigns (last %d days)\n", days))
+	g.writeServerText(&buf)
+
+	if len(campaigns) == 0 {
+		buf.WriteString("No campaigns detected.\n")
+		return buf.String(), nil
+	}
+
+	var attempts int
+	for _, c := range campaigns {
+		attempts += c.Attempts
+	}
+	buf.WriteString(fmt.Sprintf("Campaigns: %d, attempts: %d\n", len(campaigns), attempts))
+
+	for _, c := range campaigns {
+		network := fmt.Sprintf("AS%d", c.ASN)
+		if c.ASOrg != "" {
+			network += " " + c.ASOrg
+		}
+		buf.WriteString(fmt.Sprintf("\n%s  %s – %s  %s\n", c.ID, c.FirstSeen.Format("2006-01-02 15:04"), c.LastSeen.Format("2006-01-02 15:04"), network))
+		buf.WriteString(fmt.Sprintf("  %d attempts from %d IPs against %s\n", c.Attempts, len(c.IPs), strings.Join(c.Usernames, ", ")))
+		buf.WriteString(fmt.Sprintf("  IPs: %s\n", strings.Join(c.IPs, ", ")))
+	}
+	return buf.String(), nil
+}
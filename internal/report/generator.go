@@ -3,52 +3,165 @@ package report
 import (
 	"bytes"
 	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
 	"time"
 
+	"github.com/oxisoft/oxiwatch/internal/advisory"
+	"github.com/oxisoft/oxiwatch/internal/audit"
+	"github.com/oxisoft/oxiwatch/internal/config"
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+	"github.com/oxisoft/oxiwatch/internal/host"
+	"github.com/oxisoft/oxiwatch/internal/parser"
 	"github.com/oxisoft/oxiwatch/internal/storage"
 	"github.com/oxisoft/oxiwatch/internal/version"
 )
 
 type Generator struct {
-	storage        *storage.Storage
+	storage        storage.Store
 	serverName     string
+	metadata       config.ServerMetadata
 	currentVersion string
+	template       *template.Template
+	uptime         func() (time.Duration, error)
 }
 
-func NewGenerator(storage *storage.Storage, serverName, currentVersion string) *Generator {
+func NewGenerator(storage storage.Store, serverName string, metadata config.ServerMetadata, currentVersion string) *Generator {
 	return &Generator{
 		storage:        storage,
 		serverName:     serverName,
+		metadata:       metadata,
 		currentVersion: currentVersion,
+		uptime:         host.Uptime,
 	}
 }
 
-func (g *Generator) GenerateDailyReport(date time.Time) (string, error) {
+// SetUptime replaces the host uptime shown in the daily report, for
+// simulations that must not show the real host.
+func (g *Generator) SetUptime(uptime func() (time.Duration, error)) {
+	g.uptime = uptime
+}
+
+// DailyData is everything the daily report shows. Custom templates are
+// executed with it, see LoadTemplate.
+type DailyData struct {
+	Date         time.Time
+	Server       string
+	Labels       []string
+	RunbookURL   string
+	Uptime       string
+	SuccessCount int
+	Failed       storage.Stats
+	Aggregated   int
+	TopUsers     []storage.UsernameCount
+	TopIPs       []storage.IPCount
+	Services     []ServiceSection
+}
+
+// ServiceSection covers one of the services other than SSH.
+type ServiceSection struct {
+	Name   string
+	Stats  storage.ServiceStats
+	TopIPs []storage.IPCount
+}
+
+// LoadTemplate replaces the built-in daily report layout with a text/template
+// file executed with DailyData.
+func (g *Generator) LoadTemplate(path string) error {
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+	if err != nil {
+		return err
+	}
+	g.template = tmpl
+	return nil
+}
+
+var templateFuncs = template.FuncMap{
+	"number":   formatNumber,
+	"flag":     geoip.Flag,
+	"location": ipLocation,
+	"service":  parser.ServiceLabel,
+	"add":      func(a, b int) int { return a + b },
+}
+
+// DailyData collects the numbers for the calendar day of date, in date's
+// location.
+func (g *Generator) DailyData(date time.Time) (*DailyData, error) {
 	startOfDay := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
-	endOfDay := startOfDay.Add(24 * time.Hour)
-	_ = endOfDay
+	endOfDay := startOfDay.AddDate(0, 0, 1)
+
+	data := &DailyData{
+		Date:       startOfDay,
+		Server:     g.serverName,
+		Labels:     g.metadata.Labels(),
+		RunbookURL: g.metadata.RunbookURL,
+	}
+	if uptime, err := g.uptime(); err == nil {
+		data.Uptime = host.FormatUptime(uptime)
+	}
 
-	stats, err := g.storage.GetFailedStats(startOfDay)
+	stats, err := g.storage.GetFailedStats(startOfDay, endOfDay, parser.ServiceSSH)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	data.Failed = *stats
 
-	topUsers, err := g.storage.GetTopUsernames(startOfDay, 10)
+	data.TopUsers, err = g.storage.GetTopUsernames(startOfDay, endOfDay, 10, parser.ServiceSSH)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	topIPs, err := g.storage.GetTopIPs(startOfDay, 10)
+	data.TopIPs, err = g.storage.GetTopIPs(startOfDay, endOfDay, 10, parser.ServiceSSH)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	successCount, err := g.storage.GetSuccessCount(startOfDay)
+	data.SuccessCount, err = g.storage.GetSuccessCount(startOfDay, endOfDay, parser.ServiceSSH)
+	if err != nil {
+		return nil, err
+	}
+
+	data.Aggregated, err = g.storage.GetAggregatedFailures(startOfDay, endOfDay, parser.ServiceSSH)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceStats, err := g.storage.GetServiceStats(startOfDay, endOfDay)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ss := range serviceStats {
+		if ss.Service == parser.ServiceSSH {
+			continue
+		}
+		ips, err := g.storage.GetTopIPs(startOfDay, endOfDay, 5, ss.Service)
+		if err != nil {
+			return nil, err
+		}
+		data.Services = append(data.Services, ServiceSection{Name: parser.ServiceLabel(ss.Service), Stats: ss, TopIPs: ips})
+	}
+
+	return data, nil
+}
+
+func (g *Generator) GenerateDailyReport(date time.Time) (string, error) {
+	data, err := g.DailyData(date)
 	if err != nil {
 		return "", err
 	}
 
-	reportText := g.formatReport(date, stats, topUsers, topIPs, successCount)
+	var reportText string
+	if g.template != nil {
+		var buf bytes.Buffer
+		if err := g.template.Execute(&buf, data); err != nil {
+			return "", fmt.Errorf("failed to render report template: %w", err)
+		}
+		reportText = buf.String()
+	} else {
+		reportText = g.formatReport(data)
+	}
 
 	if g.currentVersion != "" {
 		reportText += g.checkVersionUpdate()
@@ -57,71 +170,247 @@ func (g *Generator) GenerateDailyReport(date time.Time) (string, error) {
 	return reportText, nil
 }
 
-func (g *Generator) formatReport(date time.Time, stats *storage.Stats, topUsers []storage.UsernameCount, topIPs []storage.IPCount, successCount int) string {
+// ComposeDailyReport builds the report the daemon sends the day after date,
+// including the weekly audit and the advisory sections when they are due.
+func (g *Generator) ComposeDailyReport(date time.Time, cfg *config.Config) (string, error) {
+	reportText, err := g.GenerateDailyReport(date)
+	if err != nil {
+		return "", err
+	}
+
+	if cfg.SSHDAuditEnabled {
+		weekday, _ := cfg.AuditWeekday()
+		if date.AddDate(0, 0, 1).Weekday() == weekday {
+			reportText += g.GenerateSSHDAuditSection(cfg.SSHDConfigPath)
+		}
+	}
+
+	if cfg.AdvisoryCheckEnabled {
+		reportText += g.GenerateAdvisorySection()
+	}
+
+	return reportText, nil
+}
+
+func (g *Generator) formatReport(data *DailyData) string {
 	var buf bytes.Buffer
 
 	buf.WriteString(fmt.Sprintf("📊 *Daily SSH Report*\n"))
-	buf.WriteString(fmt.Sprintf("🖥️ Server: %s\n", escapeMarkdown(g.serverName)))
-	buf.WriteString(fmt.Sprintf("📅 %s\n\n", date.Format("2006\\-01\\-02")))
+	buf.WriteString(fmt.Sprintf("🖥️ Server: %s\n", escapeMarkdown(data.Server)))
+	if len(data.Labels) > 0 {
+		buf.WriteString(fmt.Sprintf("🏷️ %s\n", escapeMarkdown(strings.Join(data.Labels, " | "))))
+	}
+	if data.RunbookURL != "" {
+		buf.WriteString(fmt.Sprintf("📘 Runbook: %s\n", escapeMarkdown(data.RunbookURL)))
+	}
+	if data.Uptime != "" {
+		buf.WriteString(fmt.Sprintf("⏱️ Uptime: %s\n", data.Uptime))
+	}
+	buf.WriteString(fmt.Sprintf("📅 %s\n\n", data.Date.Format("2006\\-01\\-02")))
 
 	buf.WriteString("📈 *Summary*\n")
-	buf.WriteString(fmt.Sprintf("• Successful logins: %s\n", formatNumber(successCount)))
-	buf.WriteString(fmt.Sprintf("• Failed attempts: %s\n", formatNumber(stats.TotalAttempts)))
-	buf.WriteString(fmt.Sprintf("• Unique IPs: %s\n", formatNumber(stats.UniqueIPs)))
-	buf.WriteString(fmt.Sprintf("• Unique usernames: %s\n\n", formatNumber(stats.UniqueUsernames)))
+	buf.WriteString(fmt.Sprintf("• Successful logins: %s\n", formatNumber(data.SuccessCount)))
+	buf.WriteString(fmt.Sprintf("• Failed attempts: %s\n", formatNumber(data.Failed.TotalAttempts)))
+	buf.WriteString(fmt.Sprintf("• Unique IPs: %s\n", formatNumber(data.Failed.UniqueIPs)))
+	buf.WriteString(fmt.Sprintf("• Unique usernames: %s\n", formatNumber(data.Failed.UniqueUsernames)))
+	if data.Aggregated > 0 {
+		buf.WriteString(fmt.Sprintf("• Failed attempts counted without details: %s\n", formatNumber(data.Aggregated)))
+	}
+	buf.WriteString("\n")
 
-	if len(topUsers) > 0 {
+	if len(data.TopUsers) > 0 {
 		buf.WriteString("👤 *Top 10 Usernames*\n")
-		for i, u := range topUsers {
+		for i, u := range data.TopUsers {
 			buf.WriteString(fmt.Sprintf("%d\\. %s \\- %s\n", i+1, escapeMarkdown(u.Username), formatNumber(u.Count)))
 		}
 		buf.WriteString("\n")
 	}
 
-	if len(topIPs) > 0 {
+	if len(data.TopIPs) > 0 {
 		buf.WriteString("🌐 *Top 10 IPs*\n")
+		writeIPList(&buf, data.TopIPs)
+	}
+
+	for _, section := range data.Services {
+		buf.WriteString(fmt.Sprintf("\n🧩 *%s*\n", escapeMarkdown(section.Name)))
+		buf.WriteString(fmt.Sprintf("• Successful logins: %s\n", formatNumber(section.Stats.SuccessCount)))
+		buf.WriteString(fmt.Sprintf("• Failed attempts: %s\n", formatNumber(section.Stats.FailedCount)))
+		buf.WriteString(fmt.Sprintf("• Unique IPs: %s\n", formatNumber(section.Stats.UniqueIPs)))
+		writeIPList(&buf, section.TopIPs)
+	}
+
+	return buf.String()
+}
+
+func writeIPList(buf *bytes.Buffer, ips []storage.IPCount) {
+	for i, ip := range ips {
+		location := ipLocation(ip)
+		if location != "" {
+			buf.WriteString(fmt.Sprintf("%d\\. %s \\(%s\\) \\- %s\n", i+1, escapeMarkdown(ip.IP), escapeMarkdown(location), formatNumber(ip.Count)))
+		} else {
+			buf.WriteString(fmt.Sprintf("%d\\. %s \\- %s\n", i+1, escapeMarkdown(ip.IP), formatNumber(ip.Count)))
+		}
+	}
+}
+
+func (g *Generator) GenerateWallOfShame(end time.Time) (string, error) {
+	since := end.AddDate(0, 0, -7)
+
+	topIPs, err := g.storage.GetTopIPs(since, end, 10, "")
+	if err != nil {
+		return "", err
+	}
+
+	topASNs, err := g.storage.GetTopASNs(since, end, 10)
+	if err != nil {
+		return "", err
+	}
+
+	topCountries, err := g.storage.GetTopCountries(since, end, 10)
+	if err != nil {
+		return "", err
+	}
+
+	stats, err := g.storage.GetFailedStats(since, end, "")
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("🏆 *Weekly Wall of Shame*\n")
+	buf.WriteString(fmt.Sprintf("🖥️ Server: %s\n", escapeMarkdown(g.serverName)))
+	buf.WriteString(fmt.Sprintf("📅 %s – %s\n", since.Format("2006\\-01\\-02"), end.Format("2006\\-01\\-02")))
+	buf.WriteString(fmt.Sprintf("🔢 %s failed attempts from %s IPs\n", formatNumber(stats.TotalAttempts), formatNumber(stats.UniqueIPs)))
+
+	if len(topIPs) > 0 {
+		buf.WriteString("\n🌐 *Top 10 IPs*\n")
 		for i, ip := range topIPs {
-			location := formatLocation(ip.Country, ip.City)
-			if location != "" {
-				buf.WriteString(fmt.Sprintf("%d\\. %s \\(%s\\) \\- %s\n", i+1, escapeMarkdown(ip.IP), escapeMarkdown(location), formatNumber(ip.Count)))
-			} else {
-				buf.WriteString(fmt.Sprintf("%d\\. %s \\- %s\n", i+1, escapeMarkdown(ip.IP), formatNumber(ip.Count)))
+			line := fmt.Sprintf("%d\\. %s", i+1, escapeMarkdown(ip.IP))
+			if location := ipLocation(ip); location != "" {
+				line += fmt.Sprintf(" \\(%s\\)", escapeMarkdown(location))
+			}
+			if ip.ASN != 0 {
+				line += fmt.Sprintf(" AS%d", ip.ASN)
 			}
+			buf.WriteString(fmt.Sprintf("%s \\- %s\n", line, formatNumber(ip.Count)))
+		}
+	}
+
+	if len(topASNs) > 0 {
+		buf.WriteString("\n🏢 *Top 10 Networks*\n")
+		for i, a := range topASNs {
+			buf.WriteString(fmt.Sprintf("%d\\. AS%d %s \\- %s from %s IPs\n",
+				i+1, a.ASN, escapeMarkdown(a.ASOrg), formatNumber(a.Count), formatNumber(a.UniqueIPs)))
 		}
 	}
 
+	if len(topCountries) > 0 {
+		buf.WriteString("\n🗺️ *Top 10 Countries*\n")
+		for i, c := range topCountries {
+			buf.WriteString(fmt.Sprintf("%d\\. %s \\- %s from %s IPs\n",
+				i+1, withFlag(c.CountryCode, escapeMarkdown(c.Country)), formatNumber(c.Count), formatNumber(c.UniqueIPs)))
+		}
+	}
+
+	return buf.String(), nil
+}
+
+func (g *Generator) GenerateSSHDAuditSection(path string) string {
+	var buf bytes.Buffer
+	buf.WriteString("\n🔒 *Weekly SSH Config Audit*\n")
+
+	findings, err := audit.AuditSSHDConfig(path)
+	if err != nil {
+		buf.WriteString(fmt.Sprintf("Could not read %s: %s\n", escapeMarkdown(path), escapeMarkdown(err.Error())))
+		return buf.String()
+	}
+
+	for _, f := range findings {
+		if f.OK {
+			buf.WriteString(fmt.Sprintf("✅ %s: %s\n", f.Setting, escapeMarkdown(f.Value)))
+		} else {
+			buf.WriteString(fmt.Sprintf("❌ %s: %s \\(expected %s\\)\n", f.Setting, escapeMarkdown(f.Value), escapeMarkdown(f.Expected)))
+		}
+	}
 	return buf.String()
 }
 
-func (g *Generator) GenerateStats(days int) (string, error) {
-	since := time.Now().AddDate(0, 0, -days)
+const maxListedCVEs = 10
+
+func (g *Generator) GenerateAdvisorySection() string {
+	result, err := advisory.NewChecker().CheckOpenSSH()
+	if err != nil || len(result.CVEs) == 0 {
+		return ""
+	}
+
+	listed := result.CVEs
+	if len(listed) > maxListedCVEs {
+		listed = listed[:maxListedCVEs]
+	}
 
-	stats, err := g.storage.GetOverallStats(since)
+	var buf bytes.Buffer
+	buf.WriteString("\n🛡️ *OpenSSH Advisories*\n")
+	buf.WriteString(fmt.Sprintf("Your sshd version %s is affected by:\n", escapeMarkdown(result.Version)))
+	for _, cve := range listed {
+		buf.WriteString(fmt.Sprintf("• %s\n", escapeMarkdown(cve)))
+	}
+	if more := len(result.CVEs) - len(listed); more > 0 {
+		buf.WriteString(fmt.Sprintf("… and %d more\n", more))
+	}
+	buf.WriteString("Check for fixed packages: `apt list --upgradable`\n")
+	return buf.String()
+}
+
+func (g *Generator) GenerateStats(days int, service string) (string, error) {
+	now := time.Now()
+	since := now.AddDate(0, 0, -days)
+
+	stats, err := g.storage.GetOverallStats(since, service)
 	if err != nil {
 		return "", err
 	}
 
 	var buf bytes.Buffer
-	buf.WriteString(fmt.Sprintf("SSH Statistics (last %d days)\n", days))
-	buf.WriteString(fmt.Sprintf("Server: %s\n\n", g.serverName))
+	if service != "" {
+		buf.WriteString(fmt.Sprintf("%s Statistics (last %d days)\n", parser.ServiceLabel(service), days))
+	} else {
+		buf.WriteString(fmt.Sprintf("Statistics (last %d days)\n", days))
+	}
+	g.writeServerText(&buf)
 	buf.WriteString(fmt.Sprintf("Successful logins: %d\n", stats.SuccessCount))
 	buf.WriteString(fmt.Sprintf("Failed attempts: %d\n", stats.FailedCount))
 	buf.WriteString(fmt.Sprintf("Unique IPs: %d\n", stats.UniqueIPs))
 	buf.WriteString(fmt.Sprintf("Unique usernames: %d\n", stats.UniqueUsernames))
 
+	if service != "" {
+		return buf.String(), nil
+	}
+
+	serviceStats, err := g.storage.GetServiceStats(since, now)
+	if err != nil {
+		return "", err
+	}
+	if len(serviceStats) > 1 {
+		buf.WriteString("\nBy service:\n")
+		for _, ss := range serviceStats {
+			buf.WriteString(fmt.Sprintf("  %-10s  %6d successful  %8d failed  %6d unique IPs\n",
+				ss.Service, ss.SuccessCount, ss.FailedCount, ss.UniqueIPs))
+		}
+	}
+
 	return buf.String(), nil
 }
 
-func (g *Generator) GenerateLoginsReport(days int) (string, error) {
+func (g *Generator) GenerateLoginsReport(days int, filter storage.EventFilter) (string, error) {
 	since := time.Now().AddDate(0, 0, -days)
-	logins, err := g.storage.GetSuccessfulLogins(since)
+	logins, err := g.storage.GetSuccessfulLogins(since, filter)
 	if err != nil {
 		return "", err
 	}
 
 	var buf bytes.Buffer
-	buf.WriteString(fmt.Sprintf("Successful SSH Logins (last %d days)\n", days))
-	buf.WriteString(fmt.Sprintf("Server: %s\n\n", g.serverName))
+	buf.WriteString(fmt.Sprintf("Successful Logins (last %d days)\n", days))
+	g.writeServerText(&buf)
 
 	if len(logins) == 0 {
 		buf.WriteString("No successful logins in this period.\n")
@@ -129,18 +418,26 @@ func (g *Generator) GenerateLoginsReport(days int) (string, error) {
 	}
 
 	for _, login := range logins {
+		if login.MFA {
+			login.Method += "+mfa"
+		}
+		if login.AuthSource != "" {
+			login.Method += "+" + login.AuthSource
+		}
 		location := formatLocation(login.Country, login.City)
 		if location != "" {
-			buf.WriteString(fmt.Sprintf("%s  %-15s  %-12s  %s (%s)\n",
+			buf.WriteString(fmt.Sprintf("%s  %-9s  %-15s  %-14s  %s (%s)\n",
 				login.Timestamp.Format("2006-01-02 15:04:05"),
+				login.Service,
 				login.Username,
 				login.Method,
 				login.IP,
 				location,
 			))
 		} else {
-			buf.WriteString(fmt.Sprintf("%s  %-15s  %-12s  %s\n",
+			buf.WriteString(fmt.Sprintf("%s  %-9s  %-15s  %-14s  %s\n",
 				login.Timestamp.Format("2006-01-02 15:04:05"),
+				login.Service,
 				login.Username,
 				login.Method,
 				login.IP,
@@ -151,6 +448,78 @@ func (g *Generator) GenerateLoginsReport(days int) (string, error) {
 	return buf.String(), nil
 }
 
+// GenerateGeoIPComparison summarises how often the primary GeoIP database
+// (A) and the comparison database (B) disagreed on sampled IPs.
+func (g *Generator) GenerateGeoIPComparison(nameA, nameB string) (string, error) {
+	c, err := g.storage.GetGeoIPComparison(10)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("GeoIP Comparison\n")
+	g.writeServerText(&buf)
+	buf.WriteString(fmt.Sprintf("A: %s\nB: %s\n\n", nameA, nameB))
+
+	if c.Samples == 0 {
+		buf.WriteString("No IPs sampled yet.\n")
+		return buf.String(), nil
+	}
+
+	buf.WriteString(fmt.Sprintf("Sampled IPs: %d (%s – %s)\n", c.Samples, c.First.Format("2006-01-02"), c.Last.Format("2006-01-02")))
+	buf.WriteString(fmt.Sprintf("Unknown to A: %d (%s)\n", c.MissingA, percent(c.MissingA, c.Samples)))
+	buf.WriteString(fmt.Sprintf("Unknown to B: %d (%s)\n", c.MissingB, percent(c.MissingB, c.Samples)))
+	buf.WriteString(fmt.Sprintf("Country disagreements: %d (%s)\n", c.CountryMismatch, percent(c.CountryMismatch, c.Samples)))
+	buf.WriteString(fmt.Sprintf("City disagreements (same country): %d (%s)\n", c.CityMismatch, percent(c.CityMismatch, c.Samples)))
+
+	if len(c.Examples) > 0 {
+		buf.WriteString("\nRecent country disagreements:\n")
+		for _, d := range c.Examples {
+			buf.WriteString(fmt.Sprintf("  %-39s  A: %-24s  B: %s\n", d.IP,
+				comparedLocation(d.CountryCodeA, d.CityA), comparedLocation(d.CountryCodeB, d.CityB)))
+		}
+	}
+
+	return buf.String(), nil
+}
+
+func comparedLocation(countryCode, city string) string {
+	if countryCode == "" {
+		return "unknown"
+	}
+	return formatLocation(countryCode, city)
+}
+
+func percent(n, total int) string {
+	return fmt.Sprintf("%.1f%%", float64(n)*100/float64(total))
+}
+
+func (g *Generator) writeServerText(buf *bytes.Buffer) {
+	buf.WriteString(fmt.Sprintf("Server: %s\n", g.serverName))
+	if labels := g.metadata.Labels(); len(labels) > 0 {
+		buf.WriteString(strings.Join(labels, " | ") + "\n")
+	}
+	if g.metadata.RunbookURL != "" {
+		buf.WriteString(fmt.Sprintf("Runbook: %s\n", g.metadata.RunbookURL))
+	}
+	buf.WriteString("\n")
+}
+
+func ipLocation(ip storage.IPCount) string {
+	location := formatLocation(ip.Country, ip.City)
+	if location == "" {
+		return ""
+	}
+	return withFlag(ip.CountryCode, location)
+}
+
+func withFlag(countryCode, s string) string {
+	if flag := geoip.Flag(countryCode); flag != "" {
+		return flag + " " + s
+	}
+	return s
+}
+
 func formatLocation(country, city string) string {
 	if city != "" && country != "" {
 		return fmt.Sprintf("%s, %s", city, country)
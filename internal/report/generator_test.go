@@ -0,0 +1,131 @@
+package report
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/config"
+	"github.com/oxisoft/oxiwatch/internal/fixture"
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+func TestGenerateStats(t *testing.T) {
+	store := storage.NewMemory()
+	for i := 0; i < 3; i++ {
+		store.InsertEvent(fixture.Failure("root", "203.0.113.5").Ago(time.Duration(i)*time.Minute).Build(), geoip.Location{})
+	}
+	store.InsertEvent(fixture.Failure("admin", "198.51.100.7").Service("web").Build(), geoip.Location{})
+	store.InsertEvent(fixture.Success("alice", "192.0.2.1").Build(), geoip.Location{})
+	store.InsertEvent(fixture.Failure("old", "192.0.2.9").Ago(72*time.Hour).Build(), geoip.Location{})
+
+	gen := NewGenerator(store, "web1", config.ServerMetadata{Environment: "prod"}, "1.0.0")
+	output, err := gen.GenerateStats(1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"Server: web1\nEnv: prod\n",
+		"Successful logins: 1\n",
+		"Failed attempts: 4\n",
+		"Unique IPs: 3\n",
+		"By service:\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output:\n%s", want, output)
+		}
+	}
+}
+
+func TestDailyReportCoversOnlyThatDay(t *testing.T) {
+	store := storage.NewMemory()
+	day := time.Date(2026, time.January, 20, 0, 0, 0, 0, time.Local)
+	store.InsertEvent(fixture.Failure("root", "203.0.113.5").At(day.Add(-time.Minute)).Build(), geoip.Location{})
+	store.InsertEvent(fixture.Failure("root", "203.0.113.5").At(day.Add(9*time.Hour)).Build(), geoip.Location{})
+	store.InsertEvent(fixture.Failure("admin", "198.51.100.7").At(day.Add(23*time.Hour)).Build(), geoip.Location{})
+	store.InsertEvent(fixture.Failure("root", "203.0.113.5").At(day.AddDate(0, 0, 1)).Build(), geoip.Location{})
+
+	gen := NewGenerator(store, "web1", config.ServerMetadata{}, "")
+	data, err := gen.DailyData(day.Add(12 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Failed.TotalAttempts != 2 || data.Failed.UniqueIPs != 2 {
+		t.Errorf("expected 2 failures from 2 IPs on 2026-01-20, got %+v", data.Failed)
+	}
+	if !data.Date.Equal(day) {
+		t.Errorf("expected date %v, got %v", day, data.Date)
+	}
+}
+
+func TestWallOfShameCoversOnlyThatWeek(t *testing.T) {
+	store := storage.NewMemory()
+	end := time.Date(2026, time.January, 20, 0, 0, 0, 0, time.Local)
+	cn := fixture.Location("CN", "China", "Beijing")
+	cn.ASN, cn.ASOrg = 4134, "Chinanet"
+	de := fixture.Location("DE", "Germany", "Berlin")
+	de.ASN, de.ASOrg = 3320, "Deutsche Telekom"
+	store.InsertEvent(fixture.Failure("root", "203.0.113.5").At(end.Add(-time.Hour)).Build(), cn)
+	// After the week, e.g. when the report is regenerated later.
+	for i := 0; i < 3; i++ {
+		store.InsertEvent(fixture.Failure("admin", "198.51.100.7").At(end.Add(time.Duration(i)*time.Hour)).Build(), de)
+	}
+
+	gen := NewGenerator(store, "web1", config.ServerMetadata{}, "")
+	output, err := gen.GenerateWallOfShame(end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, unwanted := range []string{"198.51.100.7", "AS3320", "Germany"} {
+		if strings.Contains(output, unwanted) {
+			t.Errorf("expected no %s after the week:\n%s", unwanted, output)
+		}
+	}
+	if !strings.Contains(output, "AS4134") || !strings.Contains(output, "China") {
+		t.Errorf("expected the week's network and country:\n%s", output)
+	}
+}
+
+func TestDailyReportTemplate(t *testing.T) {
+	store := storage.NewMemory()
+	noon := time.Date(2026, time.January, 20, 12, 0, 0, 0, time.Local)
+	for i := 0; i < 1200; i++ {
+		store.InsertEvent(fixture.Failure("root", "203.0.113.5").At(noon.Add(-time.Duration(i)*time.Second)).Build(), geoip.Location{})
+	}
+
+	path := t.TempDir() + "/report.tmpl"
+	tmpl := `Tagesbericht {{.Server}} {{.Date.Format "02.01.2006"}}
+Fehlversuche: {{number .Failed.TotalAttempts}}
+{{range $i, $ip := .TopIPs}}{{add $i 1}}. {{$ip.IP}} ({{$ip.Count}})
+{{end}}`
+	if err := os.WriteFile(path, []byte(tmpl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	gen := NewGenerator(store, "web1", config.ServerMetadata{}, "")
+	if err := gen.LoadTemplate(path); err != nil {
+		t.Fatal(err)
+	}
+	output, err := gen.GenerateDailyReport(noon)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Tagesbericht web1 20.01.2026\nFehlversuche: 1,200\n1. 203.0.113.5 (1200)\n"
+	if output != want {
+		t.Errorf("expected %q, got %q", want, output)
+	}
+}
+
+func TestLoadTemplateError(t *testing.T) {
+	path := t.TempDir() + "/broken.tmpl"
+	os.WriteFile(path, []byte("{{.Server"), 0o644)
+
+	gen := NewGenerator(storage.NewMemory(), "web1", config.ServerMetadata{}, "")
+	if err := gen.LoadTemplate(path); err == nil {
+		t.Error("expected parse error")
+	}
+}
@@ -0,0 +1,215 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+// failureRunGap is the longest pause between two failed attempts from the
+// same IP and service that still collapses them into one timeline entry.
+const failureRunGap = 10 * time.Minute
+
+// timelineEntry is either a single successful login or a run of failed
+// attempts from one IP against one service.
+type timelineEntry struct {
+	start, end time.Time
+	events     []storage.SSHEventRecord
+}
+
+// GenerateIncidentTimeline writes a Markdown timeline of the stored events
+// matching filter (normally an IP or a username) in [since, until), for
+// pasting into post-incident writeups.
+func (g *Generator) GenerateIncidentTimeline(filter storage.EventFilter, since, until time.Time) (string, error) {
+	failures, err := g.storage.GetFailedAttempts(since, filter)
+	if err != nil {
+		return "", err
+	}
+	logins, err := g.storage.GetSuccessfulLogins(since, filter)
+	if err != nil {
+		return "", err
+	}
+
+	var events []storage.SSHEventRecord
+	for _, e := range append(failures, logins...) {
+		if e.Timestamp.Before(until) {
+			events = append(events, e)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+
+	var subject []string
+	if filter.IP != "" {
+		subject = append(subject, filter.IP)
+	}
+	if filter.Username != "" {
+		subject = append(subject, "user "+filter.Username)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("# Incident timeline: %s\n\n", strings.Join(subject, ", ")))
+	buf.WriteString(fmt.Sprintf("- Server: %s\n", g.serverName))
+	if labels := g.metadata.Labels(); len(labels) > 0 {
+		buf.WriteString(fmt.Sprintf("- %s\n", strings.Join(labels, " | ")))
+	}
+	buf.WriteString(fmt.Sprintf("- Window: %s to %s\n", since.Format("2006-01-02 15:04:05"), until.Format("2006-01-02 15:04:05 MST")))
+
+	if len(events) == 0 {
+		buf.WriteString("\nNo events in this window.\n")
+		return buf.String(), nil
+	}
+
+	var failed, succeeded int
+	users := make(map[string]int)
+	ips := make(map[string]int)
+	var locations []string
+	for _, e := range events {
+		if e.EventType == "success" {
+			succeeded++
+		} else {
+			failed++
+		}
+		users[e.Username]++
+		ips[e.IP]++
+		if location := withFlag(e.CountryCode, formatLocation(e.Country, e.City)); location != "" && !slices.Contains(locations, location) {
+			locations = append(locations, location)
+		}
+	}
+
+	buf.WriteString("\n## Summary\n\n")
+	buf.WriteString(fmt.Sprintf("- First event: %s\n", events[0].Timestamp.Format("2006-01-02 15:04:05")))
+	buf.WriteString(fmt.Sprintf("- Last event: %s\n", events[len(events)-1].Timestamp.Format("2006-01-02 15:04:05")))
+	buf.WriteString(fmt.Sprintf("- Failed attempts: %d\n", failed))
+	buf.WriteString(fmt.Sprintf("- Successful logins: %d\n", succeeded))
+	if filter.Username == "" {
+		buf.WriteString(fmt.Sprintf("- Usernames: %s\n", mostFrequent(users, 10)))
+	}
+	if filter.IP == "" {
+		buf.WriteString(fmt.Sprintf("- IPs: %s\n", mostFrequent(ips, 10)))
+	}
+	if len(locations) > 0 {
+		buf.WriteString(fmt.Sprintf("- Locations: %s\n", strings.Join(locations, "; ")))
+	}
+
+	buf.WriteString("\n## Timeline\n\n")
+	for _, entry := range buildTimeline(events) {
+		buf.WriteString(formatTimelineEntry(entry, filter))
+	}
+
+	buf.WriteString("\nSession ends and in-memory detections (bursts, spikes) are not stored, so they are not part of this timeline.\n")
+	return buf.String(), nil
+}
+
+func buildTimeline(events []storage.SSHEventRecord) []timelineEntry {
+	var entries []timelineEntry
+	open := make(map[string]int)
+	for _, e := range events {
+		if e.EventType == "success" {
+			// Failures after a login start a new run so the timeline stays
+			// in order around it.
+			clear(open)
+			entries = append(entries, timelineEntry{start: e.Timestamp, end: e.Timestamp, events: []storage.SSHEventRecord{e}})
+			continue
+		}
+
+		key := e.IP + "|" + e.Service
+		if i, ok := open[key]; ok && e.Timestamp.Sub(entries[i].end) <= failureRunGap {
+			entries[i].end = e.Timestamp
+			entries[i].events = append(entries[i].events, e)
+			continue
+		}
+		open[key] = len(entries)
+		entries = append(entries, timelineEntry{start: e.Timestamp, end: e.Timestamp, events: []storage.SSHEventRecord{e}})
+	}
+	return entries
+}
+
+func formatTimelineEntry(entry timelineEntry, filter storage.EventFilter) string {
+	first := entry.events[0]
+	when := entry.start.Format("2006-01-02 15:04:05")
+	switch {
+	case entry.end.Equal(entry.start):
+	case entry.end.Format("2006-01-02") == entry.start.Format("2006-01-02"):
+		when += " – " + entry.end.Format("15:04:05")
+	default:
+		when += " – " + entry.end.Format("2006-01-02 15:04:05")
+	}
+
+	source := first.IP
+	if location := formatLocation(first.Country, first.City); location != "" {
+		source += " (" + location + ")"
+	}
+
+	if first.EventType == "success" {
+		method := first.Method
+		if first.MFA {
+			method += "+mfa"
+		}
+		if first.AuthSource != "" {
+			method += "+" + first.AuthSource
+		}
+		return fmt.Sprintf("- `%s` ✅ **%s login** as `%s` via %s from %s port %d\n",
+			when, first.Service, first.Username, method, source, first.Port)
+	}
+
+	users := make(map[string]int)
+	invalid := 0
+	for _, e := range entry.events {
+		users[e.Username]++
+		if e.InvalidUser {
+			invalid++
+		}
+	}
+	line := fmt.Sprintf("- `%s` ❌ %d failed %s attempt", when, len(entry.events), first.Service)
+	if len(entry.events) != 1 {
+		line += "s"
+	}
+	if filter.Username == "" {
+		line += " for " + mostFrequent(users, 5)
+	}
+	if filter.IP == "" {
+		line += " from " + source
+	}
+	if invalid > 0 {
+		line += fmt.Sprintf(", %d for invalid users", invalid)
+	}
+	if first.CampaignID != "" {
+		line += fmt.Sprintf(", campaign `%s`", first.CampaignID)
+	}
+	return line + "\n"
+}
+
+// mostFrequent lists up to limit keys, most frequent first, with their
+// counts when there is more than one key.
+func mostFrequent(counts map[string]int, limit int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	if len(keys) == 1 {
+		return "`" + keys[0] + "`"
+	}
+	var parts []string
+	for i, k := range keys {
+		if i == limit {
+			parts = append(parts, fmt.Sprintf("+%d more", len(keys)-limit))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("`%s` (%d)", k, counts[k]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,54 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/config"
+	"github.com/oxisoft/oxiwatch/internal/fixture"
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+func TestIncidentTimeline(t *testing.T) {
+	store := storage.NewMemory()
+	cn := fixture.Location("CN", "China", "Beijing")
+	start := time.Date(2026, time.March, 3, 10, 0, 0, 0, time.Local)
+
+	for i := 0; i < 5; i++ {
+		store.InsertEvent(fixture.Failure("root", "203.0.113.5").At(start.Add(time.Duration(i)*time.Minute)).Build(), cn)
+	}
+	store.InsertEvent(fixture.Failure("admin", "203.0.113.5").At(start.Add(5*time.Minute)).InvalidUser().Build(), cn)
+	store.InsertEvent(fixture.Success("root", "203.0.113.5").At(start.Add(6*time.Minute)).Build(), cn)
+	// After the login and after a long pause: separate entries.
+	store.InsertEvent(fixture.Failure("root", "203.0.113.5").At(start.Add(7*time.Minute)).Build(), cn)
+	store.InsertEvent(fixture.Failure("root", "203.0.113.5").At(start.Add(2*time.Hour)).Build(), cn)
+	// Outside the window or another IP.
+	store.InsertEvent(fixture.Failure("root", "203.0.113.5").At(start.Add(-2*time.Hour)).Build(), cn)
+	store.InsertEvent(fixture.Failure("root", "198.51.100.7").At(start).Build(), geoip.Location{})
+
+	gen := NewGenerator(store, "web1", config.ServerMetadata{}, "")
+	output, err := gen.GenerateIncidentTimeline(storage.EventFilter{IP: "203.0.113.5"}, start.Add(-time.Hour), start.Add(3*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"# Incident timeline: 203.0.113.5\n",
+		"- Failed attempts: 8\n",
+		"- Successful logins: 1\n",
+		"- Usernames: `root` (8), `admin` (1)\n",
+		"- `2026-03-03 10:00:00 – 10:05:00` ❌ 6 failed ssh attempts for `root` (5), `admin` (1), 1 for invalid users\n",
+		"- `2026-03-03 10:06:00` ✅ **ssh login** as `root` via password from 203.0.113.5 (Beijing, China)",
+		"- `2026-03-03 10:07:00` ❌ 1 failed ssh attempt for `root`\n",
+		"- `2026-03-03 12:00:00` ❌ 1 failed ssh attempt for `root`\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "198.51.100.7") || strings.Contains(output, "08:00:00") {
+		t.Errorf("timeline includes events it should not:\n%s", output)
+	}
+}
@@ -0,0 +1,137 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+// keyAccount is one account a key logs into, with the IPs it came from,
+// most recent first.
+type keyAccount struct {
+	username string
+	logins   int
+	lastSeen time.Time
+	sources  []storage.KeyUsage
+}
+
+type inventoryKey struct {
+	fingerprint string
+	keyType     string
+	lastSeen    time.Time
+	accounts    []*keyAccount
+}
+
+// GenerateKeyInventory lists which SSH keys log into which accounts from
+// where, and points out keys shared between accounts and keys not used in
+// unusedDays.
+func (g *Generator) GenerateKeyInventory(unusedDays int, now time.Time) (string, error) {
+	usage, err := g.storage.GetKeyUsage("", "")
+	if err != nil {
+		return "", err
+	}
+	keys := groupKeyUsage(usage)
+
+	var buf bytes.Buffer
+	buf.WriteString("SSH Key Inventory\n")
+	g.writeServerText(&buf)
+
+	if len(keys) == 0 {
+		buf.WriteString("No public key logins recorded yet.\n")
+		return buf.String(), nil
+	}
+
+	accounts := make(map[string]bool)
+	var shared, unused []*inventoryKey
+	cutoff := now.AddDate(0, 0, -unusedDays)
+	for _, k := range keys {
+		for _, a := range k.accounts {
+			accounts[a.username] = true
+		}
+		if len(k.accounts) > 1 {
+			shared = append(shared, k)
+		}
+		if k.lastSeen.Before(cutoff) {
+			unused = append(unused, k)
+		}
+	}
+	buf.WriteString(fmt.Sprintf("Keys: %d, accounts: %d\n", len(keys), len(accounts)))
+
+	if len(shared) > 0 {
+		buf.WriteString("\nUsed by more than one account:\n")
+		for _, k := range shared {
+			var names []string
+			for _, a := range k.accounts {
+				names = append(names, a.username)
+			}
+			buf.WriteString(fmt.Sprintf("  %s  %s\n", keyLabel(k), strings.Join(names, ", ")))
+		}
+	}
+
+	if len(unused) > 0 {
+		buf.WriteString(fmt.Sprintf("\nNot used in %d days:\n", unusedDays))
+		for _, k := range unused {
+			buf.WriteString(fmt.Sprintf("  %s  last used %s (%d days ago)\n",
+				keyLabel(k), k.lastSeen.Format("2006-01-02"), int(math.Round(now.Sub(k.lastSeen).Hours()/24))))
+		}
+	}
+
+	buf.WriteString("\nAll keys:\n")
+	for _, k := range keys {
+		buf.WriteString(fmt.Sprintf("%s\n", keyLabel(k)))
+		for _, a := range k.accounts {
+			var sources []string
+			for _, s := range a.sources {
+				source := s.IP
+				if location := formatLocation(s.Country, s.City); location != "" {
+					source += " (" + location + ")"
+				}
+				sources = append(sources, source)
+			}
+			buf.WriteString(fmt.Sprintf("  %-15s  %5d logins  last %s  from %s\n",
+				a.username, a.logins, a.lastSeen.Format("2006-01-02 15:04"), strings.Join(sources, ", ")))
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// groupKeyUsage groups inventory rows, which are ordered by fingerprint and
+// username, into keys and accounts.
+func groupKeyUsage(usage []storage.KeyUsage) []*inventoryKey {
+	var keys []*inventoryKey
+	for _, u := range usage {
+		if len(keys) == 0 || keys[len(keys)-1].fingerprint != u.Fingerprint {
+			keys = append(keys, &inventoryKey{fingerprint: u.Fingerprint})
+		}
+		k := keys[len(keys)-1]
+		if u.KeyType != "" {
+			k.keyType = u.KeyType
+		}
+		if u.LastSeen.After(k.lastSeen) {
+			k.lastSeen = u.LastSeen
+		}
+
+		if len(k.accounts) == 0 || k.accounts[len(k.accounts)-1].username != u.Username {
+			k.accounts = append(k.accounts, &keyAccount{username: u.Username})
+		}
+		a := k.accounts[len(k.accounts)-1]
+		a.logins += u.Logins
+		if u.LastSeen.After(a.lastSeen) {
+			a.lastSeen = u.LastSeen
+		}
+		a.sources = append(a.sources, u)
+	}
+	return keys
+}
+
+func keyLabel(k *inventoryKey) string {
+	if k.keyType == "" {
+		return k.fingerprint
+	}
+	return fmt.Sprintf("%s (%s)", k.fingerprint, k.keyType)
+}
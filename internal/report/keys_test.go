@@ -0,0 +1,46 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/config"
+	"github.com/oxisoft/oxiwatch/internal/fixture"
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+func TestKeyInventory(t *testing.T) {
+	store := storage.NewMemory()
+	now := time.Date(2026, time.June, 1, 12, 0, 0, 0, time.Local)
+	de := fixture.Location("DE", "Germany", "Berlin")
+
+	store.InsertEvent(fixture.Success("deploy", "198.51.100.7").At(now.Add(-time.Hour)).PublicKey("ED25519", "SHA256:shared").Build(), de)
+	store.InsertEvent(fixture.Success("deploy", "198.51.100.7").At(now.Add(-2*time.Hour)).PublicKey("ED25519", "SHA256:shared").Build(), de)
+	store.InsertEvent(fixture.Success("root", "192.0.2.1").At(now.AddDate(0, 0, -3)).PublicKey("ED25519", "SHA256:shared").Build(), geoip.Location{})
+	store.InsertEvent(fixture.Success("alice", "192.0.2.1").At(now.AddDate(0, 0, -120)).PublicKey("RSA", "SHA256:stale").Build(), geoip.Location{})
+	// Failures and password logins are not part of the inventory.
+	store.InsertEvent(fixture.Failure("root", "203.0.113.5").At(now).PublicKey("RSA", "SHA256:attacker").Build(), geoip.Location{})
+	store.InsertEvent(fixture.Success("bob", "192.0.2.1").At(now).Build(), geoip.Location{})
+
+	gen := NewGenerator(store, "web1", config.ServerMetadata{}, "")
+	output, err := gen.GenerateKeyInventory(90, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"Keys: 2, accounts: 3\n",
+		"Used by more than one account:\n  SHA256:shared (ED25519)  deploy, root\n",
+		"Not used in 90 days:\n  SHA256:stale (RSA)  last used 2026-02-01 (120 days ago)\n",
+		"  deploy               2 logins  last 2026-06-01 11:00  from 198.51.100.7 (Berlin, Germany)\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "SHA256:attacker") || strings.Contains(output, "bob") {
+		t.Errorf("inventory includes failed or password logins:\n%s", output)
+	}
+}
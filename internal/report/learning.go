@@ -0,0 +1,218 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+// MinCandidateLogins is how many successful logins an IP needs before it is
+// suggested for trusted_networks.
+const MinCandidateLogins = 3
+
+// TrustedCandidate is an IP that logged in successfully at least
+// MinCandidateLogins times and never failed, so trusting it would only
+// silence routine login alerts.
+type TrustedCandidate struct {
+	IP          string
+	Logins      int
+	Users       []string
+	Country     string
+	CountryCode string
+	City        string
+	LastSeen    time.Time
+}
+
+// TrustedCandidates lists candidate IPs from the logins in [since, until),
+// most logins first, leaving out IPs already covered by trusted. Failed
+// attempts count from any time still in the database.
+func (g *Generator) TrustedCandidates(since, until time.Time, trusted []netip.Prefix) ([]TrustedCandidate, error) {
+	logins, err := g.storage.GetSuccessfulLogins(since, storage.EventFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	byIP := make(map[string]*TrustedCandidate)
+	users := make(map[string]map[string]bool)
+	for _, e := range logins {
+		if !e.Timestamp.Before(until) || IsTrusted(trusted, e.IP) {
+			continue
+		}
+		c, ok := byIP[e.IP]
+		if !ok {
+			c = &TrustedCandidate{IP: e.IP}
+			byIP[e.IP] = c
+			users[e.IP] = make(map[string]bool)
+		}
+		c.Logins++
+		users[e.IP][e.Username] = true
+		if e.Timestamp.After(c.LastSeen) {
+			c.LastSeen = e.Timestamp
+			c.Country, c.CountryCode, c.City = e.Country, e.CountryCode, e.City
+		}
+	}
+
+	var candidates []TrustedCandidate
+	for ip, c := range byIP {
+		if c.Logins < MinCandidateLogins {
+			continue
+		}
+		failures, err := g.storage.GetFailedAttempts(time.Time{}, storage.EventFilter{IP: ip})
+		if err != nil {
+			return nil, err
+		}
+		if len(failures) > 0 {
+			continue
+		}
+		for u := range users[ip] {
+			c.Users = append(c.Users, u)
+		}
+		sort.Strings(c.Users)
+		candidates = append(candidates, *c)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Logins != candidates[j].Logins {
+			return candidates[i].Logins > candidates[j].Logins
+		}
+		return candidates[i].IP < candidates[j].IP
+	})
+	return candidates, nil
+}
+
+// IsTrusted reports whether ip lies in one of the trusted networks.
+func IsTrusted(trusted []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+type userBaseline struct {
+	logins    int
+	ips       map[string]bool
+	countries map[string]int
+	methods   map[string]int
+	keys      map[string]bool
+}
+
+// GenerateLearningSummary describes what the learning period in
+// [since, until) established: how each account usually logs in, and which
+// IPs could be trusted.
+func (g *Generator) GenerateLearningSummary(since, until time.Time, trusted []netip.Prefix) (string, error) {
+	logins, err := g.storage.GetSuccessfulLogins(since, storage.EventFilter{})
+	if err != nil {
+		return "", err
+	}
+	keys, err := g.storage.GetKeyUsage("", "")
+	if err != nil {
+		return "", err
+	}
+	candidates, err := g.TrustedCandidates(since, until, trusted)
+	if err != nil {
+		return "", err
+	}
+
+	baselines := make(map[string]*userBaseline)
+	for _, e := range logins {
+		if !e.Timestamp.Before(until) {
+			continue
+		}
+		b, ok := baselines[e.Username]
+		if !ok {
+			b = &userBaseline{ips: make(map[string]bool), countries: make(map[string]int), methods: make(map[string]int), keys: make(map[string]bool)}
+			baselines[e.Username] = b
+		}
+		b.logins++
+		b.ips[e.IP] = true
+		if e.Country != "" {
+			b.countries[e.Country]++
+		}
+		b.methods[e.Method]++
+	}
+	for _, k := range keys {
+		if b, ok := baselines[k.Username]; ok && !k.LastSeen.Before(since) {
+			b.keys[k.Fingerprint] = true
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("🎓 *Learning Period Summary*\n")
+	buf.WriteString(fmt.Sprintf("🖥️ Server: %s\n", escapeMarkdown(g.serverName)))
+	buf.WriteString(fmt.Sprintf("📅 %s – %s\n", since.Format("2006\\-01\\-02"), until.Format("2006\\-01\\-02")))
+	buf.WriteString("Detections were recorded but not alerted during this period\\.\n")
+
+	buf.WriteString("\n👤 *Per\\-user baselines*\n")
+	if len(baselines) == 0 {
+		buf.WriteString("No successful logins\\.\n")
+	}
+	names := make([]string, 0, len(baselines))
+	for name := range baselines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b := baselines[name]
+		line := fmt.Sprintf("• %s: %s logins from %d %s", escapeMarkdown(name), formatNumber(b.logins), len(b.ips), plural(len(b.ips), "IP", "IPs"))
+		if len(b.countries) > 0 {
+			line += fmt.Sprintf(" in %s", escapeMarkdown(strings.Join(sortedKeys(b.countries), ", ")))
+		}
+		line += fmt.Sprintf(" via %s", escapeMarkdown(strings.Join(sortedKeys(b.methods), ", ")))
+		if len(b.keys) > 0 {
+			line += fmt.Sprintf(", %d %s", len(b.keys), plural(len(b.keys), "key", "keys"))
+		}
+		buf.WriteString(line + "\n")
+	}
+
+	buf.WriteString("\n✅ *Trusted IP candidates*\n")
+	if len(candidates) == 0 {
+		buf.WriteString(fmt.Sprintf("No IP with at least %d logins and no failed attempts\\.\n", MinCandidateLogins))
+		return buf.String(), nil
+	}
+	buf.WriteString(fmt.Sprintf("At least %d logins and never a failed attempt:\n", MinCandidateLogins))
+	var ips []string
+	for _, c := range candidates {
+		line := fmt.Sprintf("• %s", escapeMarkdown(c.IP))
+		if location := formatLocation(c.Country, c.City); location != "" {
+			line += fmt.Sprintf(" \\(%s\\)", escapeMarkdown(withFlag(c.CountryCode, location)))
+		}
+		line += fmt.Sprintf(" \\- %s logins as %s", formatNumber(c.Logins), escapeMarkdown(strings.Join(c.Users, ", ")))
+		buf.WriteString(line + "\n")
+		ips = append(ips, c.IP)
+	}
+	buf.WriteString(fmt.Sprintf("\nTo approve, run `oxiwatch trusted approve %s` on the server\\.\n", strings.Join(ips, " ")))
+	return buf.String(), nil
+}
+
+// sortedKeys lists keys by count, most frequent first.
+func sortedKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,58 @@
+package report
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/config"
+	"github.com/oxisoft/oxiwatch/internal/fixture"
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+func TestLearningSummary(t *testing.T) {
+	store := storage.NewMemory()
+	until := time.Date(2026, time.June, 8, 0, 0, 0, 0, time.Local)
+	since := until.AddDate(0, 0, -7)
+	de := fixture.Location("DE", "Germany", "Berlin")
+
+	login := func(user, ip string, day int, loc geoip.Location) {
+		store.InsertEvent(fixture.Success(user, ip).At(since.AddDate(0, 0, day).Add(time.Duration(len(user))*time.Minute)).Build(), loc)
+	}
+	for day := 0; day < 4; day++ {
+		login("alice", "198.51.100.7", day, de)
+		login("deploy", "198.51.100.7", day, de)
+		login("bob", "192.0.2.1", day, geoip.Location{})
+		login("carol", "10.0.0.5", day, geoip.Location{})
+	}
+	login("alice", "203.0.113.9", 5, geoip.Location{})
+	// 192.0.2.1 also failed once, 10.0.0.5 is already trusted.
+	store.InsertEvent(fixture.Failure("root", "192.0.2.1").At(since.AddDate(0, 0, -30)).Build(), geoip.Location{})
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	gen := NewGenerator(store, "web1", config.ServerMetadata{}, "")
+	candidates, err := gen.TrustedCandidates(since, until, trusted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 || candidates[0].IP != "198.51.100.7" || candidates[0].Logins != 8 || strings.Join(candidates[0].Users, ",") != "alice,deploy" {
+		t.Fatalf("unexpected candidates: %+v", candidates)
+	}
+
+	output, err := gen.GenerateLearningSummary(since, until, trusted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"• alice: 5 logins from 2 IPs in Germany via password\n",
+		"• carol: 4 logins from 1 IP via password\n",
+		"• 198\\.51\\.100\\.7 \\(🇩🇪 Berlin, Germany\\) \\- 8 logins as alice, deploy\n",
+		"`oxiwatch trusted approve 198.51.100.7`",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output:\n%s", want, output)
+		}
+	}
+}
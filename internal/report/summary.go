@@ -0,0 +1,460 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+const stateRollupThrough = "rollup_through"
+
+// SummaryData is a quarterly or annual summary built from the daily rollups,
+// which are kept after retention cleanup removes the events themselves.
+type SummaryData struct {
+	Title         string
+	Server        string
+	Labels        []string
+	Since         time.Time
+	Until         time.Time
+	Days          int
+	SuccessCount  int
+	FailedCount   int
+	PeakUniqueIPs int
+	Trend         []TrendPoint
+	Services      []storage.ServiceStats
+	TopIPs        []storage.IPCount
+	TopCountries  []storage.CountryCount
+	BusiestDays   []BusyDay
+	Incidents     []Incident
+}
+
+// TrendPoint is one bar of the trend chart: a week of a quarter or a month
+// of a year.
+type TrendPoint struct {
+	Label   string
+	Success int
+	Failed  int
+}
+
+// BusyDay is one of the days with the most failed attempts, with the IP
+// that contributed most to it.
+type BusyDay struct {
+	Day    time.Time
+	Failed int
+	TopIP  storage.IPCount
+}
+
+// Incident is a notable incident of the period: an attack campaign that is
+// still stored, or a legal hold an admin placed during the period.
+type Incident struct {
+	Start       time.Time
+	End         time.Time
+	Kind        string
+	Description string
+}
+
+// ParsePeriod parses a summary period, either a year ("2025") or a quarter
+// ("2025Q3"), into UTC bounds and a title.
+func ParsePeriod(period string) (since, until time.Time, title string, err error) {
+	period = strings.ToUpper(strings.TrimSpace(period))
+	yearPart, quarterPart, isQuarter := strings.Cut(period, "Q")
+
+	year, err := strconv.Atoi(yearPart)
+	if err != nil || year < 1970 || year > 9999 {
+		return time.Time{}, time.Time{}, "", fmt.Errorf("invalid period %q, expected YYYY or YYYYQn", period)
+	}
+	if !isQuarter {
+		since = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		return since, since.AddDate(1, 0, 0), fmt.Sprintf("Annual Summary %d", year), nil
+	}
+
+	quarter, err := strconv.Atoi(quarterPart)
+	if err != nil || quarter < 1 || quarter > 4 {
+		return time.Time{}, time.Time{}, "", fmt.Errorf("invalid period %q, expected YYYY or YYYYQn", period)
+	}
+	since = time.Date(year, time.Month(3*quarter-2), 1, 0, 0, 0, 0, time.UTC)
+	return since, since.AddDate(0, 3, 0), fmt.Sprintf("Quarterly Summary %dQ%d", year, quarter), nil
+}
+
+// UpdateRollups rolls up every complete UTC day that has not been rolled up
+// yet, starting no earlier than oldest. It runs before retention cleanup so
+// no day is lost, and before a summary so it covers everything up to
+// yesterday.
+func UpdateRollups(store storage.Store, oldest, now time.Time) (int, error) {
+	day := time.Date(oldest.UTC().Year(), oldest.UTC().Month(), oldest.UTC().Day(), 0, 0, 0, 0, time.UTC)
+	through, err := store.GetState(stateRollupThrough)
+	if err != nil {
+		return 0, err
+	}
+	if through != "" {
+		last, err := time.Parse("2006-01-02", through)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s state %q: %w", stateRollupThrough, through, err)
+		}
+		if next := last.AddDate(0, 0, 1); next.After(day) {
+			day = next
+		}
+	}
+
+	today := time.Date(now.UTC().Year(), now.UTC().Month(), now.UTC().Day(), 0, 0, 0, 0, time.UTC)
+	var count int
+	for ; day.Before(today); day = day.AddDate(0, 0, 1) {
+		if err := store.Rollup(day); err != nil {
+			return count, fmt.Errorf("failed to roll up %s: %w", day.Format("2006-01-02"), err)
+		}
+		if err := store.SetState(stateRollupThrough, day.Format("2006-01-02")); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (g *Generator) SummaryData(since, until time.Time, title string) (*SummaryData, error) {
+	data := &SummaryData{
+		Title:  title,
+		Server: g.serverName,
+		Labels: g.metadata.Labels(),
+		Since:  since,
+		Until:  until,
+	}
+
+	rollups, err := g.storage.GetDailyRollups(since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	monthly := until.Sub(since) > 100*24*time.Hour
+	services := make(map[string]*storage.ServiceStats)
+	days := make(map[time.Time]*BusyDay)
+	var order []string
+	uniqueIPs := make(map[time.Time]int)
+	for _, r := range rollups {
+		data.SuccessCount += r.Success
+		data.FailedCount += r.Failed
+		uniqueIPs[r.Day] += r.UniqueIPs
+
+		ss, ok := services[r.Service]
+		if !ok {
+			ss = &storage.ServiceStats{Service: r.Service}
+			services[r.Service] = ss
+			order = append(order, r.Service)
+		}
+		ss.SuccessCount += r.Success
+		ss.FailedCount += r.Failed
+
+		if _, ok := days[r.Day]; !ok {
+			days[r.Day] = &BusyDay{Day: r.Day}
+		}
+		days[r.Day].Failed += r.Failed
+	}
+	data.Days = len(days)
+	for _, n := range uniqueIPs {
+		data.PeakUniqueIPs = max(data.PeakUniqueIPs, n)
+	}
+	for _, name := range order {
+		data.Services = append(data.Services, *services[name])
+	}
+	data.Trend = trend(rollups, since, until, monthly)
+
+	if data.TopIPs, err = g.storage.GetRollupTopIPs(since, until, 10); err != nil {
+		return nil, err
+	}
+	if data.TopCountries, err = g.storage.GetRollupTopCountries(since, until, 10); err != nil {
+		return nil, err
+	}
+
+	for _, d := range days {
+		if d.Failed > 0 {
+			data.BusiestDays = append(data.BusiestDays, *d)
+		}
+	}
+	sort.Slice(data.BusiestDays, func(i, j int) bool {
+		a, b := data.BusiestDays[i], data.BusiestDays[j]
+		if a.Failed != b.Failed {
+			return a.Failed > b.Failed
+		}
+		return a.Day.Before(b.Day)
+	})
+	if len(data.BusiestDays) > 5 {
+		data.BusiestDays = data.BusiestDays[:5]
+	}
+	for i, d := range data.BusiestDays {
+		top, err := g.storage.GetRollupTopIPs(d.Day, d.Day.AddDate(0, 0, 1), 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(top) > 0 {
+			data.BusiestDays[i].TopIP = top[0]
+		}
+	}
+
+	if data.Incidents, err = g.incidents(since, until); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// incidents collects the campaigns active in [since, until) and the legal
+// holds placed in it, oldest first. Campaigns are deleted with the events
+// they were found in, so only those within retention are listed.
+func (g *Generator) incidents(since, until time.Time) ([]Incident, error) {
+	var incidents []Incident
+	campaigns, err := g.storage.GetCampaigns(since)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range campaigns {
+		if !c.FirstSeen.Before(until) {
+			continue
+		}
+		network := fmt.Sprintf("AS%d", c.ASN)
+		if c.ASOrg != "" {
+			network += " " + c.ASOrg
+		}
+		incidents = append(incidents, Incident{
+			Start:       c.FirstSeen,
+			End:         c.LastSeen,
+			Kind:        "campaign",
+			Description: fmt.Sprintf("%s: %s attempts from %d IPs in %s", c.ID, formatNumber(c.Attempts), len(c.IPs), network),
+		})
+	}
+
+	holds, err := g.storage.GetLegalHolds(true)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range holds {
+		if h.CreatedAt.Before(since) || !h.CreatedAt.Before(until) {
+			continue
+		}
+		var subject []string
+		if h.IPRange != "" {
+			subject = append(subject, h.IPRange)
+		}
+		if h.Username != "" {
+			subject = append(subject, "user "+h.Username)
+		}
+		incidents = append(incidents, Incident{
+			Start:       h.CreatedAt,
+			End:         h.ReleasedAt,
+			Kind:        "legal hold",
+			Description: fmt.Sprintf("%s (%s)", h.Reason, strings.Join(subject, ", ")),
+		})
+	}
+
+	sort.SliceStable(incidents, func(i, j int) bool {
+		return incidents[i].Start.Before(incidents[j].Start)
+	})
+	return incidents, nil
+}
+
+func trend(rollups []storage.DailyRollup, since, until time.Time, monthly bool) []TrendPoint {
+	var points []TrendPoint
+	var starts []time.Time
+	for start := since; start.Before(until); {
+		starts = append(starts, start)
+		if monthly {
+			points = append(points, TrendPoint{Label: start.Format("Jan")})
+			start = start.AddDate(0, 1, 0)
+		} else {
+			points = append(points, TrendPoint{Label: start.Format("Jan 02")})
+			start = start.AddDate(0, 0, 7)
+		}
+	}
+
+	for _, r := range rollups {
+		i := len(starts) - 1
+		for i > 0 && r.Day.Before(starts[i]) {
+			i--
+		}
+		points[i].Success += r.Success
+		points[i].Failed += r.Failed
+	}
+	return points
+}
+
+func (g *Generator) GenerateSummary(data *SummaryData) string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("%s (%s to %s)\n", data.Title, data.Since.Format("2006-01-02"), data.Until.AddDate(0, 0, -1).Format("2006-01-02")))
+	g.writeServerText(&buf)
+
+	if data.Days == 0 {
+		buf.WriteString("No rollups for this period.\n")
+		return buf.String()
+	}
+
+	buf.WriteString(fmt.Sprintf("Days covered: %d\n", data.Days))
+	buf.WriteString(fmt.Sprintf("Successful logins: %s\n", formatNumber(data.SuccessCount)))
+	buf.WriteString(fmt.Sprintf("Failed attempts: %s\n", formatNumber(data.FailedCount)))
+	buf.WriteString(fmt.Sprintf("Peak unique IPs in a day: %s\n", formatNumber(data.PeakUniqueIPs)))
+
+	if len(data.Services) > 1 {
+		buf.WriteString("\nBy service:\n")
+		for _, ss := range data.Services {
+			buf.WriteString(fmt.Sprintf("  %-10s  %8d successful  %10d failed\n", ss.Service, ss.SuccessCount, ss.FailedCount))
+		}
+	}
+
+	buf.WriteString("\nFailed attempts:\n")
+	var peak int
+	for _, p := range data.Trend {
+		peak = max(peak, p.Failed)
+	}
+	for _, p := range data.Trend {
+		width := barWidth(p.Failed, peak, 30)
+		bar := strings.Repeat("█", width) + strings.Repeat(" ", 30-width)
+		buf.WriteString(fmt.Sprintf("  %-6s  %s  %s\n", p.Label, bar, formatNumber(p.Failed)))
+	}
+
+	if len(data.TopIPs) > 0 {
+		buf.WriteString("\nTop offenders:\n")
+		for i, ip := range data.TopIPs {
+			line := fmt.Sprintf("  %2d. %-39s  %s", i+1, ip.IP, formatNumber(ip.Count))
+			if location := ipLocation(ip); location != "" {
+				line += "  " + location
+			}
+			buf.WriteString(line + "\n")
+		}
+	}
+
+	if len(data.TopCountries) > 0 {
+		buf.WriteString("\nTop countries:\n")
+		for i, cc := range data.TopCountries {
+			buf.WriteString(fmt.Sprintf("  %2d. %s  %s\n", i+1, withFlag(cc.CountryCode, cc.Country), formatNumber(cc.Count)))
+		}
+	}
+
+	if len(data.BusiestDays) > 0 {
+		buf.WriteString("\nBusiest days:\n")
+		for _, d := range data.BusiestDays {
+			line := fmt.Sprintf("  %s  %s failed", d.Day.Format("2006-01-02"), formatNumber(d.Failed))
+			if d.TopIP.IP != "" {
+				line += fmt.Sprintf(", mostly %s (%s)", d.TopIP.IP, formatNumber(d.TopIP.Count))
+			}
+			buf.WriteString(line + "\n")
+		}
+	}
+
+	if len(data.Incidents) > 0 {
+		buf.WriteString("\nNotable incidents:\n")
+		for _, incident := range data.Incidents {
+			buf.WriteString(fmt.Sprintf("  %s  %-10s  %s\n", incidentDates(incident), incident.Kind, incident.Description))
+		}
+	}
+
+	return buf.String()
+}
+
+// incidentDates formats the days an incident spanned; an unreleased legal
+// hold has no end.
+func incidentDates(incident Incident) string {
+	start := incident.Start.Format("2006-01-02")
+	if incident.End.IsZero() {
+		return start + " – ongoing"
+	}
+	return start + " – " + incident.End.Format("2006-01-02")
+}
+
+func barWidth(n, peak, width int) int {
+	if peak == 0 {
+		return 0
+	}
+	return (n*width + peak - 1) / peak
+}
+
+var summaryHTML = template.Must(template.New("summary").Funcs(template.FuncMap{
+	"number":  formatNumber,
+	"flag":    geoip.Flag,
+	"percent": func(n, peak int) int { return barWidth(n, peak, 100) },
+	"date":    func(t time.Time) string { return t.Format("2006-01-02") },
+	"last":    func(t time.Time) string { return t.AddDate(0, 0, -1).Format("2006-01-02") },
+	"add":     func(a, b int) int { return a + b },
+	"span":    incidentDates,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Data.Title}} – {{.Data.Server}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; color: #222; }
+h1 { margin-bottom: 0; }
+.meta { color: #666; margin-top: 0.3em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5em; }
+th, td { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; }
+td.n { text-align: right; font-variant-numeric: tabular-nums; }
+.bar { background: #c0392b; height: 1em; }
+.totals td { font-size: 1.2em; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h1>{{.Data.Title}}</h1>
+<p class="meta">{{.Data.Server}}{{range .Data.Labels}} · {{.}}{{end}}<br>{{date .Data.Since}} to {{last .Data.Until}}, {{.Data.Days}} days covered</p>
+
+<table class="totals">
+<tr><td>Successful logins</td><td class="n">{{number .Data.SuccessCount}}</td></tr>
+<tr><td>Failed attempts</td><td class="n">{{number .Data.FailedCount}}</td></tr>
+<tr><td>Peak unique IPs in a day</td><td class="n">{{number .Data.PeakUniqueIPs}}</td></tr>
+</table>
+
+<h2>Failed attempts</h2>
+<table>
+{{range .Data.Trend}}<tr><td>{{.Label}}</td><td style="width:70%"><div class="bar" style="width:{{percent .Failed $.Peak}}%"></div></td><td class="n">{{number .Failed}}</td></tr>
+{{end}}</table>
+{{if gt (len .Data.Services) 1}}
+<h2>By service</h2>
+<table>
+<tr><th>Service</th><th>Successful</th><th>Failed</th></tr>
+{{range .Data.Services}}<tr><td>{{.Service}}</td><td class="n">{{number .SuccessCount}}</td><td class="n">{{number .FailedCount}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Data.TopIPs}}
+<h2>Top offenders</h2>
+<table>
+<tr><th>#</th><th>IP</th><th>Location</th><th>Attempts</th></tr>
+{{range $i, $ip := .Data.TopIPs}}<tr><td>{{add $i 1}}</td><td>{{$ip.IP}}</td><td>{{flag $ip.CountryCode}} {{$ip.Country}}</td><td class="n">{{number $ip.Count}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Data.TopCountries}}
+<h2>Top countries</h2>
+<table>
+<tr><th>#</th><th>Country</th><th>Attempts</th></tr>
+{{range $i, $cc := .Data.TopCountries}}<tr><td>{{add $i 1}}</td><td>{{flag $cc.CountryCode}} {{$cc.Country}}</td><td class="n">{{number $cc.Count}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Data.BusiestDays}}
+<h2>Busiest days</h2>
+<table>
+<tr><th>Day</th><th>Failed</th><th>Main source</th></tr>
+{{range .Data.BusiestDays}}<tr><td>{{date .Day}}</td><td class="n">{{number .Failed}}</td><td>{{with .TopIP}}{{.IP}} ({{number .Count}}){{end}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Data.Incidents}}
+<h2>Notable incidents</h2>
+<table>
+<tr><th>Dates</th><th>Kind</th><th>Description</th></tr>
+{{range .Data.Incidents}}<tr><td>{{span .}}</td><td>{{.Kind}}</td><td>{{.Description}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// WriteSummaryHTML writes the summary as a self-contained HTML page. It
+// prints cleanly, so a PDF can be made with a browser's print dialog.
+func WriteSummaryHTML(w io.Writer, data *SummaryData) error {
+	var peak int
+	for _, p := range data.Trend {
+		peak = max(peak, p.Failed)
+	}
+	return summaryHTML.Execute(w, struct {
+		Data *SummaryData
+		Peak int
+	}{data, peak})
+}
@@ -0,0 +1,116 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/config"
+	"github.com/oxisoft/oxiwatch/internal/fixture"
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+func TestParsePeriod(t *testing.T) {
+	since, until, title, err := ParsePeriod("2025q3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !since.Equal(time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)) || !until.Equal(time.Date(2025, time.October, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected bounds %v - %v", since, until)
+	}
+	if title != "Quarterly Summary 2025Q3" {
+		t.Errorf("unexpected title %q", title)
+	}
+
+	for _, bad := range []string{"", "25", "2025Q5", "2025-03"} {
+		if _, _, _, err := ParsePeriod(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	store := storage.NewMemory()
+	cn := fixture.Location("CN", "China", "Beijing")
+	start := time.Date(2025, time.July, 1, 12, 0, 0, 0, time.UTC)
+
+	// A steady trickle every day of the quarter, and one wave on 2025-08-14.
+	for day := start; day.Month() < time.October; day = day.AddDate(0, 0, 1) {
+		store.InsertEvent(fixture.Failure("root", "198.51.100.7").At(day).Build(), geoip.Location{})
+	}
+	wave := time.Date(2025, time.August, 14, 3, 0, 0, 0, time.UTC)
+	for i := 0; i < 40; i++ {
+		store.InsertEvent(fixture.Failure("admin", "203.0.113.5").At(wave.Add(time.Duration(i)*time.Minute)).Build(), cn)
+	}
+	store.InsertEvent(fixture.Success("alice", "192.0.2.1").At(start).Build(), geoip.Location{})
+
+	store.SaveCampaign(storage.Campaign{
+		ID: "C-20250814-1", ASN: 4134, ASOrg: "Chinanet", Usernames: []string{"admin"},
+		IPs: []string{"203.0.113.5", "203.0.113.6"}, Attempts: 40,
+		FirstSeen: wave, LastSeen: wave.Add(40 * time.Minute),
+	})
+	store.AddLegalHold(storage.LegalHold{IPRange: "203.0.113.0/24", Reason: "case 2025-17", CreatedAt: wave.AddDate(0, 0, 1)})
+	// Outside the quarter.
+	store.AddLegalHold(storage.LegalHold{Username: "bob", Reason: "case 2025-30", CreatedAt: time.Date(2025, time.October, 2, 0, 0, 0, 0, time.UTC)})
+
+	rolled, err := UpdateRollups(store, start, time.Date(2025, time.October, 1, 8, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rolled != 92 {
+		t.Errorf("expected 92 days rolled up, got %d", rolled)
+	}
+	// Already rolled up days are not redone.
+	if rolled, _ := UpdateRollups(store, start, time.Date(2025, time.October, 1, 9, 0, 0, 0, time.UTC)); rolled != 0 {
+		t.Errorf("expected no further rollups, got %d", rolled)
+	}
+
+	since, until, title, _ := ParsePeriod("2025Q3")
+	gen := NewGenerator(store, "web1", config.ServerMetadata{}, "")
+	data, err := gen.SummaryData(since, until, title)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Days != 92 || data.FailedCount != 92+40 || data.SuccessCount != 1 {
+		t.Errorf("unexpected totals: %d days, %d failed, %d successful", data.Days, data.FailedCount, data.SuccessCount)
+	}
+	if len(data.Trend) != 14 {
+		t.Errorf("expected weekly trend of 14 points, got %d", len(data.Trend))
+	}
+	if len(data.TopIPs) != 2 || data.TopIPs[0].IP != "198.51.100.7" || data.TopIPs[1].CountryCode != "CN" {
+		t.Errorf("unexpected top IPs: %+v", data.TopIPs)
+	}
+	if len(data.BusiestDays) == 0 || data.BusiestDays[0].Day.Format("2006-01-02") != "2025-08-14" || data.BusiestDays[0].TopIP.IP != "203.0.113.5" {
+		t.Errorf("unexpected busiest days: %+v", data.BusiestDays)
+	}
+
+	if len(data.Incidents) != 2 || data.Incidents[0].Kind != "campaign" || data.Incidents[1].Kind != "legal hold" {
+		t.Errorf("unexpected incidents: %+v", data.Incidents)
+	}
+
+	output := gen.GenerateSummary(data)
+	for _, want := range []string{
+		"Quarterly Summary 2025Q3 (2025-07-01 to 2025-09-30)\n",
+		"Failed attempts: 132\n",
+		"2025-08-14  41 failed, mostly 203.0.113.5 (40)\n",
+		"2025-08-14 – 2025-08-14  campaign    C-20250814-1: 40 attempts from 2 IPs in AS4134 Chinanet\n",
+		"2025-08-15 – ongoing  legal hold  case 2025-17 (203.0.113.0/24)\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output:\n%s", want, output)
+		}
+	}
+
+	var html bytes.Buffer
+	if err := WriteSummaryHTML(&html, data); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), "<td>203.0.113.5</td>") {
+		t.Errorf("expected top offender in HTML:\n%s", html.String())
+	}
+	if !strings.Contains(html.String(), "<td>case 2025-17 (203.0.113.0/24)</td>") {
+		t.Errorf("expected legal hold in HTML:\n%s", html.String())
+	}
+}
@@ -3,7 +3,10 @@ package scheduler
 import (
 	"context"
 	"log/slog"
+	"sync"
 	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/clock"
 )
 
 type Task func(ctx context.Context) error
@@ -13,11 +16,20 @@ type taskType int
 const (
 	taskTypeDaily taskType = iota
 	taskTypeMonthly
+	taskTypeInterval
 )
 
+// TickInterval is how often Start checks for due tasks.
+const TickInterval = 30 * time.Second
+
 type Scheduler struct {
-	logger *slog.Logger
-	tasks  []scheduledTask
+	logger        *slog.Logger
+	clock         clock.Clock
+	tasks         []scheduledTask
+	mu            sync.Mutex
+	lastCheck     time.Time
+	jumpThreshold time.Duration
+	onClockJump   func(jump time.Duration)
 }
 
 type scheduledTask struct {
@@ -28,14 +40,22 @@ type scheduledTask struct {
 	location *time.Location
 	lastRun  time.Time
 	taskType taskType
+	interval time.Duration
 }
 
 func New(logger *slog.Logger) *Scheduler {
 	return &Scheduler{
 		logger: logger,
+		clock:  clock.System{},
 	}
 }
 
+// SetClock replaces the wall clock, e.g. with a clock.Fake that Tick is
+// driven by. Call it before Start.
+func (s *Scheduler) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
 func (s *Scheduler) AddDailyTask(name string, timeStr string, timezone string, task Task) error {
 	loc, err := time.LoadLocation(timezone)
 	if err != nil {
@@ -82,43 +102,179 @@ func (s *Scheduler) AddMonthlyTask(name string, timeStr string, timezone string,
 	return nil
 }
 
+func (s *Scheduler) AddIntervalTask(name string, interval time.Duration, task Task) {
+	s.tasks = append(s.tasks, scheduledTask{
+		name:     name,
+		task:     task,
+		taskType: taskTypeInterval,
+		interval: interval,
+	})
+}
+
+// OnClockJump registers fn to be called when the wall clock moves by more
+// than threshold relative to the monotonic clock between two ticks, e.g.
+// after an NTP correction or a VM resume.
+func (s *Scheduler) OnClockJump(threshold time.Duration, fn func(jump time.Duration)) {
+	s.jumpThreshold = threshold
+	s.onClockJump = fn
+}
+
+// Resume makes the first check after Start cover the time since lastCheck,
+// so daily and monthly tasks scheduled while the daemon was restarting still
+// run once. Call it before Start.
+func (s *Scheduler) Resume(lastCheck time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastCheck = lastCheck
+}
+
+// LastCheck returns when the scheduler last looked for due tasks.
+func (s *Scheduler) LastCheck() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastCheck
+}
+
+// NextRun is when a task is due next.
+type NextRun struct {
+	Name string    `json:"name"`
+	At   time.Time `json:"at"`
+}
+
+// NextRuns returns when each task is due next after now. Interval tasks
+// that have not run yet are due on the next tick.
+func (s *Scheduler) NextRuns(now time.Time) []NextRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs := make([]NextRun, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		var at time.Time
+		switch task.taskType {
+		case taskTypeInterval:
+			at = now.Add(TickInterval)
+			if !task.lastRun.IsZero() {
+				at = task.lastRun.Add(task.interval)
+			}
+		case taskTypeDaily, taskTypeMonthly:
+			local := now.In(task.location)
+			at = time.Date(local.Year(), local.Month(), local.Day(), task.hour, task.minute, 0, 0, task.location)
+			for !at.After(now) || (task.taskType == taskTypeMonthly && !isLastDayOfMonth(at)) {
+				at = at.AddDate(0, 0, 1)
+			}
+		}
+		runs = append(runs, NextRun{Name: task.name, At: at})
+	}
+	return runs
+}
+
 func (s *Scheduler) Start(ctx context.Context) {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(TickInterval)
 	defer ticker.Stop()
 
+	s.mu.Lock()
+	if s.lastCheck.IsZero() {
+		s.lastCheck = s.clock.Now().Add(-TickInterval)
+	}
+	s.mu.Unlock()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			s.checkTasks(ctx)
+			s.Tick(ctx)
 		}
 	}
 }
 
-func (s *Scheduler) checkTasks(ctx context.Context) {
-	now := time.Now()
+// Tick runs the tasks that became due since the previous check. Start calls
+// it every TickInterval; simulations call it after advancing a fake clock.
+func (s *Scheduler) Tick(ctx context.Context) {
+	now := s.clock.Now()
+	s.mu.Lock()
+	prev := s.lastCheck
+	s.lastCheck = now
+	s.mu.Unlock()
+
+	// Sub uses the monotonic readings, Round(0) strips them to compare wall time.
+	jump := now.Round(0).Sub(prev.Round(0)) - now.Sub(prev)
+	if jump.Abs() >= time.Second {
+		s.logger.Warn("system clock jumped", "jump", jump)
+	}
+	if s.onClockJump != nil && s.jumpThreshold > 0 && jump.Abs() >= s.jumpThreshold {
+		s.onClockJump(jump)
+	}
 
 	for i := range s.tasks {
 		task := &s.tasks[i]
-		localNow := now.In(task.location)
-
-		if localNow.Hour() == task.hour && localNow.Minute() == task.minute {
-			today := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), 0, 0, 0, 0, task.location)
-			if task.lastRun.Before(today) {
-				if task.taskType == taskTypeMonthly && !isLastDayOfMonth(localNow) {
-					continue
-				}
-
-				s.logger.Info("running scheduled task", "name", task.name)
-				if err := task.task(ctx); err != nil {
-					s.logger.Error("scheduled task failed", "name", task.name, "error", err)
-				} else {
-					s.logger.Info("scheduled task completed", "name", task.name)
-				}
-				task.lastRun = now
+
+		if task.taskType == taskTypeInterval {
+			if task.lastRun.IsZero() || now.Sub(task.lastRun) >= task.interval {
+				s.runTask(ctx, task)
+				s.setLastRun(task, now)
 			}
+			continue
+		}
+
+		// Daily and monthly tasks run when their scheduled time falls between
+		// the previous check and now in wall time. A forward jump therefore
+		// catches up on a skipped run once; a backward jump never repeats one
+		// because the occurrence must also be later than the last run.
+		occurrence, ok := occurrenceBetween(prev, now, task.hour, task.minute, task.location)
+		if !ok || !task.lastRun.Round(0).Before(occurrence) {
+			continue
+		}
+		if task.taskType == taskTypeMonthly && !isLastDayOfMonth(occurrence) {
+			continue
+		}
+
+		s.runTask(ctx, task)
+		s.setLastRun(task, now)
+	}
+}
+
+// occurrenceBetween returns the latest hour:minute in loc that lies in the
+// wall-clock interval (from, to].
+func occurrenceBetween(from, to time.Time, hour, minute int, loc *time.Location) (time.Time, bool) {
+	from = from.Round(0).In(loc)
+	to = to.Round(0).In(loc)
+	if !to.After(from) {
+		return time.Time{}, false
+	}
+
+	day := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc)
+	for {
+		t := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+		if !t.After(from) {
+			return time.Time{}, false
+		}
+		if !t.After(to) {
+			return t, true
 		}
+		day = day.AddDate(0, 0, -1)
+	}
+}
+
+// setLastRun is locked for NextRuns; Tick itself is the only writer.
+func (s *Scheduler) setLastRun(task *scheduledTask, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task.lastRun = t
+}
+
+// runTask logs interval tasks, which run every few minutes or on every
+// tick, at debug level so they do not flood the log of an idle host.
+func (s *Scheduler) runTask(ctx context.Context, task *scheduledTask) {
+	level := slog.LevelInfo
+	if task.taskType == taskTypeInterval {
+		level = slog.LevelDebug
+	}
+	s.logger.Log(ctx, level, "running scheduled task", "name", task.name)
+	if err := task.task(ctx); err != nil {
+		s.logger.Error("scheduled task failed", "name", task.name, "error", err)
+	} else {
+		s.logger.Log(ctx, level, "scheduled task completed", "name", task.name)
 	}
 }
 
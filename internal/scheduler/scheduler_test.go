@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/clock"
+)
+
+func TestOccurrenceBetween(t *testing.T) {
+	at := func(day, hour, minute, sec int) time.Time {
+		return time.Date(2026, time.March, day, hour, minute, sec, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		expected time.Time
+		ok       bool
+	}{
+		{"regular tick", at(10, 7, 59, 45), at(10, 8, 0, 15), at(10, 8, 0, 0), true},
+		{"not yet", at(10, 7, 58, 0), at(10, 7, 58, 30), time.Time{}, false},
+		{"already passed", at(10, 8, 0, 15), at(10, 8, 0, 45), time.Time{}, false},
+		{"forward jump over schedule", at(10, 6, 0, 0), at(10, 11, 0, 0), at(10, 8, 0, 0), true},
+		{"forward jump over midnight", at(10, 23, 0, 0), at(11, 7, 0, 0), time.Time{}, false},
+		{"forward jump over several days", at(8, 12, 0, 0), at(11, 7, 0, 0), at(10, 8, 0, 0), true},
+		{"backward jump", at(10, 9, 0, 0), at(10, 7, 0, 0), time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := occurrenceBetween(tt.from, tt.to, 8, 0, time.UTC)
+		if ok != tt.ok || !got.Equal(tt.expected) {
+			t.Errorf("%s: expected (%v, %v), got (%v, %v)", tt.name, tt.expected, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestResumeRunsMissedTask(t *testing.T) {
+	now := time.Now().UTC()
+	s := New(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var runs int
+	err := s.AddDailyTask("report", now.Add(-10*time.Minute).Format("15:04"), "UTC", func(ctx context.Context) error {
+		runs++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Resume(now.Add(-30 * time.Minute))
+	s.Tick(context.Background())
+	s.Tick(context.Background())
+	if runs != 1 {
+		t.Errorf("expected the task missed during the restart to run once, ran %d times", runs)
+	}
+}
+
+func TestTickFollowsClock(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 1, 30, 23, 0, 0, 0, time.UTC))
+	s := New(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.SetClock(clk)
+
+	var checks, monthly int
+	s.AddIntervalTask("check", 5*time.Minute, func(ctx context.Context) error {
+		checks++
+		return nil
+	})
+	err := s.AddMonthlyTask("update", "04:00", "UTC", func(ctx context.Context) error {
+		monthly++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Resume(clk.Now())
+	for i := 0; i < 2*24*60*2; i++ {
+		clk.Advance(TickInterval)
+		s.Tick(context.Background())
+	}
+
+	// 48 hours of checks every 5 minutes, and the monthly task on Jan 31.
+	if checks != 48*12 {
+		t.Errorf("expected %d interval runs, got %d", 48*12, checks)
+	}
+	if monthly != 1 {
+		t.Errorf("expected the monthly task to run once, ran %d times", monthly)
+	}
+}
+
+func TestIntervalTasksLogAtDebug(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 1, 30, 3, 59, 0, 0, time.UTC))
+	var buf bytes.Buffer
+	s := New(slog.New(slog.NewTextHandler(&buf, nil)))
+	s.SetClock(clk)
+
+	s.AddIntervalTask("check", time.Minute, func(ctx context.Context) error { return nil })
+	if err := s.AddDailyTask("report", "04:00", "UTC", func(ctx context.Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Resume(clk.Now())
+	for i := 0; i < 4; i++ {
+		clk.Advance(TickInterval)
+		s.Tick(context.Background())
+	}
+
+	if strings.Contains(buf.String(), "name=check") {
+		t.Errorf("expected interval task runs below info level, got:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "msg=\"scheduled task completed\" name=report") {
+		t.Errorf("expected the daily task run at info level, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,940 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+	"github.com/oxisoft/oxiwatch/internal/parser"
+)
+
+// Memory is an in-memory Store for tests. It mirrors the queries of the
+// SQLite Storage closely enough for daemon, detection and report tests, but
+// keeps nothing on disk.
+type Memory struct {
+	mu          sync.Mutex
+	events      []memEvent
+	hashes      map[string]bool
+	aggregates  map[aggregateKey]*memAggregate
+	comparisons map[string]memComparison
+	rollups     map[string]memRollup
+	keys        map[keyUsageKey]*KeyUsage
+	holds       []LegalHold
+	trusted     []TrustedNetwork
+	campaigns   map[string]Campaign
+	state       map[string]string
+	nextID      int64
+}
+
+type memEvent struct {
+	SSHEventRecord
+	Continent string
+	ASN       uint
+	ASOrg     string
+}
+
+type aggregateKey struct {
+	hour    time.Time
+	service string
+	ip      string
+}
+
+type memAggregate struct {
+	country string
+	city    string
+	count   int
+}
+
+type keyUsageKey struct {
+	fingerprint string
+	username    string
+	ip          string
+}
+
+type memRollup struct {
+	services  []DailyRollup
+	ips       []IPCount
+	countries []CountryCount
+}
+
+type memComparison struct {
+	GeoIPDisagreement
+	seen time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		hashes:      make(map[string]bool),
+		aggregates:  make(map[aggregateKey]*memAggregate),
+		comparisons: make(map[string]memComparison),
+		rollups:     make(map[string]memRollup),
+		keys:        make(map[keyUsageKey]*KeyUsage),
+		campaigns:   make(map[string]Campaign),
+		state:       make(map[string]string),
+	}
+}
+
+func (m *Memory) InsertEvent(event *parser.SSHEvent, loc geoip.Location) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hash := event.Hash()
+	if m.hashes[hash] {
+		return false, nil
+	}
+	m.hashes[hash] = true
+
+	service := event.Service
+	if service == "" {
+		service = parser.ServiceSSH
+	}
+
+	m.nextID++
+	m.events = append(m.events, memEvent{
+		SSHEventRecord: SSHEventRecord{
+			ID:            m.nextID,
+			Timestamp:     event.Timestamp,
+			EventType:     string(event.EventType),
+			Service:       service,
+			Username:      event.Username,
+			IP:            event.IP,
+			Port:          event.Port,
+			Method:        event.Method,
+			Country:       loc.Country,
+			CountryCode:   loc.CountryCode,
+			ContinentCode: loc.ContinentCode,
+			City:          loc.City,
+			InvalidUser:   event.InvalidUser,
+			MFA:           event.MFA,
+			AuthSource:    event.AuthSource,
+			CreatedAt:     time.Now(),
+		},
+		Continent: loc.Continent,
+		ASN:       loc.ASN,
+		ASOrg:     loc.ASOrg,
+	})
+
+	if event.EventType == parser.EventSuccess && event.Fingerprint != "" {
+		m.recordKeyUsage(event, loc)
+	}
+	return true, nil
+}
+
+func (m *Memory) recordKeyUsage(event *parser.SSHEvent, loc geoip.Location) {
+	key := keyUsageKey{event.Fingerprint, event.Username, event.IP}
+	k, ok := m.keys[key]
+	if !ok {
+		k = &KeyUsage{
+			Fingerprint: event.Fingerprint,
+			Username:    event.Username,
+			IP:          event.IP,
+			FirstSeen:   event.Timestamp,
+			LastSeen:    event.Timestamp,
+		}
+		m.keys[key] = k
+	}
+	if event.KeyType != "" {
+		k.KeyType = event.KeyType
+	}
+	if loc.Country != "" {
+		k.Country, k.CountryCode, k.City = loc.Country, loc.CountryCode, loc.City
+	}
+	if event.Timestamp.Before(k.FirstSeen) {
+		k.FirstSeen = event.Timestamp
+	}
+	if event.Timestamp.After(k.LastSeen) {
+		k.LastSeen = event.Timestamp
+	}
+	k.Logins++
+}
+
+func (m *Memory) AddFailureAggregate(event *parser.SSHEvent, loc geoip.Location) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	service := event.Service
+	if service == "" {
+		service = parser.ServiceSSH
+	}
+
+	key := aggregateKey{event.Timestamp.Truncate(time.Hour), service, event.IP}
+	if a, ok := m.aggregates[key]; ok {
+		a.count++
+		return nil
+	}
+	m.aggregates[key] = &memAggregate{country: loc.Country, city: loc.City, count: 1}
+	return nil
+}
+
+func (m *Memory) AddFailureAggregates(aggregates []FailureAggregate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, agg := range aggregates {
+		key := aggregateKey{agg.Hour.Truncate(time.Hour), agg.Service, agg.IP}
+		if a, ok := m.aggregates[key]; ok {
+			a.count += agg.Count
+			continue
+		}
+		m.aggregates[key] = &memAggregate{country: agg.Country, city: agg.City, count: agg.Count}
+	}
+	return nil
+}
+
+func (m *Memory) GetAggregatedFailures(since, until time.Time, service string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	since = since.Truncate(time.Hour)
+	var count int
+	for key, a := range m.aggregates {
+		if !key.hour.Before(since) && key.hour.Before(until) && (service == "" || key.service == service) {
+			count += a.count
+		}
+	}
+	return count, nil
+}
+
+func (m *Memory) GetSuccessfulLogins(since time.Time, filter EventFilter) ([]SSHEventRecord, error) {
+	return m.getEvents("success", since, filter)
+}
+
+func (m *Memory) GetLastLoginForUser(service, username string) (*SSHEventRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var last *SSHEventRecord
+	for i := range m.events {
+		e := &m.events[i].SSHEventRecord
+		if e.EventType != "success" || e.Service != service || e.Username != username {
+			continue
+		}
+		if last == nil || e.Timestamp.After(last.Timestamp) {
+			last = e
+		}
+	}
+	if last == nil {
+		return nil, sql.ErrNoRows
+	}
+	record := *last
+	return &record, nil
+}
+
+func (m *Memory) GetFailedAttempts(since time.Time, filter EventFilter) ([]SSHEventRecord, error) {
+	return m.getEvents("failure", since, filter)
+}
+
+func (m *Memory) getEvents(eventType string, since time.Time, filter EventFilter) ([]SSHEventRecord, error) {
+	country := strings.ToUpper(filter.CountryCode)
+	continent := strings.ToUpper(filter.ContinentCode)
+
+	var events []SSHEventRecord
+	for _, e := range m.matching(eventType, since, time.Time{}, filter.Service) {
+		if (country == "" || e.CountryCode == country) && (continent == "" || e.ContinentCode == continent) &&
+			(filter.IP == "" || e.IP == filter.IP) && (filter.Username == "" || e.Username == filter.Username) {
+			events = append(events, e.SSHEventRecord)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.After(events[j].Timestamp)
+	})
+	return events, nil
+}
+
+func (m *Memory) GetFailedStats(since, until time.Time, service string) (*Stats, error) {
+	events := m.matching("failure", since, until, service)
+	return &Stats{
+		TotalAttempts:   len(events),
+		UniqueIPs:       countDistinct(events, func(e memEvent) string { return e.IP }),
+		UniqueUsernames: countDistinct(events, func(e memEvent) string { return e.Username }),
+	}, nil
+}
+
+func (m *Memory) GetTopUsernames(since, until time.Time, limit int, service string) ([]UsernameCount, error) {
+	var results []UsernameCount
+	for _, g := range groupEvents(m.matching("failure", since, until, service), func(e memEvent) string { return e.Username }) {
+		if g.key != "" {
+			results = append(results, UsernameCount{Username: g.key, Count: len(g.events)})
+		}
+	}
+	return limitResults(results, limit), nil
+}
+
+func (m *Memory) GetTopIPs(since, until time.Time, limit int, service string) ([]IPCount, error) {
+	var results []IPCount
+	for _, g := range groupEvents(m.matching("failure", since, until, service), func(e memEvent) string { return e.IP }) {
+		first := g.events[0]
+		ic := IPCount{IP: g.key, Country: first.Country, City: first.City, Count: len(g.events)}
+		for _, e := range g.events {
+			ic.CountryCode = max(ic.CountryCode, e.CountryCode)
+			ic.ASN = max(ic.ASN, e.ASN)
+			ic.ASOrg = max(ic.ASOrg, e.ASOrg)
+		}
+		results = append(results, ic)
+	}
+	return limitResults(results, limit), nil
+}
+
+func (m *Memory) GetTopASNs(since, until time.Time, limit int) ([]ASNCount, error) {
+	var withASN []memEvent
+	for _, e := range m.matching("failure", since, until, "") {
+		if e.ASN != 0 {
+			withASN = append(withASN, e)
+		}
+	}
+
+	var results []ASNCount
+	for _, g := range groupEvents(withASN, func(e memEvent) uint { return e.ASN }) {
+		ac := ASNCount{ASN: g.key, Count: len(g.events)}
+		for _, e := range g.events {
+			ac.ASOrg = max(ac.ASOrg, e.ASOrg)
+		}
+		ac.UniqueIPs = countDistinct(g.events, func(e memEvent) string { return e.IP })
+		results = append(results, ac)
+	}
+	return limitResults(results, limit), nil
+}
+
+func (m *Memory) GetTopCountries(since, until time.Time, limit int) ([]CountryCount, error) {
+	var withCountry []memEvent
+	for _, e := range m.matching("failure", since, until, "") {
+		if e.Country != "" {
+			withCountry = append(withCountry, e)
+		}
+	}
+
+	var results []CountryCount
+	for _, g := range groupEvents(withCountry, func(e memEvent) string { return e.Country }) {
+		cc := CountryCount{Country: g.key, Count: len(g.events)}
+		for _, e := range g.events {
+			cc.CountryCode = max(cc.CountryCode, e.CountryCode)
+		}
+		cc.UniqueIPs = countDistinct(g.events, func(e memEvent) string { return e.IP })
+		results = append(results, cc)
+	}
+	return limitResults(results, limit), nil
+}
+
+func (m *Memory) GetIPHistory(ip string, since time.Time) (*IPHistory, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var h IPHistory
+	for _, e := range m.events {
+		if e.IP != ip {
+			continue
+		}
+		if !e.Timestamp.Before(since) {
+			h.Count++
+		}
+		if h.FirstSeen.IsZero() || e.Timestamp.Before(h.FirstSeen) {
+			h.FirstSeen = e.Timestamp
+		}
+	}
+	return &h, nil
+}
+
+func (m *Memory) GetIPsToEnrich(since time.Time, missingOnly, withASN bool) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var ips []string
+	for _, e := range m.events {
+		if e.Timestamp.Before(since) || seen[e.IP] {
+			continue
+		}
+		if missingOnly && e.CountryCode != "" && (!withASN || e.ASN != 0) {
+			continue
+		}
+		seen[e.IP] = true
+		ips = append(ips, e.IP)
+	}
+	return ips, nil
+}
+
+func (m *Memory) UpdateLocation(ip string, since time.Time, loc geoip.Location) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	for i := range m.events {
+		e := &m.events[i]
+		if e.IP != ip || e.Timestamp.Before(since) {
+			continue
+		}
+		e.Country = loc.Country
+		e.CountryCode = loc.CountryCode
+		e.Continent = loc.Continent
+		e.ContinentCode = loc.ContinentCode
+		e.City = loc.City
+		e.ASN = loc.ASN
+		e.ASOrg = loc.ASOrg
+		n++
+	}
+
+	since = since.Truncate(time.Hour)
+	for key, a := range m.aggregates {
+		if key.ip == ip && !key.hour.Before(since) {
+			a.country = loc.Country
+			a.city = loc.City
+		}
+	}
+	return n, nil
+}
+
+func (m *Memory) AddGeoIPComparison(ip string, seen time.Time, a, b geoip.Location) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.comparisons[ip]; ok {
+		return nil
+	}
+	m.comparisons[ip] = memComparison{
+		GeoIPDisagreement: GeoIPDisagreement{
+			IP:           ip,
+			CountryCodeA: a.CountryCode,
+			CityA:        a.City,
+			CountryCodeB: b.CountryCode,
+			CityB:        b.City,
+		},
+		seen: seen,
+	}
+	return nil
+}
+
+func (m *Memory) GetGeoIPComparison(examples int) (*GeoIPComparison, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var c GeoIPComparison
+	var disagreements []memComparison
+	for _, cmp := range m.comparisons {
+		c.Samples++
+		if c.First.IsZero() || cmp.seen.Before(c.First) {
+			c.First = cmp.seen
+		}
+		if cmp.seen.After(c.Last) {
+			c.Last = cmp.seen
+		}
+
+		a, b := cmp.CountryCodeA, cmp.CountryCodeB
+		switch {
+		case a == "" && b == "":
+			c.MissingA++
+			c.MissingB++
+		case a == "":
+			c.MissingA++
+		case b == "":
+			c.MissingB++
+		case a != b:
+			c.CountryMismatch++
+			disagreements = append(disagreements, cmp)
+		case cmp.CityA != "" && cmp.CityB != "" && cmp.CityA != cmp.CityB:
+			c.CityMismatch++
+		}
+	}
+
+	sort.Slice(disagreements, func(i, j int) bool {
+		return disagreements[i].seen.After(disagreements[j].seen)
+	})
+	for i := 0; i < len(disagreements) && i < examples; i++ {
+		c.Examples = append(c.Examples, disagreements[i].GeoIPDisagreement)
+	}
+	return &c, nil
+}
+
+func (m *Memory) GetSuccessCount(since, until time.Time, service string) (int, error) {
+	return len(m.matching("success", since, until, service)), nil
+}
+
+func (m *Memory) GetServiceStats(since, until time.Time) ([]ServiceStats, error) {
+	var results []ServiceStats
+	for _, g := range groupEvents(m.matching("", since, until, ""), func(e memEvent) string { return e.Service }) {
+		ss := ServiceStats{Service: g.key}
+		for _, e := range g.events {
+			switch e.EventType {
+			case "success":
+				ss.SuccessCount++
+			case "failure":
+				ss.FailedCount++
+			}
+		}
+		ss.UniqueIPs = countDistinct(g.events, func(e memEvent) string { return e.IP })
+		results = append(results, ss)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if (results[i].Service == parser.ServiceSSH) != (results[j].Service == parser.ServiceSSH) {
+			return results[i].Service == parser.ServiceSSH
+		}
+		return results[i].FailedCount > results[j].FailedCount
+	})
+	return results, nil
+}
+
+func (m *Memory) GetOverallStats(since time.Time, service string) (*OverallStats, error) {
+	events := m.matching("", since, time.Time{}, service)
+	var stats OverallStats
+	for _, e := range events {
+		switch e.EventType {
+		case "success":
+			stats.SuccessCount++
+		case "failure":
+			stats.FailedCount++
+		}
+	}
+	stats.UniqueIPs = countDistinct(events, func(e memEvent) string { return e.IP })
+	stats.UniqueUsernames = countDistinct(events, func(e memEvent) string { return e.Username })
+	return &stats, nil
+}
+
+func (m *Memory) GetKeyUsage(fingerprint, username string) ([]KeyUsage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var results []KeyUsage
+	for _, k := range m.keys {
+		if (fingerprint == "" || k.Fingerprint == fingerprint) && (username == "" || k.Username == username) {
+			results = append(results, *k)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Fingerprint != b.Fingerprint {
+			return a.Fingerprint < b.Fingerprint
+		}
+		if a.Username != b.Username {
+			return a.Username < b.Username
+		}
+		return a.LastSeen.After(b.LastSeen)
+	})
+	return results, nil
+}
+
+func (m *Memory) Rollup(day time.Time) error {
+	start := rollupDay(day)
+	end := start.Add(24 * time.Hour)
+	events := m.matching("", start, end, "")
+
+	// Aggregated failures count as events of their hour, like in SQLite.
+	m.mu.Lock()
+	for key, a := range m.aggregates {
+		if !key.hour.Before(start) && key.hour.Before(end) {
+			for i := 0; i < a.count; i++ {
+				events = append(events, memEvent{SSHEventRecord: SSHEventRecord{
+					EventType: "failure",
+					Service:   key.service,
+					IP:        key.ip,
+					Country:   a.country,
+				}})
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	var rollup memRollup
+	for _, g := range groupEvents(events, func(e memEvent) string { return e.Service }) {
+		r := DailyRollup{Day: start, Service: g.key}
+		for _, e := range g.events {
+			switch e.EventType {
+			case "success":
+				r.Success++
+			case "failure":
+				r.Failed++
+			}
+		}
+		r.UniqueIPs = countDistinct(g.events, func(e memEvent) string { return e.IP })
+		rollup.services = append(rollup.services, r)
+	}
+	sort.Slice(rollup.services, func(i, j int) bool {
+		return rollup.services[i].Service < rollup.services[j].Service
+	})
+
+	var failures []memEvent
+	for _, e := range events {
+		if e.EventType == "failure" {
+			failures = append(failures, e)
+		}
+	}
+	for _, g := range groupEvents(failures, func(e memEvent) string { return e.IP }) {
+		ic := IPCount{IP: g.key, Count: len(g.events)}
+		for _, e := range g.events {
+			ic.Country = max(ic.Country, e.Country)
+			ic.CountryCode = max(ic.CountryCode, e.CountryCode)
+		}
+		rollup.ips = append(rollup.ips, ic)
+	}
+	rollup.ips = limitResults(rollup.ips, RollupIPsPerDay)
+	for _, g := range groupEvents(failures, func(e memEvent) string { return e.Country }) {
+		if g.key == "" {
+			continue
+		}
+		cc := CountryCount{Country: g.key, Count: len(g.events)}
+		for _, e := range g.events {
+			cc.CountryCode = max(cc.CountryCode, e.CountryCode)
+		}
+		rollup.countries = append(rollup.countries, cc)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollups[rollupKey(day)] = rollup
+	return nil
+}
+
+func (m *Memory) GetDailyRollups(since, until time.Time) ([]DailyRollup, error) {
+	var results []DailyRollup
+	for _, r := range m.rollupsBetween(since, until) {
+		results = append(results, r.services...)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Day.Before(results[j].Day)
+	})
+	return results, nil
+}
+
+func (m *Memory) GetRollupTopIPs(since, until time.Time, limit int) ([]IPCount, error) {
+	totals := make(map[string]*IPCount)
+	for _, r := range m.rollupsBetween(since, until) {
+		for _, ic := range r.ips {
+			t, ok := totals[ic.IP]
+			if !ok {
+				t = &IPCount{IP: ic.IP}
+				totals[ic.IP] = t
+			}
+			t.Country = max(t.Country, ic.Country)
+			t.CountryCode = max(t.CountryCode, ic.CountryCode)
+			t.Count += ic.Count
+		}
+	}
+
+	var results []IPCount
+	for _, t := range totals {
+		results = append(results, *t)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Count != results[j].Count {
+			return results[i].Count > results[j].Count
+		}
+		return results[i].IP < results[j].IP
+	})
+	return limitResults(results, limit), nil
+}
+
+func (m *Memory) GetRollupTopCountries(since, until time.Time, limit int) ([]CountryCount, error) {
+	totals := make(map[string]*CountryCount)
+	for _, r := range m.rollupsBetween(since, until) {
+		for _, cc := range r.countries {
+			t, ok := totals[cc.Country]
+			if !ok {
+				t = &CountryCount{Country: cc.Country}
+				totals[cc.Country] = t
+			}
+			t.CountryCode = max(t.CountryCode, cc.CountryCode)
+			t.Count += cc.Count
+		}
+	}
+
+	var results []CountryCount
+	for _, t := range totals {
+		results = append(results, *t)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Count != results[j].Count {
+			return results[i].Count > results[j].Count
+		}
+		return results[i].Country < results[j].Country
+	})
+	return limitResults(results, limit), nil
+}
+
+func (m *Memory) rollupsBetween(since, until time.Time) []memRollup {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	from, to := rollupKey(since), rollupKey(until)
+	var rollups []memRollup
+	for day, r := range m.rollups {
+		if day >= from && day < to {
+			rollups = append(rollups, r)
+		}
+	}
+	return rollups
+}
+
+func (m *Memory) GetState(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state[key], nil
+}
+
+func (m *Memory) SetState(key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state[key] = value
+	return nil
+}
+
+func (m *Memory) Cleanup(now time.Time, retentionDays int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cutoff := now.AddDate(0, 0, -retentionDays)
+	for id, c := range m.campaigns {
+		if c.LastSeen.Before(cutoff) {
+			delete(m.campaigns, id)
+		}
+	}
+	holds := m.activeHolds()
+	kept := m.events[:0]
+	var deleted int64
+	for _, e := range m.events {
+		if e.Timestamp.Before(cutoff) && !heldBy(holds, e.IP, e.Username, e.Timestamp) {
+			deleted++
+			continue
+		}
+		kept = append(kept, e)
+	}
+	m.events = kept
+
+	for key := range m.aggregates {
+		if key.hour.Before(cutoff) && !heldBy(holds, key.ip, "", key.hour) {
+			delete(m.aggregates, key)
+		}
+	}
+	return deleted, nil
+}
+
+func (m *Memory) AddLegalHold(h LegalHold) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h.ID = int64(len(m.holds) + 1)
+	m.holds = append(m.holds, h)
+	return h.ID, nil
+}
+
+func (m *Memory) GetLegalHolds(includeReleased bool) ([]LegalHold, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if includeReleased {
+		return append([]LegalHold(nil), m.holds...), nil
+	}
+	return m.activeHolds(), nil
+}
+
+func (m *Memory) activeHolds() []LegalHold {
+	var holds []LegalHold
+	for _, h := range m.holds {
+		if h.ReleasedAt.IsZero() {
+			holds = append(holds, h)
+		}
+	}
+	return holds
+}
+
+func (m *Memory) ReleaseLegalHold(id int64, at time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.holds {
+		if m.holds[i].ID == id && m.holds[i].ReleasedAt.IsZero() {
+			m.holds[i].ReleasedAt = at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *Memory) GetIPUserAttempts(since, until time.Time) ([]IPUserAttempts, error) {
+	var results []IPUserAttempts
+	index := make(map[string]int)
+	for _, e := range m.matching("failure", since, until, "") {
+		if e.ASN == 0 {
+			continue
+		}
+		key := fmt.Sprintf("%d|%s|%s", e.ASN, e.IP, e.Username)
+		i, ok := index[key]
+		if !ok {
+			i = len(results)
+			index[key] = i
+			results = append(results, IPUserAttempts{ASN: e.ASN, IP: e.IP, Username: e.Username, First: e.Timestamp, Last: e.Timestamp})
+		}
+		a := &results[i]
+		a.Count++
+		a.ASOrg = max(a.ASOrg, e.ASOrg)
+		if e.Timestamp.Before(a.First) {
+			a.First = e.Timestamp
+		}
+		if e.Timestamp.After(a.Last) {
+			a.Last = e.Timestamp
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.ASN != b.ASN {
+			return a.ASN < b.ASN
+		}
+		if a.IP != b.IP {
+			return a.IP < b.IP
+		}
+		return a.Username < b.Username
+	})
+	return results, nil
+}
+
+func (m *Memory) SaveCampaign(c Campaign) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c.Usernames = append([]string(nil), c.Usernames...)
+	c.IPs = append([]string(nil), c.IPs...)
+	m.campaigns[c.ID] = c
+	return nil
+}
+
+func (m *Memory) GetCampaigns(since time.Time) ([]Campaign, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var campaigns []Campaign
+	for _, c := range m.campaigns {
+		if !c.LastSeen.Before(since) {
+			campaigns = append(campaigns, c)
+		}
+	}
+	sort.Slice(campaigns, func(i, j int) bool {
+		if !campaigns[i].FirstSeen.Equal(campaigns[j].FirstSeen) {
+			return campaigns[i].FirstSeen.After(campaigns[j].FirstSeen)
+		}
+		return campaigns[i].ID < campaigns[j].ID
+	})
+	return campaigns, nil
+}
+
+func (m *Memory) TagCampaignEvents(id string, asn uint, ips []string, since, until time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var tagged int64
+	for i := range m.events {
+		e := &m.events[i]
+		if e.EventType == "failure" && e.ASN == asn && e.CampaignID == "" &&
+			!e.Timestamp.Before(since) && e.Timestamp.Before(until) && slices.Contains(ips, e.IP) {
+			e.CampaignID = id
+			tagged++
+		}
+	}
+	return tagged, nil
+}
+
+func (m *Memory) AddTrustedNetwork(n TrustedNetwork) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.trusted {
+		if t.IPRange == n.IPRange {
+			return false, nil
+		}
+	}
+	m.trusted = append(m.trusted, n)
+	return true, nil
+}
+
+func (m *Memory) GetTrustedNetworks() ([]TrustedNetwork, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	networks := append([]TrustedNetwork(nil), m.trusted...)
+	sort.SliceStable(networks, func(i, j int) bool {
+		if !networks[i].ApprovedAt.Equal(networks[j].ApprovedAt) {
+			return networks[i].ApprovedAt.Before(networks[j].ApprovedAt)
+		}
+		return networks[i].IPRange < networks[j].IPRange
+	})
+	return networks, nil
+}
+
+func (m *Memory) RemoveTrustedNetwork(ipRange string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, t := range m.trusted {
+		if t.IPRange == ipRange {
+			m.trusted = append(m.trusted[:i], m.trusted[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// matching returns the events of the given type (any type if empty) and
+// service (any service if empty) in [since, until), in insertion order. A
+// zero until means no upper bound.
+func (m *Memory) matching(eventType string, since, until time.Time, service string) []memEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var events []memEvent
+	for _, e := range m.events {
+		if (eventType == "" || e.EventType == eventType) &&
+			(service == "" || e.Service == service) &&
+			!e.Timestamp.Before(since) &&
+			(until.IsZero() || e.Timestamp.Before(until)) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+type eventGroup[K comparable] struct {
+	key    K
+	events []memEvent
+}
+
+// groupEvents groups events by key, largest group first; ties keep the order
+// in which the keys were first seen.
+func groupEvents[K comparable](events []memEvent, key func(memEvent) K) []eventGroup[K] {
+	index := make(map[K]int)
+	var groups []eventGroup[K]
+	for _, e := range events {
+		k := key(e)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, eventGroup[K]{key: k})
+		}
+		groups[i].events = append(groups[i].events, e)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].events) > len(groups[j].events)
+	})
+	return groups
+}
+
+func countDistinct(events []memEvent, key func(memEvent) string) int {
+	seen := make(map[string]bool)
+	for _, e := range events {
+		seen[key(e)] = true
+	}
+	return len(seen)
+}
+
+func limitResults[T any](results []T, limit int) []T {
+	if limit >= 0 && len(results) > limit {
+		return results[:limit]
+	}
+	return results
+}
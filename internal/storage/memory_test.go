@@ -0,0 +1,456 @@
+package storage_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/fixture"
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+	"github.com/oxisoft/oxiwatch/internal/parser"
+	"github.com/oxisoft/oxiwatch/internal/storage"
+)
+
+// seed stores the same scenario in any Store. Counts per IP, user, network
+// and country are distinct so the ordering of top lists is well defined.
+func seed(t *testing.T, s storage.Store) time.Time {
+	t.Helper()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	de := fixture.Location("DE", "Germany", "Berlin")
+	de.ASN, de.ASOrg = 3320, "Deutsche Telekom"
+	cn := fixture.Location("CN", "China", "Beijing")
+	cn.ASN, cn.ASOrg = 4134, "Chinanet"
+
+	insert := func(b *fixture.EventBuilder, loc geoip.Location) {
+		if _, err := s.InsertEvent(b.Build(), loc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		insert(fixture.Failure("root", "203.0.113.5").At(now.Add(-time.Duration(i)*time.Minute)), cn)
+	}
+	for i := 0; i < 3; i++ {
+		insert(fixture.Failure("admin", "198.51.100.7").At(now.Add(-time.Duration(i)*time.Minute)), de)
+	}
+	insert(fixture.Failure("guest", "192.0.2.1").At(now.Add(-2*time.Hour)).Service("web"), geoip.Location{})
+	insert(fixture.Failure("old", "192.0.2.9").At(now.AddDate(0, 0, -100)), cn)
+	insert(fixture.Success("alice", "198.51.100.7").At(now.Add(-time.Hour)).MFA(), de)
+	insert(fixture.Success("deploy", "198.51.100.7").At(now.Add(-3*time.Hour)).PublicKey("ED25519", "SHA256:deploykey"), de)
+	insert(fixture.Success("deploy", "198.51.100.7").At(now.Add(-2*time.Hour)).PublicKey("ED25519", "SHA256:deploykey"), geoip.Location{})
+	insert(fixture.Success("root", "192.0.2.1").At(now.Add(-4*time.Hour)).PublicKey("ED25519", "SHA256:deploykey"), geoip.Location{})
+	insert(fixture.Success("alice", "192.0.2.1").At(now.Add(-30*time.Minute)).Service("smb"), geoip.Location{})
+
+	if err := s.AddFailureAggregate(fixture.Failure("root", "203.0.113.5").At(now).Build(), cn); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddFailureAggregate(fixture.Failure("root", "203.0.113.5").At(now).Build(), cn); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddFailureAggregates([]storage.FailureAggregate{
+		{Hour: now, Service: "ssh", IP: "203.0.113.5", Count: 3},
+		{Hour: now.Add(-time.Hour), Service: "ssh", IP: "192.0.2.1", Count: 4},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return now
+}
+
+type snapshot struct {
+	Duplicate    bool
+	Aggregated   int
+	Logins       []storage.SSHEventRecord
+	Failures     []storage.SSHEventRecord
+	LastLogin    storage.SSHEventRecord
+	FailedStats  storage.Stats
+	TopUsers     []storage.UsernameCount
+	TopIPs       []storage.IPCount
+	TopASNs      []storage.ASNCount
+	TopCountries []storage.CountryCount
+	History      storage.IPHistory
+	ToEnrich     []string
+	Success      int
+	Services     []storage.ServiceStats
+	Overall      storage.OverallStats
+	Rollups      []storage.DailyRollup
+	RollupIPs    []storage.IPCount
+	RollupCC     []storage.CountryCount
+	Keys         []storage.KeyUsage
+	Deleted      int64
+	AfterCleanup storage.OverallStats
+}
+
+func take(t *testing.T, s storage.Store, now time.Time) snapshot {
+	t.Helper()
+	since := now.Add(-24 * time.Hour)
+	// until is exclusive: the failures and the duplicate check at now are
+	// left out of the bounded queries.
+	until := now
+	var snap snapshot
+	var err error
+	check := func(e error) {
+		t.Helper()
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	inserted, err := s.InsertEvent(fixture.Failure("root", "203.0.113.5").At(now).Port(22).Build(), geoip.Location{})
+	check(err)
+	inserted2, err := s.InsertEvent(fixture.Failure("root", "203.0.113.5").At(now).Port(22).Build(), geoip.Location{})
+	check(err)
+	snap.Duplicate = inserted && !inserted2
+
+	snap.Aggregated, err = s.GetAggregatedFailures(since, until.Add(time.Hour), "ssh")
+	check(err)
+	snap.Logins, err = s.GetSuccessfulLogins(since, storage.EventFilter{ContinentCode: "eu"})
+	check(err)
+	snap.Failures, err = s.GetFailedAttempts(since, storage.EventFilter{Service: "ssh", CountryCode: "de"})
+	check(err)
+	byIP, err := s.GetFailedAttempts(since, storage.EventFilter{IP: "203.0.113.5", Username: "root"})
+	snap.Failures = append(snap.Failures, byIP...)
+	check(err)
+	last, err := s.GetLastLoginForUser("ssh", "alice")
+	check(err)
+	snap.LastLogin = *last
+	stats, err := s.GetFailedStats(since, until, "")
+	check(err)
+	snap.FailedStats = *stats
+	snap.TopUsers, err = s.GetTopUsernames(since, until, 2, "ssh")
+	check(err)
+	snap.TopIPs, err = s.GetTopIPs(since, until, 10, "")
+	check(err)
+	snap.TopASNs, err = s.GetTopASNs(since, until, 10)
+	check(err)
+	snap.TopCountries, err = s.GetTopCountries(since, until, 10)
+	check(err)
+	history, err := s.GetIPHistory("203.0.113.5", now.Add(-2*time.Minute))
+	check(err)
+	snap.History = *history
+	snap.ToEnrich, err = s.GetIPsToEnrich(time.Time{}, true, false)
+	check(err)
+	snap.Success, err = s.GetSuccessCount(since, until, "")
+	check(err)
+	snap.Services, err = s.GetServiceStats(since, until)
+	check(err)
+	overall, err := s.GetOverallStats(since, "")
+	check(err)
+	snap.Overall = *overall
+	for _, day := range []time.Time{now.AddDate(0, 0, -1), now, now.AddDate(0, 0, -100)} {
+		check(s.Rollup(day))
+	}
+	snap.Rollups, err = s.GetDailyRollups(now.AddDate(0, 0, -1), now.AddDate(0, 0, 1))
+	check(err)
+	snap.RollupIPs, err = s.GetRollupTopIPs(now.AddDate(0, 0, -200), now.AddDate(0, 0, 1), 10)
+	check(err)
+	snap.RollupCC, err = s.GetRollupTopCountries(now.AddDate(0, 0, -200), now.AddDate(0, 0, 1), 10)
+	check(err)
+	snap.Keys, err = s.GetKeyUsage("SHA256:deploykey", "")
+	check(err)
+	for i := range snap.Keys {
+		snap.Keys[i].FirstSeen = snap.Keys[i].FirstSeen.UTC()
+		snap.Keys[i].LastSeen = snap.Keys[i].LastSeen.UTC()
+	}
+	snap.Deleted, err = s.Cleanup(now, 90)
+	check(err)
+	overall, err = s.GetOverallStats(time.Time{}, "")
+	check(err)
+	snap.AfterCleanup = *overall
+
+	// Fixture ports differ between the two seeds, everything else must match.
+	for _, records := range [][]storage.SSHEventRecord{snap.Logins, snap.Failures} {
+		for i := range records {
+			records[i].Timestamp = records[i].Timestamp.UTC()
+			records[i].CreatedAt = time.Time{}
+			records[i].Port = 0
+		}
+	}
+	snap.LastLogin.Timestamp = snap.LastLogin.Timestamp.UTC()
+	snap.LastLogin.CreatedAt = time.Time{}
+	snap.LastLogin.Port = 0
+	snap.History.FirstSeen = snap.History.FirstSeen.UTC()
+	return snap
+}
+
+func TestMemoryMatchesSQLite(t *testing.T) {
+	sqlite, err := storage.New(t.TempDir() + "/oxiwatch.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+	memory := storage.NewMemory()
+
+	now := seed(t, sqlite)
+	seed(t, memory)
+
+	want := take(t, sqlite, now)
+	got := take(t, memory, now)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("memory store differs from sqlite\n got: %+v\nwant: %+v", got, want)
+	}
+	if want.Overall.FailedCount == 0 || len(want.TopIPs) == 0 || len(want.Rollups) == 0 || len(want.RollupCC) == 0 || len(want.Keys) != 2 || !want.Duplicate || want.Aggregated != 9 {
+		t.Errorf("scenario did not exercise the store: %+v", want)
+	}
+}
+
+func TestMemoryGeoIPComparison(t *testing.T) {
+	s := storage.NewMemory()
+	now := time.Now()
+	s.AddGeoIPComparison("192.0.2.1", now, fixture.Location("AU", "Australia", "Sydney"), fixture.Location("US", "United States", ""))
+	s.AddGeoIPComparison("192.0.2.2", now, fixture.Location("DE", "Germany", "Berlin"), fixture.Location("DE", "Germany", "Munich"))
+	s.AddGeoIPComparison("192.0.2.3", now, geoip.Location{}, fixture.Location("FR", "France", ""))
+	s.AddGeoIPComparison("192.0.2.3", now, fixture.Location("FR", "France", ""), fixture.Location("FR", "France", ""))
+
+	c, err := s.GetGeoIPComparison(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Samples != 3 || c.MissingA != 1 || c.MissingB != 0 || c.CountryMismatch != 1 || c.CityMismatch != 1 {
+		t.Errorf("unexpected summary: %+v", c)
+	}
+	if len(c.Examples) != 1 || c.Examples[0].IP != "192.0.2.1" {
+		t.Errorf("expected one example for 192.0.2.1, got %+v", c.Examples)
+	}
+}
+
+func TestLegalHolds(t *testing.T) {
+	sqlite, err := storage.New(t.TempDir() + "/oxiwatch.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+
+	for name, s := range map[string]storage.Store{"sqlite": sqlite, "memory": storage.NewMemory()} {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now().Truncate(time.Second)
+			old := now.AddDate(0, 0, -100)
+			for _, b := range []*fixture.EventBuilder{
+				fixture.Failure("root", "203.0.113.5").At(old),
+				fixture.Failure("root", "203.0.113.200").At(old),
+				fixture.Failure("admin", "198.51.100.7").At(old),
+				fixture.Success("alice", "192.0.2.1").At(old),
+				fixture.Success("alice", "192.0.2.1").At(old.AddDate(0, 0, -20)),
+			} {
+				if _, err := s.InsertEvent(b.Build(), geoip.Location{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.AddFailureAggregate(fixture.Failure("root", "203.0.113.9").At(old).Build(), geoip.Location{}); err != nil {
+				t.Fatal(err)
+			}
+
+			network, err := storage.ParseIPRange("203.0.113.0/25")
+			if err != nil {
+				t.Fatal(err)
+			}
+			netHold, err := s.AddLegalHold(storage.LegalHold{IPRange: network, Reason: "case 1", CreatedAt: now})
+			if err != nil {
+				t.Fatal(err)
+			}
+			since := old.AddDate(0, 0, -1)
+			if _, err := s.AddLegalHold(storage.LegalHold{Username: "alice", Since: since, Reason: "case 2", CreatedAt: now}); err != nil {
+				t.Fatal(err)
+			}
+
+			// 203.0.113.200 is outside the /25, alice's older login before
+			// the hold's window.
+			deleted, err := s.Cleanup(now, 90)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted != 3 {
+				t.Errorf("expected 3 unheld events deleted, got %d", deleted)
+			}
+			if n, _ := s.GetAggregatedFailures(old.Add(-time.Hour), now, ""); n != 1 {
+				t.Errorf("expected the held aggregate to survive, got %d", n)
+			}
+
+			released, err := s.ReleaseLegalHold(netHold, now)
+			if err != nil || !released {
+				t.Fatalf("expected hold %d to be released: %v", netHold, err)
+			}
+			if released, _ := s.ReleaseLegalHold(netHold, now); released {
+				t.Error("expected a second release to report nothing released")
+			}
+			holds, err := s.GetLegalHolds(false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(holds) != 1 || holds[0].Username != "alice" || !holds[0].Since.Equal(since) {
+				t.Errorf("unexpected active holds: %+v", holds)
+			}
+			if all, _ := s.GetLegalHolds(true); len(all) != 2 || all[0].ReleasedAt.IsZero() {
+				t.Errorf("expected the released hold in the full list: %+v", all)
+			}
+
+			deleted, err = s.Cleanup(now, 90)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted != 1 {
+				t.Errorf("expected the released hold's event deleted, got %d", deleted)
+			}
+			if n, _ := s.GetAggregatedFailures(old.Add(-time.Hour), now, ""); n != 0 {
+				t.Errorf("expected the aggregate deleted after release, got %d", n)
+			}
+		})
+	}
+}
+
+func TestTrustedNetworks(t *testing.T) {
+	sqlite, err := storage.New(t.TempDir() + "/oxiwatch.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+
+	for name, s := range map[string]storage.Store{"sqlite": sqlite, "memory": storage.NewMemory()} {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now().Truncate(time.Second)
+			for _, n := range []storage.TrustedNetwork{
+				{IPRange: "198.51.100.0/24", Note: "office", ApprovedAt: now},
+				{IPRange: "192.0.2.7/32", ApprovedAt: now.Add(-time.Hour)},
+			} {
+				if added, err := s.AddTrustedNetwork(n); err != nil || !added {
+					t.Fatalf("expected %s to be added: %v", n.IPRange, err)
+				}
+			}
+			if added, _ := s.AddTrustedNetwork(storage.TrustedNetwork{IPRange: "192.0.2.7/32", ApprovedAt: now}); added {
+				t.Error("expected approving a range twice to add nothing")
+			}
+
+			networks, err := s.GetTrustedNetworks()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(networks) != 2 || networks[0].IPRange != "192.0.2.7/32" || networks[1].Note != "office" || !networks[1].ApprovedAt.Equal(now) {
+				t.Errorf("unexpected trusted networks: %+v", networks)
+			}
+
+			if removed, err := s.RemoveTrustedNetwork("192.0.2.7/32"); err != nil || !removed {
+				t.Fatalf("expected 192.0.2.7/32 to be removed: %v", err)
+			}
+			if removed, _ := s.RemoveTrustedNetwork("192.0.2.7/32"); removed {
+				t.Error("expected a second removal to report nothing removed")
+			}
+			if networks, _ := s.GetTrustedNetworks(); len(networks) != 1 {
+				t.Errorf("expected one network left, got %+v", networks)
+			}
+		})
+	}
+}
+
+func TestCampaigns(t *testing.T) {
+	sqlite, err := storage.New(t.TempDir() + "/oxiwatch.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+
+	for name, s := range map[string]storage.Store{"sqlite": sqlite, "memory": storage.NewMemory()} {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now().Truncate(time.Second)
+			network := geoip.Location{ASN: 64500, ASOrg: "Example Hosting"}
+			for i, b := range []*fixture.EventBuilder{
+				fixture.Failure("root", "203.0.113.5").At(now.Add(-30 * time.Minute)),
+				fixture.Failure("root", "203.0.113.5").At(now.Add(-20 * time.Minute)),
+				fixture.Failure("admin", "203.0.113.5").At(now.Add(-10 * time.Minute)),
+				fixture.Failure("root", "203.0.113.6").At(now.Add(-5 * time.Minute)),
+				fixture.Failure("root", "203.0.113.6").At(now.Add(-2 * time.Hour)),
+			} {
+				if _, err := s.InsertEvent(b.Build(), network); err != nil {
+					t.Fatal(err)
+				}
+				if i == 0 {
+					// Without a network the event is left out.
+					if _, err := s.InsertEvent(fixture.Failure("root", "192.0.2.1").At(now.Add(-time.Minute)).Build(), geoip.Location{}); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			since := now.Add(-time.Hour)
+			attempts, err := s.GetIPUserAttempts(since, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(attempts) != 3 {
+				t.Fatalf("expected 3 IP/username groups, got %+v", attempts)
+			}
+			if a := attempts[1]; a.IP != "203.0.113.5" || a.Username != "root" || a.Count != 2 || a.ASOrg != "Example Hosting" ||
+				!a.First.Equal(now.Add(-30*time.Minute)) || !a.Last.Equal(now.Add(-20*time.Minute)) {
+				t.Errorf("unexpected group: %+v", a)
+			}
+
+			tagged, err := s.TagCampaignEvents("c-1", 64500, []string{"203.0.113.5", "203.0.113.6"}, since, now)
+			if err != nil || tagged != 4 {
+				t.Fatalf("expected 4 events tagged, got %d: %v", tagged, err)
+			}
+			if tagged, _ := s.TagCampaignEvents("c-2", 64500, []string{"203.0.113.5"}, since, now); tagged != 0 {
+				t.Errorf("expected tagged events to keep their campaign, got %d retagged", tagged)
+			}
+			failures, err := s.GetFailedAttempts(since, storage.EventFilter{IP: "203.0.113.6"})
+			if err != nil || len(failures) != 1 || failures[0].CampaignID != "c-1" {
+				t.Errorf("expected the campaign ID on the event: %+v %v", failures, err)
+			}
+
+			campaign := storage.Campaign{
+				ID: "c-1", ASN: 64500, ASOrg: "Example Hosting",
+				Usernames: []string{"admin", "root"}, IPs: []string{"203.0.113.5", "203.0.113.6"},
+				Attempts: 4, FirstSeen: now.Add(-30 * time.Minute), LastSeen: now.Add(-5 * time.Minute),
+			}
+			if err := s.SaveCampaign(campaign); err != nil {
+				t.Fatal(err)
+			}
+			campaign.Attempts = 5
+			if err := s.SaveCampaign(campaign); err != nil {
+				t.Fatal(err)
+			}
+			campaigns, err := s.GetCampaigns(since)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(campaigns) != 1 || campaigns[0].Attempts != 5 || len(campaigns[0].IPs) != 2 || campaigns[0].Usernames[1] != "root" {
+				t.Errorf("unexpected campaigns: %+v", campaigns)
+			}
+
+			if _, err := s.Cleanup(now.AddDate(0, 0, 91), 90); err != nil {
+				t.Fatal(err)
+			}
+			if campaigns, _ := s.GetCampaigns(time.Time{}); len(campaigns) != 0 {
+				t.Errorf("expected cleanup to delete the campaign, got %+v", campaigns)
+			}
+		})
+	}
+}
+
+// SQLite compares stored timestamps as text, which the memory store hides.
+// Events parsed from logs with their own offset must still land in the
+// right window.
+func TestParsedTimestampsInSQLite(t *testing.T) {
+	sqlite, err := storage.New(t.TempDir() + "/oxiwatch.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+
+	for _, event := range []*parser.SSHEvent{
+		parser.ParseAccessLog(`198.51.100.4 - - [20/Jan/2026:14:32:15 +0100] "GET /.env HTTP/1.1" 403 153 "-" "Mozilla/5.0"`),
+		parser.ParseAutheliaLine(`time="2026-01-20T14:32:15+01:00" level=error msg="Unsuccessful 1FA authentication attempt by user 'bob'" remote_ip=203.0.113.7`),
+		parser.ParseAuthentikLine(`{"action": "login_failed", "client_ip": "203.0.113.8", "context": {"username": "bob"}, "timestamp": "2026-01-20T13:32:15Z"}`),
+	} {
+		if _, err := sqlite.InsertEvent(event, geoip.Location{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	since := time.Date(2026, time.January, 20, 13, 0, 0, 0, time.UTC).Local()
+	stats, err := sqlite.GetFailedStats(since, since.Add(time.Hour), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalAttempts != 3 {
+		t.Errorf("expected the failures at 13:32 UTC in [13:00, 14:00), got %d", stats.TotalAttempts)
+	}
+}
@@ -3,8 +3,11 @@ package storage
 import (
 	"database/sql"
 	"fmt"
+	"net/netip"
+	"strings"
 	"time"
 
+	"github.com/oxisoft/oxiwatch/internal/geoip"
 	"github.com/oxisoft/oxiwatch/internal/parser"
 	_ "modernc.org/sqlite"
 )
@@ -14,17 +17,23 @@ type Storage struct {
 }
 
 type SSHEventRecord struct {
-	ID          int64
-	Timestamp   time.Time
-	EventType   string
-	Username    string
-	IP          string
-	Port        int
-	Method      string
-	Country     string
-	City        string
-	InvalidUser bool
-	CreatedAt   time.Time
+	ID            int64
+	Timestamp     time.Time
+	EventType     string
+	Service       string
+	Username      string
+	IP            string
+	Port          int
+	Method        string
+	Country       string
+	CountryCode   string
+	ContinentCode string
+	City          string
+	InvalidUser   bool
+	MFA           bool
+	AuthSource    string
+	CampaignID    string
+	CreatedAt     time.Time
 }
 
 type Stats struct {
@@ -39,10 +48,13 @@ type UsernameCount struct {
 }
 
 type IPCount struct {
-	IP      string
-	Country string
-	City    string
-	Count   int
+	IP          string
+	Country     string
+	CountryCode string
+	City        string
+	ASN         uint
+	ASOrg       string
+	Count       int
 }
 
 func New(dbPath string) (*Storage, error) {
@@ -83,50 +95,337 @@ func (s *Storage) migrate() error {
 	CREATE INDEX IF NOT EXISTS idx_event_type ON ssh_events(event_type);
 	CREATE INDEX IF NOT EXISTS idx_ip ON ssh_events(ip);
 	CREATE INDEX IF NOT EXISTS idx_username ON ssh_events(username);
+
+	CREATE TABLE IF NOT EXISTS failure_aggregates (
+		hour DATETIME NOT NULL,
+		service TEXT NOT NULL,
+		ip TEXT NOT NULL,
+		country TEXT,
+		city TEXT,
+		count INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (hour, service, ip)
+	);
+
+	CREATE TABLE IF NOT EXISTS geoip_comparisons (
+		ip TEXT PRIMARY KEY,
+		seen_at DATETIME NOT NULL,
+		country_code_a TEXT,
+		city_a TEXT,
+		country_code_b TEXT,
+		city_b TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS daily_rollups (
+		day TEXT NOT NULL,
+		service TEXT NOT NULL,
+		success INTEGER NOT NULL DEFAULT 0,
+		failed INTEGER NOT NULL DEFAULT 0,
+		unique_ips INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (day, service)
+	);
+
+	CREATE TABLE IF NOT EXISTS daily_rollup_ips (
+		day TEXT NOT NULL,
+		ip TEXT NOT NULL,
+		country TEXT,
+		country_code TEXT,
+		count INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (day, ip)
+	);
+
+	CREATE TABLE IF NOT EXISTS daily_rollup_countries (
+		day TEXT NOT NULL,
+		country TEXT NOT NULL,
+		country_code TEXT,
+		count INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (day, country)
+	);
+
+	CREATE TABLE IF NOT EXISTS key_usage (
+		fingerprint TEXT NOT NULL,
+		username TEXT NOT NULL,
+		ip TEXT NOT NULL,
+		key_type TEXT,
+		country TEXT,
+		country_code TEXT,
+		city TEXT,
+		first_seen DATETIME NOT NULL,
+		last_seen DATETIME NOT NULL,
+		logins INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (fingerprint, username, ip)
+	);
+
+	CREATE TABLE IF NOT EXISTS legal_holds (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ip_range TEXT,
+		username TEXT,
+		since DATETIME,
+		until DATETIME,
+		reason TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		released_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS campaigns (
+		id TEXT PRIMARY KEY,
+		asn INTEGER NOT NULL,
+		as_org TEXT NOT NULL DEFAULT '',
+		usernames TEXT NOT NULL,
+		ips TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		first_seen DATETIME NOT NULL,
+		last_seen DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS trusted_networks (
+		ip_range TEXT PRIMARY KEY,
+		note TEXT NOT NULL DEFAULT '',
+		approved_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS state (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
 	`
 
-	_, err := s.db.Exec(schema)
+	if _, err := s.db.Exec(schema); err != nil {
+		return err
+	}
+
+	if err := s.addColumn("ssh_events", "service", "TEXT NOT NULL DEFAULT 'ssh'"); err != nil {
+		return err
+	}
+	if err := s.addColumn("ssh_events", "mfa", "BOOLEAN DEFAULT FALSE"); err != nil {
+		return err
+	}
+	if err := s.addColumn("ssh_events", "auth_source", "TEXT"); err != nil {
+		return err
+	}
+	if err := s.addColumn("ssh_events", "event_hash", "TEXT"); err != nil {
+		return err
+	}
+	if err := s.addColumn("ssh_events", "country_code", "TEXT"); err != nil {
+		return err
+	}
+	if err := s.addColumn("ssh_events", "continent", "TEXT"); err != nil {
+		return err
+	}
+	if err := s.addColumn("ssh_events", "continent_code", "TEXT"); err != nil {
+		return err
+	}
+	if err := s.addColumn("ssh_events", "asn", "INTEGER"); err != nil {
+		return err
+	}
+	if err := s.addColumn("ssh_events", "as_org", "TEXT"); err != nil {
+		return err
+	}
+	if err := s.addColumn("ssh_events", "campaign_id", "TEXT"); err != nil {
+		return err
+	}
+
+	_, err := s.db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_service ON ssh_events(service);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_event_hash ON ssh_events(event_hash);
+	`)
 	return err
 }
 
-func (s *Storage) InsertEvent(event *parser.SSHEvent, country, city string) error {
+func (s *Storage) addColumn(table, column, definition string) error {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   bool
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// InsertEvent stores the event unless one with the same hash already exists.
+// inserted is false for such duplicates.
+func (s *Storage) InsertEvent(event *parser.SSHEvent, loc geoip.Location) (inserted bool, err error) {
 	query := `
-		INSERT INTO ssh_events (timestamp, event_type, username, ip, port, method, country, city, invalid_user)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT OR IGNORE INTO ssh_events (timestamp, event_type, service, username, ip, port, method, country, country_code, continent, continent_code, city, asn, as_org, invalid_user, mfa, auth_source, event_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := s.db.Exec(query,
+	service := event.Service
+	if service == "" {
+		service = parser.ServiceSSH
+	}
+
+	result, err := s.db.Exec(query,
 		event.Timestamp,
 		string(event.EventType),
+		service,
 		event.Username,
 		event.IP,
 		event.Port,
 		event.Method,
-		nullString(country),
-		nullString(city),
+		nullString(loc.Country),
+		nullString(loc.CountryCode),
+		nullString(loc.Continent),
+		nullString(loc.ContinentCode),
+		nullString(loc.City),
+		nullInt(loc.ASN),
+		nullString(loc.ASOrg),
 		event.InvalidUser,
+		event.MFA,
+		nullString(event.AuthSource),
+		event.Hash(),
 	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	if event.EventType == parser.EventSuccess && event.Fingerprint != "" {
+		if err := s.recordKeyUsage(event, loc); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// recordKeyUsage updates the key inventory. Unlike events it is never
+// cleaned up, so keys that stop being used can still be reported.
+func (s *Storage) recordKeyUsage(event *parser.SSHEvent, loc geoip.Location) error {
+	_, err := s.db.Exec(`
+		INSERT INTO key_usage (fingerprint, username, ip, key_type, country, country_code, city, first_seen, last_seen, logins)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1)
+		ON CONFLICT(fingerprint, username, ip) DO UPDATE SET
+			key_type = COALESCE(excluded.key_type, key_type),
+			country = COALESCE(excluded.country, country),
+			country_code = COALESCE(excluded.country_code, country_code),
+			city = CASE WHEN excluded.country IS NULL THEN city ELSE excluded.city END,
+			first_seen = MIN(first_seen, excluded.first_seen),
+			last_seen = MAX(last_seen, excluded.last_seen),
+			logins = logins + 1
+	`, event.Fingerprint, event.Username, event.IP, nullString(event.KeyType),
+		nullString(loc.Country), nullString(loc.CountryCode), nullString(loc.City),
+		event.Timestamp, event.Timestamp)
+	return err
+}
+
+// AddFailureAggregate counts a failed attempt against its hour/service/ip
+// bucket instead of storing a full row; used while disk space is low.
+func (s *Storage) AddFailureAggregate(event *parser.SSHEvent, loc geoip.Location) error {
+	service := event.Service
+	if service == "" {
+		service = parser.ServiceSSH
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO failure_aggregates (hour, service, ip, country, city, count)
+		VALUES (?, ?, ?, ?, ?, 1)
+		ON CONFLICT(hour, service, ip) DO UPDATE SET count = count + 1
+	`, event.Timestamp.Truncate(time.Hour), service, event.IP, nullString(loc.Country), nullString(loc.City))
 	return err
 }
 
-func (s *Storage) GetSuccessfulLogins(since time.Time) ([]SSHEventRecord, error) {
-	return s.getEvents("success", since)
+// FailureAggregate is a number of failed attempts from one IP against one
+// service within an hour.
+type FailureAggregate struct {
+	Hour    time.Time
+	Service string
+	IP      string
+	Country string
+	City    string
+	Count   int
 }
 
-func (s *Storage) GetLastLoginForUser(username string) (*SSHEventRecord, error) {
+// AddFailureAggregates adds a batch of counts in one transaction; used by
+// the high-volume profile.
+func (s *Storage) AddFailureAggregates(aggregates []FailureAggregate) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO failure_aggregates (hour, service, ip, country, city, count)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(hour, service, ip) DO UPDATE SET count = count + excluded.count
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, a := range aggregates {
+		if _, err := stmt.Exec(a.Hour.Truncate(time.Hour), a.Service, a.IP, nullString(a.Country), nullString(a.City), a.Count); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Storage) GetAggregatedFailures(since, until time.Time, service string) (int, error) {
+	var count int
+	err := s.db.QueryRow(`
+		SELECT COALESCE(SUM(count), 0) FROM failure_aggregates
+		WHERE hour >= ? AND hour < ? AND (? = '' OR service = ?)
+	`, since.Truncate(time.Hour), until, service, service).Scan(&count)
+	return count, err
+}
+
+// EventFilter narrows event listings. Empty fields match everything; country
+// and continent are matched by ISO code, not by name.
+type EventFilter struct {
+	Service       string
+	CountryCode   string
+	ContinentCode string
+	IP            string
+	Username      string
+}
+
+func (s *Storage) GetSuccessfulLogins(since time.Time, filter EventFilter) ([]SSHEventRecord, error) {
+	return s.getEvents("success", since, filter)
+}
+
+func (s *Storage) GetLastLoginForUser(service, username string) (*SSHEventRecord, error) {
 	query := `
-		SELECT id, timestamp, event_type, username, ip, port, method,
-		       COALESCE(country, ''), COALESCE(city, ''), invalid_user, created_at
+		SELECT id, timestamp, event_type, service, username, ip, port, method,
+		       COALESCE(country, ''), COALESCE(country_code, ''), COALESCE(continent_code, ''), COALESCE(city, ''),
+		       invalid_user, COALESCE(mfa, FALSE), COALESCE(auth_source, ''), created_at
 		FROM ssh_events
-		WHERE event_type = 'success' AND username = ?
+		WHERE event_type = 'success' AND service = ? AND username = ?
 		ORDER BY timestamp DESC
 		LIMIT 1
 	`
 
 	var e SSHEventRecord
-	err := s.db.QueryRow(query, username).Scan(
-		&e.ID, &e.Timestamp, &e.EventType, &e.Username, &e.IP,
-		&e.Port, &e.Method, &e.Country, &e.City, &e.InvalidUser, &e.CreatedAt,
+	err := s.db.QueryRow(query, service, username).Scan(
+		&e.ID, &e.Timestamp, &e.EventType, &e.Service, &e.Username, &e.IP,
+		&e.Port, &e.Method, &e.Country, &e.CountryCode, &e.ContinentCode, &e.City,
+		&e.InvalidUser, &e.MFA, &e.AuthSource, &e.CreatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -134,20 +433,30 @@ func (s *Storage) GetLastLoginForUser(username string) (*SSHEventRecord, error)
 	return &e, nil
 }
 
-func (s *Storage) GetFailedAttempts(since time.Time) ([]SSHEventRecord, error) {
-	return s.getEvents("failure", since)
+func (s *Storage) GetFailedAttempts(since time.Time, filter EventFilter) ([]SSHEventRecord, error) {
+	return s.getEvents("failure", since, filter)
 }
 
-func (s *Storage) getEvents(eventType string, since time.Time) ([]SSHEventRecord, error) {
+func (s *Storage) getEvents(eventType string, since time.Time, filter EventFilter) ([]SSHEventRecord, error) {
 	query := `
-		SELECT id, timestamp, event_type, username, ip, port, method,
-		       COALESCE(country, ''), COALESCE(city, ''), invalid_user, created_at
+		SELECT id, timestamp, event_type, service, username, ip, port, method,
+		       COALESCE(country, ''), COALESCE(country_code, ''), COALESCE(continent_code, ''), COALESCE(city, ''),
+		       invalid_user, COALESCE(mfa, FALSE), COALESCE(auth_source, ''), COALESCE(campaign_id, ''), created_at
 		FROM ssh_events
 		WHERE event_type = ? AND timestamp >= ?
+		  AND (? = '' OR service = ?)
+		  AND (? = '' OR country_code = ?)
+		  AND (? = '' OR continent_code = ?)
+		  AND (? = '' OR ip = ?)
+		  AND (? = '' OR username = ?)
 		ORDER BY timestamp DESC
 	`
 
-	rows, err := s.db.Query(query, eventType, since)
+	country := strings.ToUpper(filter.CountryCode)
+	continent := strings.ToUpper(filter.ContinentCode)
+	rows, err := s.db.Query(query, eventType, since,
+		filter.Service, filter.Service, country, country, continent, continent,
+		filter.IP, filter.IP, filter.Username, filter.Username)
 	if err != nil {
 		return nil, err
 	}
@@ -156,8 +465,9 @@ func (s *Storage) getEvents(eventType string, since time.Time) ([]SSHEventRecord
 	var events []SSHEventRecord
 	for rows.Next() {
 		var e SSHEventRecord
-		if err := rows.Scan(&e.ID, &e.Timestamp, &e.EventType, &e.Username, &e.IP,
-			&e.Port, &e.Method, &e.Country, &e.City, &e.InvalidUser, &e.CreatedAt); err != nil {
+		if err := rows.Scan(&e.ID, &e.Timestamp, &e.EventType, &e.Service, &e.Username, &e.IP,
+			&e.Port, &e.Method, &e.Country, &e.CountryCode, &e.ContinentCode, &e.City,
+			&e.InvalidUser, &e.MFA, &e.AuthSource, &e.CampaignID, &e.CreatedAt); err != nil {
 			return nil, err
 		}
 		events = append(events, e)
@@ -165,35 +475,35 @@ func (s *Storage) getEvents(eventType string, since time.Time) ([]SSHEventRecord
 	return events, rows.Err()
 }
 
-func (s *Storage) GetFailedStats(since time.Time) (*Stats, error) {
+func (s *Storage) GetFailedStats(since, until time.Time, service string) (*Stats, error) {
 	query := `
 		SELECT
 			COUNT(*) as total,
 			COUNT(DISTINCT ip) as unique_ips,
 			COUNT(DISTINCT username) as unique_usernames
 		FROM ssh_events
-		WHERE event_type = 'failure' AND timestamp >= ?
+		WHERE event_type = 'failure' AND timestamp >= ? AND timestamp < ? AND (? = '' OR service = ?)
 	`
 
 	var stats Stats
-	err := s.db.QueryRow(query, since).Scan(&stats.TotalAttempts, &stats.UniqueIPs, &stats.UniqueUsernames)
+	err := s.db.QueryRow(query, since, until, service, service).Scan(&stats.TotalAttempts, &stats.UniqueIPs, &stats.UniqueUsernames)
 	if err != nil {
 		return nil, err
 	}
 	return &stats, nil
 }
 
-func (s *Storage) GetTopUsernames(since time.Time, limit int) ([]UsernameCount, error) {
+func (s *Storage) GetTopUsernames(since, until time.Time, limit int, service string) ([]UsernameCount, error) {
 	query := `
 		SELECT username, COUNT(*) as count
 		FROM ssh_events
-		WHERE event_type = 'failure' AND timestamp >= ?
+		WHERE event_type = 'failure' AND timestamp >= ? AND timestamp < ? AND username != '' AND (? = '' OR service = ?)
 		GROUP BY username
 		ORDER BY count DESC
 		LIMIT ?
 	`
 
-	rows, err := s.db.Query(query, since, limit)
+	rows, err := s.db.Query(query, since, until, service, service, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -210,17 +520,17 @@ func (s *Storage) GetTopUsernames(since time.Time, limit int) ([]UsernameCount,
 	return results, rows.Err()
 }
 
-func (s *Storage) GetTopIPs(since time.Time, limit int) ([]IPCount, error) {
+func (s *Storage) GetTopIPs(since, until time.Time, limit int, service string) ([]IPCount, error) {
 	query := `
-		SELECT ip, COALESCE(country, ''), COALESCE(city, ''), COUNT(*) as count
+		SELECT ip, COALESCE(country, ''), COALESCE(MAX(country_code), ''), COALESCE(city, ''), COALESCE(MAX(asn), 0), COALESCE(MAX(as_org), ''), COUNT(*) as count
 		FROM ssh_events
-		WHERE event_type = 'failure' AND timestamp >= ?
+		WHERE event_type = 'failure' AND timestamp >= ? AND timestamp < ? AND (? = '' OR service = ?)
 		GROUP BY ip
 		ORDER BY count DESC
 		LIMIT ?
 	`
 
-	rows, err := s.db.Query(query, since, limit)
+	rows, err := s.db.Query(query, since, until, service, service, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -229,7 +539,7 @@ func (s *Storage) GetTopIPs(since time.Time, limit int) ([]IPCount, error) {
 	var results []IPCount
 	for rows.Next() {
 		var ic IPCount
-		if err := rows.Scan(&ic.IP, &ic.Country, &ic.City, &ic.Count); err != nil {
+		if err := rows.Scan(&ic.IP, &ic.Country, &ic.CountryCode, &ic.City, &ic.ASN, &ic.ASOrg, &ic.Count); err != nil {
 			return nil, err
 		}
 		results = append(results, ic)
@@ -237,15 +547,323 @@ func (s *Storage) GetTopIPs(since time.Time, limit int) ([]IPCount, error) {
 	return results, rows.Err()
 }
 
-func (s *Storage) GetSuccessCount(since time.Time) (int, error) {
+type ASNCount struct {
+	ASN       uint
+	ASOrg     string
+	Count     int
+	UniqueIPs int
+}
+
+func (s *Storage) GetTopASNs(since, until time.Time, limit int) ([]ASNCount, error) {
+	rows, err := s.db.Query(`
+		SELECT asn, COALESCE(MAX(as_org), ''), COUNT(*) as count, COUNT(DISTINCT ip)
+		FROM ssh_events
+		WHERE event_type = 'failure' AND timestamp >= ? AND timestamp < ? AND asn IS NOT NULL
+		GROUP BY asn
+		ORDER BY count DESC
+		LIMIT ?
+	`, since, until, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ASNCount
+	for rows.Next() {
+		var ac ASNCount
+		if err := rows.Scan(&ac.ASN, &ac.ASOrg, &ac.Count, &ac.UniqueIPs); err != nil {
+			return nil, err
+		}
+		results = append(results, ac)
+	}
+	return results, rows.Err()
+}
+
+// IPUserAttempts counts the failed attempts from one IP for one username.
+type IPUserAttempts struct {
+	ASN      uint
+	ASOrg    string
+	IP       string
+	Username string
+	Count    int
+	First    time.Time
+	Last     time.Time
+}
+
+// GetIPUserAttempts returns failed attempts in [since, until) grouped by IP
+// and username, for events whose network is known.
+func (s *Storage) GetIPUserAttempts(since, until time.Time) ([]IPUserAttempts, error) {
+	// Grouped here rather than in SQL, where MIN and MAX of the stored
+	// timestamps would come back as text.
+	rows, err := s.db.Query(`
+		SELECT asn, COALESCE(as_org, ''), ip, username, timestamp
+		FROM ssh_events
+		WHERE event_type = 'failure' AND timestamp >= ? AND timestamp < ? AND asn IS NOT NULL
+		ORDER BY asn, ip, username
+	`, since, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []IPUserAttempts
+	for rows.Next() {
+		var a IPUserAttempts
+		var ts time.Time
+		if err := rows.Scan(&a.ASN, &a.ASOrg, &a.IP, &a.Username, &ts); err != nil {
+			return nil, err
+		}
+		n := len(results)
+		if n == 0 || results[n-1].ASN != a.ASN || results[n-1].IP != a.IP || results[n-1].Username != a.Username {
+			a.First, a.Last = ts, ts
+			results = append(results, a)
+			n++
+		}
+		last := &results[n-1]
+		last.Count++
+		last.ASOrg = max(last.ASOrg, a.ASOrg)
+		if ts.Before(last.First) {
+			last.First = ts
+		}
+		if ts.After(last.Last) {
+			last.Last = ts
+		}
+	}
+	return results, rows.Err()
+}
+
+type CountryCount struct {
+	Country     string
+	CountryCode string
+	Count       int
+	UniqueIPs   int
+}
+
+func (s *Storage) GetTopCountries(since, until time.Time, limit int) ([]CountryCount, error) {
+	rows, err := s.db.Query(`
+		SELECT country, COALESCE(MAX(country_code), ''), COUNT(*) as count, COUNT(DISTINCT ip)
+		FROM ssh_events
+		WHERE event_type = 'failure' AND timestamp >= ? AND timestamp < ? AND country IS NOT NULL
+		GROUP BY country
+		ORDER BY count DESC
+		LIMIT ?
+	`, since, until, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []CountryCount
+	for rows.Next() {
+		var cc CountryCount
+		if err := rows.Scan(&cc.Country, &cc.CountryCode, &cc.Count, &cc.UniqueIPs); err != nil {
+			return nil, err
+		}
+		results = append(results, cc)
+	}
+	return results, rows.Err()
+}
+
+type IPHistory struct {
+	Count     int
+	FirstSeen time.Time
+}
+
+// GetIPHistory counts events from ip since the given time and returns the
+// earliest stored event for it regardless of age.
+func (s *Storage) GetIPHistory(ip string, since time.Time) (*IPHistory, error) {
+	var h IPHistory
+	if err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM ssh_events WHERE ip = ? AND timestamp >= ?
+	`, ip, since).Scan(&h.Count); err != nil {
+		return nil, err
+	}
+
+	err := s.db.QueryRow(`
+		SELECT timestamp FROM ssh_events WHERE ip = ? ORDER BY timestamp ASC LIMIT 1
+	`, ip).Scan(&h.FirstSeen)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// GetIPsToEnrich returns the distinct IPs of events since the given time.
+// With missingOnly set, only IPs of events lacking a country code (or an ASN
+// when withASN is set) are returned.
+func (s *Storage) GetIPsToEnrich(since time.Time, missingOnly, withASN bool) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT ip FROM ssh_events
+		WHERE timestamp >= ?
+		AND (? = 0 OR country_code IS NULL OR (? = 1 AND asn IS NULL))
+	`, since, missingOnly, withASN)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ips []string
+	for rows.Next() {
+		var ip string
+		if err := rows.Scan(&ip); err != nil {
+			return nil, err
+		}
+		ips = append(ips, ip)
+	}
+	return ips, rows.Err()
+}
+
+// UpdateLocation overwrites the geo columns of all events from ip since the
+// given time and returns the number of rows changed.
+func (s *Storage) UpdateLocation(ip string, since time.Time, loc geoip.Location) (int64, error) {
+	result, err := s.db.Exec(`
+		UPDATE ssh_events
+		SET country = ?, country_code = ?, continent = ?, continent_code = ?, city = ?, asn = ?, as_org = ?
+		WHERE ip = ? AND timestamp >= ?
+	`,
+		nullString(loc.Country),
+		nullString(loc.CountryCode),
+		nullString(loc.Continent),
+		nullString(loc.ContinentCode),
+		nullString(loc.City),
+		nullInt(loc.ASN),
+		nullString(loc.ASOrg),
+		ip, since,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.db.Exec(`
+		UPDATE failure_aggregates SET country = ?, city = ? WHERE ip = ? AND hour >= ?
+	`, nullString(loc.Country), nullString(loc.City), ip, since.Truncate(time.Hour)); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// AddGeoIPComparison records the results of two GeoIP databases for ip. Each
+// IP is sampled once, so busy attackers do not dominate the disagreement rate.
+func (s *Storage) AddGeoIPComparison(ip string, seen time.Time, a, b geoip.Location) error {
+	_, err := s.db.Exec(`
+		INSERT OR IGNORE INTO geoip_comparisons (ip, seen_at, country_code_a, city_a, country_code_b, city_b)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, ip, seen, nullString(a.CountryCode), nullString(a.City), nullString(b.CountryCode), nullString(b.City))
+	return err
+}
+
+type GeoIPDisagreement struct {
+	IP           string
+	CountryCodeA string
+	CityA        string
+	CountryCodeB string
+	CityB        string
+}
+
+type GeoIPComparison struct {
+	Samples         int
+	First           time.Time
+	Last            time.Time
+	MissingA        int
+	MissingB        int
+	CountryMismatch int
+	CityMismatch    int
+	Examples        []GeoIPDisagreement
+}
+
+// GetGeoIPComparison summarises the recorded comparisons. Countries are only
+// compared when both databases know the IP; cities only when the countries
+// agree and both databases name a city.
+func (s *Storage) GetGeoIPComparison(examples int) (*GeoIPComparison, error) {
+	var c GeoIPComparison
+	err := s.db.QueryRow(`
+		SELECT
+			COUNT(*),
+			COUNT(CASE WHEN country_code_a IS NULL THEN 1 END),
+			COUNT(CASE WHEN country_code_b IS NULL THEN 1 END),
+			COUNT(CASE WHEN country_code_a != country_code_b THEN 1 END),
+			COUNT(CASE WHEN country_code_a = country_code_b AND city_a != city_b THEN 1 END)
+		FROM geoip_comparisons
+	`).Scan(&c.Samples, &c.MissingA, &c.MissingB, &c.CountryMismatch, &c.CityMismatch)
+	if err != nil {
+		return nil, err
+	}
+	if c.Samples == 0 {
+		return &c, nil
+	}
+
+	if err := s.db.QueryRow(`SELECT seen_at FROM geoip_comparisons ORDER BY seen_at ASC LIMIT 1`).Scan(&c.First); err != nil {
+		return nil, err
+	}
+	if err := s.db.QueryRow(`SELECT seen_at FROM geoip_comparisons ORDER BY seen_at DESC LIMIT 1`).Scan(&c.Last); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT ip, COALESCE(country_code_a, ''), COALESCE(city_a, ''), COALESCE(country_code_b, ''), COALESCE(city_b, '')
+		FROM geoip_comparisons
+		WHERE country_code_a != country_code_b
+		ORDER BY seen_at DESC
+		LIMIT ?
+	`, examples)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d GeoIPDisagreement
+		if err := rows.Scan(&d.IP, &d.CountryCodeA, &d.CityA, &d.CountryCodeB, &d.CityB); err != nil {
+			return nil, err
+		}
+		c.Examples = append(c.Examples, d)
+	}
+	return &c, rows.Err()
+}
+
+func (s *Storage) GetSuccessCount(since, until time.Time, service string) (int, error) {
 	var count int
 	err := s.db.QueryRow(`
 		SELECT COUNT(*) FROM ssh_events
-		WHERE event_type = 'success' AND timestamp >= ?
-	`, since).Scan(&count)
+		WHERE event_type = 'success' AND timestamp >= ? AND timestamp < ? AND (? = '' OR service = ?)
+	`, since, until, service, service).Scan(&count)
 	return count, err
 }
 
+type ServiceStats struct {
+	Service      string
+	SuccessCount int
+	FailedCount  int
+	UniqueIPs    int
+}
+
+func (s *Storage) GetServiceStats(since, until time.Time) ([]ServiceStats, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			service,
+			COUNT(CASE WHEN event_type = 'success' THEN 1 END) as success,
+			COUNT(CASE WHEN event_type = 'failure' THEN 1 END) as failed,
+			COUNT(DISTINCT ip) as unique_ips
+		FROM ssh_events
+		WHERE timestamp >= ? AND timestamp < ?
+		GROUP BY service
+		ORDER BY service = 'ssh' DESC, failed DESC
+	`, since, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ServiceStats
+	for rows.Next() {
+		var ss ServiceStats
+		if err := rows.Scan(&ss.Service, &ss.SuccessCount, &ss.FailedCount, &ss.UniqueIPs); err != nil {
+			return nil, err
+		}
+		results = append(results, ss)
+	}
+	return results, rows.Err()
+}
+
 type OverallStats struct {
 	SuccessCount    int
 	FailedCount     int
@@ -253,7 +871,7 @@ type OverallStats struct {
 	UniqueUsernames int
 }
 
-func (s *Storage) GetOverallStats(since time.Time) (*OverallStats, error) {
+func (s *Storage) GetOverallStats(since time.Time, service string) (*OverallStats, error) {
 	query := `
 		SELECT
 			COUNT(CASE WHEN event_type = 'success' THEN 1 END) as success,
@@ -261,30 +879,589 @@ func (s *Storage) GetOverallStats(since time.Time) (*OverallStats, error) {
 			COUNT(DISTINCT ip) as unique_ips,
 			COUNT(DISTINCT username) as unique_usernames
 		FROM ssh_events
-		WHERE timestamp >= ?
+		WHERE timestamp >= ? AND (? = '' OR service = ?)
 	`
 
 	var stats OverallStats
-	err := s.db.QueryRow(query, since).Scan(&stats.SuccessCount, &stats.FailedCount, &stats.UniqueIPs, &stats.UniqueUsernames)
+	err := s.db.QueryRow(query, since, service, service).Scan(&stats.SuccessCount, &stats.FailedCount, &stats.UniqueIPs, &stats.UniqueUsernames)
 	if err != nil {
 		return nil, err
 	}
 	return &stats, nil
 }
 
-func (s *Storage) Cleanup(retentionDays int) (int64, error) {
-	cutoff := time.Now().AddDate(0, 0, -retentionDays)
-	result, err := s.db.Exec(`DELETE FROM ssh_events WHERE timestamp < ?`, cutoff)
+// KeyUsage is how often one SSH key logged into one account from one IP.
+type KeyUsage struct {
+	Fingerprint string
+	KeyType     string
+	Username    string
+	IP          string
+	Country     string
+	CountryCode string
+	City        string
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	Logins      int
+}
+
+// GetKeyUsage returns the key inventory, optionally limited to one
+// fingerprint and/or username, ordered by fingerprint and username with the
+// most recent use first.
+func (s *Storage) GetKeyUsage(fingerprint, username string) ([]KeyUsage, error) {
+	rows, err := s.db.Query(`
+		SELECT fingerprint, COALESCE(key_type, ''), username, ip, COALESCE(country, ''), COALESCE(country_code, ''), COALESCE(city, ''),
+		       first_seen, last_seen, logins
+		FROM key_usage
+		WHERE (? = '' OR fingerprint = ?) AND (? = '' OR username = ?)
+		ORDER BY fingerprint, username, last_seen DESC
+	`, fingerprint, fingerprint, username, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []KeyUsage
+	for rows.Next() {
+		var k KeyUsage
+		if err := rows.Scan(&k.Fingerprint, &k.KeyType, &k.Username, &k.IP, &k.Country, &k.CountryCode, &k.City,
+			&k.FirstSeen, &k.LastSeen, &k.Logins); err != nil {
+			return nil, err
+		}
+		results = append(results, k)
+	}
+	return results, rows.Err()
+}
+
+// RollupIPsPerDay is how many of a day's failing IPs are kept in the
+// rollup. Long-range top offender lists are built from these, so an IP that
+// never made a day's top list is not counted.
+const RollupIPsPerDay = 100
+
+// DailyRollup holds one day's totals for one service. Rollups outlive
+// retention cleanup and feed the quarterly and annual summaries.
+type DailyRollup struct {
+	Day       time.Time
+	Service   string
+	Success   int
+	Failed    int
+	UniqueIPs int
+}
+
+// rollupFailures is every failed attempt in [?, ?) from both stored events
+// and low-disk aggregates, one row per event or aggregate with its weight n.
+const rollupFailures = `
+	SELECT service, ip, country, country_code, 1 AS n FROM ssh_events
+	WHERE event_type = 'failure' AND timestamp >= ? AND timestamp < ?
+	UNION ALL
+	SELECT service, ip, country, NULL, count FROM failure_aggregates
+	WHERE hour >= ? AND hour < ?
+`
+
+// Rollup summarises the UTC calendar day containing day into the rollup
+// tables, replacing any earlier rollup of that day.
+func (s *Storage) Rollup(day time.Time) error {
+	key := rollupKey(day)
+	// Stored timestamps carry the local offset and are compared as text.
+	start := rollupDay(day).Local()
+	end := start.Add(24 * time.Hour)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"daily_rollups", "daily_rollup_ips", "daily_rollup_countries"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE day = ?`, key); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO daily_rollups (day, service, success, failed, unique_ips)
+		SELECT ?, service,
+			SUM(CASE WHEN event_type = 'success' THEN n ELSE 0 END),
+			SUM(CASE WHEN event_type = 'failure' THEN n ELSE 0 END),
+			COUNT(DISTINCT ip)
+		FROM (
+			SELECT service, ip, event_type, 1 AS n FROM ssh_events
+			WHERE timestamp >= ? AND timestamp < ?
+			UNION ALL
+			SELECT service, ip, 'failure', count FROM failure_aggregates
+			WHERE hour >= ? AND hour < ?
+		)
+		GROUP BY service
+	`, key, start, end, start, end); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO daily_rollup_ips (day, ip, country, country_code, count)
+		SELECT ?, ip, MAX(country), MAX(country_code), SUM(n) AS total
+		FROM (`+rollupFailures+`)
+		GROUP BY ip
+		ORDER BY total DESC
+		LIMIT ?
+	`, key, start, end, start, end, RollupIPsPerDay); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO daily_rollup_countries (day, country, country_code, count)
+		SELECT ?, country, MAX(country_code), SUM(n)
+		FROM (`+rollupFailures+`)
+		WHERE country IS NOT NULL
+		GROUP BY country
+	`, key, start, end, start, end); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetDailyRollups returns the rollups of the days in [since, until), oldest
+// first.
+func (s *Storage) GetDailyRollups(since, until time.Time) ([]DailyRollup, error) {
+	rows, err := s.db.Query(`
+		SELECT day, service, success, failed, unique_ips
+		FROM daily_rollups
+		WHERE day >= ? AND day < ?
+		ORDER BY day, service
+	`, rollupKey(since), rollupKey(until))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []DailyRollup
+	for rows.Next() {
+		var r DailyRollup
+		var day string
+		if err := rows.Scan(&day, &r.Service, &r.Success, &r.Failed, &r.UniqueIPs); err != nil {
+			return nil, err
+		}
+		if r.Day, err = time.Parse("2006-01-02", day); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+func (s *Storage) GetRollupTopIPs(since, until time.Time, limit int) ([]IPCount, error) {
+	rows, err := s.db.Query(`
+		SELECT ip, COALESCE(MAX(country), ''), COALESCE(MAX(country_code), ''), SUM(count) AS total
+		FROM daily_rollup_ips
+		WHERE day >= ? AND day < ?
+		GROUP BY ip
+		ORDER BY total DESC, ip
+		LIMIT ?
+	`, rollupKey(since), rollupKey(until), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []IPCount
+	for rows.Next() {
+		var ic IPCount
+		if err := rows.Scan(&ic.IP, &ic.Country, &ic.CountryCode, &ic.Count); err != nil {
+			return nil, err
+		}
+		results = append(results, ic)
+	}
+	return results, rows.Err()
+}
+
+func (s *Storage) GetRollupTopCountries(since, until time.Time, limit int) ([]CountryCount, error) {
+	rows, err := s.db.Query(`
+		SELECT country, COALESCE(MAX(country_code), ''), SUM(count) AS total
+		FROM daily_rollup_countries
+		WHERE day >= ? AND day < ?
+		GROUP BY country
+		ORDER BY total DESC, country
+		LIMIT ?
+	`, rollupKey(since), rollupKey(until), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []CountryCount
+	for rows.Next() {
+		var cc CountryCount
+		if err := rows.Scan(&cc.Country, &cc.CountryCode, &cc.Count); err != nil {
+			return nil, err
+		}
+		results = append(results, cc)
+	}
+	return results, rows.Err()
+}
+
+func rollupDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func rollupKey(t time.Time) string {
+	return rollupDay(t).Format("2006-01-02")
+}
+
+func (s *Storage) GetState(key string) (string, error) {
+	var value string
+	err := s.db.QueryRow(`SELECT value FROM state WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+func (s *Storage) SetState(key, value string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO state (key, value, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, key, value)
+	return err
+}
+
+// Cleanup deletes events and aggregates older than retentionDays, except
+// those covered by an active legal hold.
+func (s *Storage) Cleanup(now time.Time, retentionDays int) (int64, error) {
+	cutoff := now.AddDate(0, 0, -retentionDays)
+	if _, err := s.db.Exec(`DELETE FROM campaigns WHERE last_seen < ?`, cutoff); err != nil {
+		return 0, err
+	}
+	holds, err := s.GetLegalHolds(false)
+	if err != nil {
+		return 0, err
+	}
+	if len(holds) == 0 {
+		result, err := s.db.Exec(`DELETE FROM ssh_events WHERE timestamp < ?`, cutoff)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := s.db.Exec(`DELETE FROM failure_aggregates WHERE hour < ?`, cutoff); err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	deleted, err := deleteUnheld(tx, "ssh_events", `SELECT rowid, ip, username, timestamp FROM ssh_events WHERE timestamp < ?`, cutoff, holds)
+	if err != nil {
+		return 0, err
+	}
+	// Aggregates have no username, only IP-only holds cover them.
+	if _, err := deleteUnheld(tx, "failure_aggregates", `SELECT rowid, ip, '', hour FROM failure_aggregates WHERE hour < ?`, cutoff, holds); err != nil {
+		return 0, err
+	}
+	return deleted, tx.Commit()
+}
+
+// deleteUnheld deletes the rows returned by query, which selects rowid, ip,
+// username and time, unless a hold covers them.
+func deleteUnheld(tx *sql.Tx, table, query string, cutoff time.Time, holds []LegalHold) (int64, error) {
+	rows, err := tx.Query(query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var (
+			id           int64
+			ip, username string
+			ts           time.Time
+		)
+		if err := rows.Scan(&id, &ip, &username, &ts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !heldBy(holds, ip, username, ts) {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.Prepare(fmt.Sprintf(`DELETE FROM %s WHERE rowid = ?`, table))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	for _, id := range ids {
+		if _, err := stmt.Exec(id); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(ids)), nil
+}
+
+// LegalHold keeps matching events from retention cleanup while an incident
+// is under investigation. Empty fields match everything, but a hold always
+// has an IP range or a username.
+type LegalHold struct {
+	ID         int64
+	IPRange    string
+	Username   string
+	Since      time.Time
+	Until      time.Time
+	Reason     string
+	CreatedAt  time.Time
+	ReleasedAt time.Time
+}
+
+// ParseIPRange accepts an IP address or a CIDR range and returns it as a
+// canonical range, e.g. "192.0.2.7" as "192.0.2.7/32".
+func ParseIPRange(s string) (string, error) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()).String(), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid IP or CIDR range %q", s)
+	}
+	return prefix.Masked().String(), nil
+}
+
+// Covers reports whether an event from ip by username at ts is held.
+func (h LegalHold) Covers(ip, username string, ts time.Time) bool {
+	if h.Username != "" && h.Username != username {
+		return false
+	}
+	if !h.Since.IsZero() && ts.Before(h.Since) {
+		return false
+	}
+	if !h.Until.IsZero() && !ts.Before(h.Until) {
+		return false
+	}
+	if h.IPRange == "" {
+		return true
+	}
+	prefix, err := netip.ParsePrefix(h.IPRange)
+	if err != nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && prefix.Contains(addr.Unmap())
+}
+
+func heldBy(holds []LegalHold, ip, username string, ts time.Time) bool {
+	for _, h := range holds {
+		if h.Covers(ip, username, ts) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Storage) AddLegalHold(h LegalHold) (int64, error) {
+	result, err := s.db.Exec(`
+		INSERT INTO legal_holds (ip_range, username, since, until, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, nullString(h.IPRange), nullString(h.Username), nullTime(h.Since), nullTime(h.Until), h.Reason, h.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetLegalHolds returns the active holds, and released ones too when
+// includeReleased is set, oldest first.
+func (s *Storage) GetLegalHolds(includeReleased bool) ([]LegalHold, error) {
+	rows, err := s.db.Query(`
+		SELECT id, COALESCE(ip_range, ''), COALESCE(username, ''), since, until, reason, created_at, released_at
+		FROM legal_holds
+		WHERE ? OR released_at IS NULL
+		ORDER BY id
+	`, includeReleased)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holds []LegalHold
+	for rows.Next() {
+		var h LegalHold
+		var since, until, released sql.NullTime
+		if err := rows.Scan(&h.ID, &h.IPRange, &h.Username, &since, &until, &h.Reason, &h.CreatedAt, &released); err != nil {
+			return nil, err
+		}
+		h.Since, h.Until, h.ReleasedAt = since.Time, until.Time, released.Time
+		holds = append(holds, h)
+	}
+	return holds, rows.Err()
+}
+
+// ReleaseLegalHold ends a hold, after which the next cleanup deletes its
+// events if they are past retention. released is false if there is no
+// active hold with that ID.
+func (s *Storage) ReleaseLegalHold(id int64, at time.Time) (released bool, err error) {
+	result, err := s.db.Exec(`UPDATE legal_holds SET released_at = ? WHERE id = ? AND released_at IS NULL`, at, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Campaign is a group of IPs in one network that tried the same usernames
+// in the same time window, most likely one attacker behind many addresses.
+type Campaign struct {
+	ID        string
+	ASN       uint
+	ASOrg     string
+	Usernames []string
+	IPs       []string
+	Attempts  int
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// SaveCampaign creates a campaign or replaces the one with the same ID.
+func (s *Storage) SaveCampaign(c Campaign) error {
+	_, err := s.db.Exec(`
+		INSERT INTO campaigns (id, asn, as_org, usernames, ips, attempts, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			as_org = excluded.as_org, usernames = excluded.usernames, ips = excluded.ips,
+			attempts = excluded.attempts, first_seen = excluded.first_seen, last_seen = excluded.last_seen
+	`, c.ID, c.ASN, c.ASOrg, strings.Join(c.Usernames, "\n"), strings.Join(c.IPs, "\n"), c.Attempts, c.FirstSeen, c.LastSeen)
+	return err
+}
+
+// GetCampaigns returns the campaigns active since the given time, most
+// recently started first.
+func (s *Storage) GetCampaigns(since time.Time) ([]Campaign, error) {
+	rows, err := s.db.Query(`
+		SELECT id, asn, as_org, usernames, ips, attempts, first_seen, last_seen
+		FROM campaigns
+		WHERE last_seen >= ?
+		ORDER BY first_seen DESC, id
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var campaigns []Campaign
+	for rows.Next() {
+		var c Campaign
+		var usernames, ips string
+		if err := rows.Scan(&c.ID, &c.ASN, &c.ASOrg, &usernames, &ips, &c.Attempts, &c.FirstSeen, &c.LastSeen); err != nil {
+			return nil, err
+		}
+		c.Usernames, c.IPs = strings.Split(usernames, "\n"), strings.Split(ips, "\n")
+		campaigns = append(campaigns, c)
+	}
+	return campaigns, rows.Err()
+}
+
+// TagCampaignEvents sets the campaign ID on the failed attempts from ips in
+// network asn in [since, until) that have none yet, and returns how many it
+// tagged.
+func (s *Storage) TagCampaignEvents(id string, asn uint, ips []string, since, until time.Time) (int64, error) {
+	if len(ips) == 0 {
+		return 0, nil
+	}
+	args := []any{id, asn, since, until}
+	for _, ip := range ips {
+		args = append(args, ip)
+	}
+	result, err := s.db.Exec(`
+		UPDATE ssh_events SET campaign_id = ?
+		WHERE event_type = 'failure' AND asn = ? AND timestamp >= ? AND timestamp < ?
+		  AND campaign_id IS NULL AND ip IN (?`+strings.Repeat(", ?", len(ips)-1)+`)
+	`, args...)
 	if err != nil {
 		return 0, err
 	}
 	return result.RowsAffected()
 }
 
+// TrustedNetwork is an IP range approved with `oxiwatch trusted approve`.
+// Logins from it are treated like those from trusted_networks in the config.
+type TrustedNetwork struct {
+	IPRange    string
+	Note       string
+	ApprovedAt time.Time
+}
+
+// AddTrustedNetwork approves a range given in canonical form (see
+// ParseIPRange). added is false if it was approved already.
+func (s *Storage) AddTrustedNetwork(n TrustedNetwork) (added bool, err error) {
+	result, err := s.db.Exec(`
+		INSERT OR IGNORE INTO trusted_networks (ip_range, note, approved_at)
+		VALUES (?, ?, ?)
+	`, n.IPRange, n.Note, n.ApprovedAt)
+	if err != nil {
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	return count > 0, err
+}
+
+// GetTrustedNetworks returns the approved ranges, oldest first.
+func (s *Storage) GetTrustedNetworks() ([]TrustedNetwork, error) {
+	rows, err := s.db.Query(`SELECT ip_range, note, approved_at FROM trusted_networks ORDER BY approved_at, ip_range`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var networks []TrustedNetwork
+	for rows.Next() {
+		var n TrustedNetwork
+		if err := rows.Scan(&n.IPRange, &n.Note, &n.ApprovedAt); err != nil {
+			return nil, err
+		}
+		networks = append(networks, n)
+	}
+	return networks, rows.Err()
+}
+
+// RemoveTrustedNetwork withdraws an approval. removed is false if the range
+// was not approved.
+func (s *Storage) RemoveTrustedNetwork(ipRange string) (removed bool, err error) {
+	result, err := s.db.Exec(`DELETE FROM trusted_networks WHERE ip_range = ?`, ipRange)
+	if err != nil {
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	return count > 0, err
+}
+
+// CopyTo writes a consistent copy of the database to path, which must not
+// exist yet.
+func (s *Storage) CopyTo(path string) error {
+	_, err := s.db.Exec(`VACUUM INTO ?`, path)
+	return err
+}
+
 func (s *Storage) Close() error {
 	return s.db.Close()
 }
 
+func nullInt(n uint) interface{} {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
 func nullString(s string) interface{} {
 	if s == "" {
 		return nil
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/oxisoft/oxiwatch/internal/geoip"
+	"github.com/oxisoft/oxiwatch/internal/parser"
+)
+
+// Store is implemented by the SQLite Storage and by the in-memory Memory
+// used in tests.
+type Store interface {
+	InsertEvent(event *parser.SSHEvent, loc geoip.Location) (inserted bool, err error)
+	AddFailureAggregate(event *parser.SSHEvent, loc geoip.Location) error
+	AddFailureAggregates(aggregates []FailureAggregate) error
+	GetAggregatedFailures(since, until time.Time, service string) (int, error)
+	GetSuccessfulLogins(since time.Time, filter EventFilter) ([]SSHEventRecord, error)
+	GetLastLoginForUser(service, username string) (*SSHEventRecord, error)
+	GetFailedAttempts(since time.Time, filter EventFilter) ([]SSHEventRecord, error)
+	GetFailedStats(since, until time.Time, service string) (*Stats, error)
+	GetTopUsernames(since, until time.Time, limit int, service string) ([]UsernameCount, error)
+	GetTopIPs(since, until time.Time, limit int, service string) ([]IPCount, error)
+	GetTopASNs(since, until time.Time, limit int) ([]ASNCount, error)
+	GetTopCountries(since, until time.Time, limit int) ([]CountryCount, error)
+	GetIPHistory(ip string, since time.Time) (*IPHistory, error)
+	GetIPsToEnrich(since time.Time, missingOnly, withASN bool) ([]string, error)
+	UpdateLocation(ip string, since time.Time, loc geoip.Location) (int64, error)
+	AddGeoIPComparison(ip string, seen time.Time, a, b geoip.Location) error
+	GetGeoIPComparison(examples int) (*GeoIPComparison, error)
+	GetSuccessCount(since, until time.Time, service string) (int, error)
+	GetServiceStats(since, until time.Time) ([]ServiceStats, error)
+	GetOverallStats(since time.Time, service string) (*OverallStats, error)
+	GetKeyUsage(fingerprint, username string) ([]KeyUsage, error)
+	Rollup(day time.Time) error
+	GetDailyRollups(since, until time.Time) ([]DailyRollup, error)
+	GetRollupTopIPs(since, until time.Time, limit int) ([]IPCount, error)
+	GetRollupTopCountries(since, until time.Time, limit int) ([]CountryCount, error)
+	AddLegalHold(h LegalHold) (int64, error)
+	GetLegalHolds(includeReleased bool) ([]LegalHold, error)
+	ReleaseLegalHold(id int64, at time.Time) (released bool, err error)
+	GetIPUserAttempts(since, until time.Time) ([]IPUserAttempts, error)
+	SaveCampaign(c Campaign) error
+	GetCampaigns(since time.Time) ([]Campaign, error)
+	TagCampaignEvents(id string, asn uint, ips []string, since, until time.Time) (int64, error)
+	AddTrustedNetwork(n TrustedNetwork) (added bool, err error)
+	GetTrustedNetworks() ([]TrustedNetwork, error)
+	RemoveTrustedNetwork(ipRange string) (removed bool, err error)
+	GetState(key string) (string, error)
+	SetState(key, value string) error
+	Cleanup(now time.Time, retentionDays int) (int64, error)
+	Close() error
+}
+
+var (
+	_ Store = (*Storage)(nil)
+	_ Store = (*Memory)(nil)
+)
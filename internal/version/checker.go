@@ -16,10 +16,14 @@ import (
 
 const (
 	githubAPIURL = "https://api.github.com/repos/oxisoft/oxiwatch/releases/latest"
+	githubTagURL = "https://api.github.com/repos/oxisoft/oxiwatch/releases/tags/"
+	ReleasesURL  = "https://github.com/oxisoft/oxiwatch/releases"
 )
 
 type Release struct {
 	TagName string  `json:"tag_name"`
+	Body    string  `json:"body"`
+	HTMLURL string  `json:"html_url"`
 	Assets  []Asset `json:"assets"`
 }
 
@@ -43,7 +47,16 @@ func NewChecker(currentVersion string) *Checker {
 }
 
 func (c *Checker) GetLatestRelease() (*Release, error) {
-	req, err := http.NewRequest("GET", githubAPIURL, nil)
+	return c.getRelease(githubAPIURL)
+}
+
+// GetRelease returns the release of a version such as "1.4.0".
+func (c *Checker) GetRelease(version string) (*Release, error) {
+	return c.getRelease(githubTagURL + "v" + strings.TrimPrefix(version, "v"))
+}
+
+func (c *Checker) getRelease(url string) (*Release, error) {
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -243,6 +256,11 @@ func (c *Checker) Upgrade(verbose bool) error {
 	return nil
 }
 
+// IsNewer reports whether version is a later release than previous.
+func IsNewer(version, previous string) bool {
+	return compareVersions(strings.TrimPrefix(version, "v"), strings.TrimPrefix(previous, "v")) > 0
+}
+
 func compareVersions(v1, v2 string) int {
 	parts1 := strings.Split(v1, ".")
 	parts2 := strings.Split(v2, ".")
@@ -0,0 +1,44 @@
+package version
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	markdownLink     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	markdownEmphasis = strings.NewReplacer("**", "", "__", "", "`", "")
+)
+
+// SummarizeNotes picks up to limit lines from release notes in Markdown:
+// the list items if there are any, otherwise the first paragraph lines.
+// Links are reduced to their text and emphasis is dropped.
+func SummarizeNotes(notes string, limit int) []string {
+	var items, lines []string
+	for _, line := range strings.Split(notes, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		item, isItem := strings.CutPrefix(line, "- ")
+		if !isItem {
+			item, isItem = strings.CutPrefix(line, "* ")
+		}
+		if isItem {
+			items = append(items, cleanMarkdown(item))
+		} else {
+			lines = append(lines, cleanMarkdown(line))
+		}
+	}
+	if len(items) == 0 {
+		items = lines
+	}
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+func cleanMarkdown(s string) string {
+	return strings.TrimSpace(markdownEmphasis.Replace(markdownLink.ReplaceAllString(s, "$1")))
+}
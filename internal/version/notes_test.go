@@ -0,0 +1,24 @@
+package version
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSummarizeNotes(t *testing.T) {
+	notes := "## What's Changed\r\n\r\nThis release groups attacks.\r\n\r\n" +
+		"- **Campaigns**: IPs in one network are grouped ([#12](https://example.com/12))\r\n" +
+		"* `oxiwatch trusted` approves IPs\r\n" +
+		"- Fix a crash\r\n" +
+		"- Docs\r\n"
+
+	got := SummarizeNotes(notes, 3)
+	want := []string{"Campaigns: IPs in one network are grouped (#12)", "oxiwatch trusted approves IPs", "Fix a crash"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got := SummarizeNotes("First line.\nSecond line.", 5); !reflect.DeepEqual(got, []string{"First line.", "Second line."}) {
+		t.Errorf("expected paragraph lines without list items, got %q", got)
+	}
+}